@@ -2,6 +2,7 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log/slog"
@@ -12,6 +13,7 @@ import (
 )
 
 var configurationPath string
+var adminClientID string
 
 func init() {
 	const (
@@ -20,6 +22,7 @@ func init() {
 	)
 	flag.StringVar(&configurationPath, "configuration", defaultConfiguration, configurationUsage)
 	flag.StringVar(&configurationPath, "c", defaultConfiguration, configurationUsage+" (shorthand)")
+	flag.StringVar(&adminClientID, "admin-secret", "", "prints a secret for the provided client id, which must be listed in adminClients, and exits")
 
 	flag.Usage = func() {
 		w := flag.CommandLine.Output()
@@ -43,7 +46,20 @@ func main() {
 		slog.Error("Configuration cannot be loaded, exiting ...", "error", err)
 		os.Exit(1)
 	}
+	if len(adminClientID) > 0 {
+		registration, err := server.RegisterAdminClient(c, adminClientID)
+		if err != nil {
+			slog.Error("Administrator secret cannot be generated, exiting ...", "error", err)
+			os.Exit(1)
+		}
+		jsn, _ := json.MarshalIndent(registration, "", "  ")
+		fmt.Println(string(jsn))
+		return
+	}
 	c.LogEnvironment()
 	s := server.NewConfigServer(c)
-	s.Start()
+	if err := s.Start(); err != nil {
+		slog.Error("ConfigServer stopped unexpectedly, exiting ...", "error", err)
+		os.Exit(1)
+	}
 }
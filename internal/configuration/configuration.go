@@ -2,11 +2,14 @@
 package configuration
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 
 	"gopkg.in/yaml.v3"
 )
@@ -26,6 +29,8 @@ type Configuration struct {
 	*Server
 	// Repositories configuration
 	*Repositories
+	// Tracing configuration
+	Tracing *Tracing `yaml:"tracing"`
 }
 
 // Environment gathers all the environment variable used by ConfigServer
@@ -36,44 +41,166 @@ type Environment struct {
 
 // Server groups all the configserver related settings
 type Server struct {
-	PassPhrase             string `yaml:"passPhrase"`             // key used for secret encryption
-	ListenOn               string `yaml:"listenOn"`               // address and port on which the server will listen for incoming requests
-	SecretExpiryDays       int    `yaml:"secretExpiryDays"`       // number of days after which a secret is considered as expired
-	ValidateSecretLifeSpan bool   `yaml:"validateSecretLifespan"` // if true, an expired secret will be considered invalid
+	PassPhrase             string   `yaml:"passPhrase"`             // key used for secret encryption
+	ListenOn               string   `yaml:"listenOn"`               // address and port on which the server will listen for incoming requests
+	SecretExpiryDays       int      `yaml:"secretExpiryDays"`       // number of days after which a secret is considered as expired
+	ValidateSecretLifeSpan bool     `yaml:"validateSecretLifespan"` // if true, an expired secret will be considered invalid
+	ShutdownTimeoutSeconds int      `yaml:"shutdownTimeoutSeconds"` // time given to in-flight requests and repository updates to complete on shutdown
+	AdminClients           []string `yaml:"adminClients"`           // clients allowed to administrate the repositories via the /api/repositories endpoints
+}
+
+// TracingOTLP designates the export of the traces to an OpenTelemetry collector using OTLP/HTTP
+const TracingOTLP = "otlp"
+
+// TracingFile designates the export of the traces to a local file
+const TracingFile = "file"
+
+// TracingStdout designates the export of the traces to the standard output
+const TracingStdout = "stdout"
+
+// Tracing configures the export of the traces, tracing is disabled when no exporter is set.
+// Changes to the tracing configuration require a restart
+type Tracing struct {
+	Exporter    string            `yaml:"exporter"`    // otlp, file or stdout
+	Endpoint    string            `yaml:"endpoint"`    // OTLP/HTTP traces endpoint i.e http://localhost:4318/v1/traces
+	Headers     map[string]string `yaml:"headers"`     // headers sent to the OTLP endpoint, values are resolved using ResolveSecret
+	Path        string            `yaml:"path"`        // file the traces are appended to
+	ServiceName string            `yaml:"serviceName"` // name the traces are reported under, defaults to configserver
 }
 
 // Repositories materializes the GIT repositories configuration
 type Repositories struct {
-	CheckoutLocation string        `yaml:"checkoutLocation"` // Folder to which the repositories are stored
-	Configuration    []*Repository `yaml:"configuration"`    // Collection of git repositories configuration
+	CheckoutLocation  string        `yaml:"checkoutLocation"`  // Folder to which the repositories are stored
+	Configuration     []*Repository `yaml:"configuration"`     // Collection of git repositories configuration
+	StateFile         string        `yaml:"stateFile"`         // File persisting the repositories administrated at runtime, see RepositoryState
+	StaleAfterSeconds int           `yaml:"staleAfterSeconds"` // Delay past its scheduled update after which a repository is no longer considered ready
 }
 
+// BackendGit designates repositories cloned from a git remote, the default backend
+const BackendGit = "git"
+
+// BackendLocal designates repositories served from a local directory i.e a mounted volume
+const BackendLocal = "local"
+
 // Repository is a single GIT repository configuration
 type Repository struct {
-	Name                   string   `yaml:"name"`
-	URL                    string   `yaml:"url"`
-	Branch                 string   `yaml:"branch"`
-	RefreshIntervalSeconds int      `yaml:"refreshIntervalSeconds"`
-	CheckoutLocation       string   `yaml:"checkoutLocation"`
-	Token                  string   `yaml:"token"`
-	Clients                []string `yaml:"clients"`
+	Name                   string          `yaml:"name" json:"name,omitempty"`
+	Backend                string          `yaml:"backend" json:"backend,omitempty"` // storage holding the repository files, either git (default) or local
+	Path                   string          `yaml:"path" json:"path,omitempty"`       // directory served by the local backend
+	URL                    string          `yaml:"url" json:"url,omitempty"`
+	Branch                 string          `yaml:"branch" json:"branch,omitempty"`
+	Tag                    string          `yaml:"tag" json:"tag,omitempty"`       // semantic version range or glob pattern, the highest matching tag is served instead of a branch
+	Commit                 string          `yaml:"commit" json:"commit,omitempty"` // commit served instead of a branch
+	RefreshIntervalSeconds int             `yaml:"refreshIntervalSeconds" json:"refreshIntervalSeconds,omitempty"`
+	CheckoutLocation       string          `yaml:"checkoutLocation" json:"checkoutLocation,omitempty"`
+	Token                  string          `yaml:"token" json:"token,omitempty"` // personal access token used to authenticate over https, see ResolveSecret
+	Auth                   *Authentication `yaml:"auth" json:"auth,omitempty"`   // credentials used to access the remote repository
+	Clients                []string        `yaml:"clients" json:"clients,omitempty"`
+	WebhookSecret          string          `yaml:"webhookSecret" json:"webhookSecret,omitempty"`   // shared secret used to authenticate push notifications
+	Retry                  *RetryPolicy    `yaml:"retry" json:"retry,omitempty"`                   // policy applied when the repository cannot be updated
+	Optional               bool            `yaml:"optional" json:"optional,omitempty"`             // optional repositories do not affect the server readiness
+	Depth                  int             `yaml:"depth" json:"depth,omitempty"`                   // number of commits fetched from the tip of the branches, 0 fetches the whole history
+	SingleBranch           bool            `yaml:"singleBranch" json:"singleBranch,omitempty"`     // fetch the tracked branch only, other branches and tags cannot be served
+	SparseCheckout         []string        `yaml:"sparseCheckout" json:"sparseCheckout,omitempty"` // directories checked out and served, the whole repository when empty
+	Submodules             bool            `yaml:"submodules" json:"submodules,omitempty"`         // recursively initialize and update the submodules, their files are served as part of the repository
+	LFS                    bool            `yaml:"lfs" json:"lfs,omitempty"`                       // serve the content of the git lfs pointer files instead of the pointers
+	LFSEndpoint            string          `yaml:"lfsEndpoint" json:"lfsEndpoint,omitempty"`       // lfs server url or local directory holding the lfs objects, derived from the url when empty
+	TrustedKeys            *TrustedKeys    `yaml:"trustedKeys" json:"trustedKeys,omitempty"`       // when set, only the revisions signed by one of these keys are served
+}
+
+// TrustedKeys holds the public keys trusted to sign the revisions served by a repository.
+// Values can be stored outside of the configuration file, see ResolveSecret
+type TrustedKeys struct {
+	OpenPGP []string `yaml:"openpgp" json:"openpgp,omitempty"` // armored OpenPGP public keys
+	SSH     []string `yaml:"ssh" json:"ssh,omitempty"`         // SSH public keys, one per line in the authorized keys or allowed signers format
+}
+
+// FollowsBranch returns true if the repository serves the head of a branch, as opposed to a tag or a commit
+func (r *Repository) FollowsBranch() bool {
+	return len(r.Tag) == 0 && len(r.Commit) == 0
+}
+
+// RetryPolicy controls how often failing repository updates are retried.
+// The delay between two attempts grows exponentially from InitialDelaySeconds up to MaxDelaySeconds
+type RetryPolicy struct {
+	InitialDelaySeconds int     `yaml:"initialDelaySeconds" json:"initialDelaySeconds,omitempty"` // delay before the first retry
+	MaxDelaySeconds     int     `yaml:"maxDelaySeconds" json:"maxDelaySeconds,omitempty"`         // upper bound of the delay between two attempts
+	Multiplier          float64 `yaml:"multiplier" json:"multiplier,omitempty"`                   // factor applied to the delay after each failed attempt
+	Jitter              float64 `yaml:"jitter" json:"jitter,omitempty"`                           // randomization factor between 0 and 1 applied to the delay
+}
+
+// DefaultRetryPolicy is used by repositories which do not provide their own retry policy
+var DefaultRetryPolicy = &RetryPolicy{
+	InitialDelaySeconds: 5,
+	MaxDelaySeconds:     300,
+	Multiplier:          2,
+	Jitter:              0.2,
+}
+
+// Authentication holds the credentials used to clone, fetch and pull a remote git repository.
+// Sensitive values can be stored outside of the configuration file, see ResolveSecret
+type Authentication struct {
+	Username         string   `yaml:"username" json:"username,omitempty"`                 // basic authentication username or ssh user, defaults to git
+	Password         string   `yaml:"password" json:"password,omitempty"`                 // basic authentication password or token
+	BearerToken      string   `yaml:"bearerToken" json:"bearerToken,omitempty"`           // token sent via the Authorization: Bearer header
+	SSHKey           string   `yaml:"sshKey" json:"sshKey,omitempty"`                     // PEM encoded ssh private key
+	SSHKeyPassphrase string   `yaml:"sshKeyPassphrase" json:"sshKeyPassphrase,omitempty"` // passphrase protecting the ssh private key if any
+	KnownHosts       []string `yaml:"knownHosts" json:"knownHosts,omitempty"`             // known_hosts files used to verify the remote host key, defaults to ~/.ssh/known_hosts
 }
 
 // DefaultConfiguration for when its needed
-var DefaultConfiguration = &Configuration{
-	Environment: &Environment{
-		Kind: "production",
-		Home: "/var/run/configserver",
-	},
-	Server: &Server{
-		PassPhrase:             "This is a default passphrase and should be changed",
-		ListenOn:               ":4200",
-		SecretExpiryDays:       365,
-		ValidateSecretLifeSpan: false,
-	},
-	Repositories: &Repositories{
-		CheckoutLocation: "",
-	},
+var DefaultConfiguration = defaultConfiguration()
+
+// defaultConfiguration creates a configuration holding the default settings, each call returns a distinct instance so
+// that loading a configuration never alters the defaults
+func defaultConfiguration() *Configuration {
+	return &Configuration{
+		Environment: &Environment{
+			Kind: "production",
+			Home: "/var/run/configserver",
+		},
+		Server: &Server{
+			PassPhrase:             "This is a default passphrase and should be changed",
+			ListenOn:               ":4200",
+			SecretExpiryDays:       365,
+			ValidateSecretLifeSpan: false,
+			ShutdownTimeoutSeconds: 30,
+		},
+		Repositories: &Repositories{
+			CheckoutLocation:  "",
+			StaleAfterSeconds: 300,
+		},
+	}
+}
+
+// SecretFromEnv is the prefix used in configuration values to reference an environment variable
+const SecretFromEnv = "env:"
+
+// SecretFromFile is the prefix used in configuration values to reference a file
+const SecretFromFile = "file:"
+
+// ResolveSecret returns the actual value of a sensitive configuration value.
+// Values prefixed by env: are read from the named environment variable, values prefixed by file: are read from the
+// given file (trailing new lines are trimmed), any other value is returned as is
+func ResolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, SecretFromEnv):
+		name := strings.TrimPrefix(value, SecretFromEnv)
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable '%s' is not defined", name)
+		}
+		return secret, nil
+	case strings.HasPrefix(value, SecretFromFile):
+		path := strings.TrimPrefix(value, SecretFromFile)
+		secret, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("'%s' cannot be read : %w", path, err)
+		}
+		return strings.TrimRight(string(secret), "\r\n"), nil
+	default:
+		return value, nil
+	}
 }
 
 // InitLogging sets up logging based on the CONFIGSERVER_ENV environment variable.
@@ -121,7 +248,7 @@ func LoadFrom(path string) (*Configuration, error) {
 		return nil, fmt.Errorf("'%s' configserver configuration cannot be loaded : %w", configPath, err)
 	}
 
-	config := DefaultConfiguration
+	config := defaultConfiguration()
 	config.Source = configPath
 
 	err = yaml.Unmarshal(data, &config)
@@ -134,9 +261,140 @@ func LoadFrom(path string) (*Configuration, error) {
 		slog.Info(fmt.Sprintf("Repositories checkout location defaulted to '%s'", config.Repositories.CheckoutLocation))
 	}
 
+	if len(config.Repositories.StateFile) > 0 {
+		state, err := LoadState(config.Repositories.StateFile)
+		if err != nil {
+			return nil, err
+		}
+		state.ApplyTo(config.Repositories)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("'%s' is not a valid configuration : %w", configPath, err)
+	}
+
 	return config, nil
 }
 
+// Validate checks the consistency of the configuration
+func (c *Configuration) Validate() error {
+	if c.Server == nil || len(c.Server.PassPhrase) == 0 {
+		return errors.New("a passPhrase is required")
+	}
+	if len(c.Server.ListenOn) == 0 {
+		return errors.New("a listenOn address is required")
+	}
+	if err := c.Tracing.Validate(); err != nil {
+		return err
+	}
+	if c.Repositories == nil {
+		return nil
+	}
+
+	names := make(map[string]bool)
+	for i, repo := range c.Repositories.Configuration {
+		if repo == nil || len(repo.Name) == 0 {
+			return fmt.Errorf("repository #%d has no name", i+1)
+		}
+		if err := repo.Validate(); err != nil {
+			return err
+		}
+		if names[repo.Name] {
+			return fmt.Errorf("repository '%s' is declared more than once", repo.Name)
+		}
+		names[repo.Name] = true
+	}
+	return nil
+}
+
+// Validate checks that the exporter is supported and provided with its destination
+func (t *Tracing) Validate() error {
+	if t == nil {
+		return nil
+	}
+	switch t.Exporter {
+	case "", TracingStdout:
+	case TracingOTLP:
+		if len(t.Endpoint) == 0 {
+			return errors.New("the otlp tracing exporter requires an endpoint")
+		}
+	case TracingFile:
+		if len(t.Path) == 0 {
+			return errors.New("the file tracing exporter requires a path")
+		}
+	default:
+		return fmt.Errorf("'%s' is not a supported tracing exporter, use either otlp, file or stdout", t.Exporter)
+	}
+	return nil
+}
+
+// Validate checks that the repository is named and provides the settings required by its backend
+func (r *Repository) Validate() error {
+	if len(r.Name) == 0 {
+		return errors.New("repository has no name")
+	}
+
+	switch r.Backend {
+	case "", BackendGit:
+		if len(r.URL) == 0 {
+			return fmt.Errorf("repository '%s' has no url", r.Name)
+		}
+		if r.Depth < 0 {
+			return fmt.Errorf("repository '%s' has a negative depth", r.Name)
+		}
+		for _, dir := range r.SparseCheckout {
+			if len(strings.Trim(path.Clean("/"+dir), "/")) == 0 {
+				return fmt.Errorf("repository '%s' sparse checkout directory '%s' designates the repository root", r.Name, dir)
+			}
+		}
+		if r.Submodules && len(r.SparseCheckout) > 0 {
+			return fmt.Errorf("repository '%s' cannot combine submodules with a sparse checkout", r.Name)
+		}
+		if (len(r.Tag) > 0 && len(r.Branch)+len(r.Commit) > 0) || (len(r.Branch) > 0 && len(r.Commit) > 0) {
+			return fmt.Errorf("repository '%s' can track either a branch, a tag or a commit", r.Name)
+		}
+		if _, err := path.Match(r.Tag, ""); err != nil {
+			return fmt.Errorf("repository '%s' tag pattern '%s' is invalid : %w", r.Name, r.Tag, err)
+		}
+		if len(r.Commit) > 0 && r.SingleBranch {
+			return fmt.Errorf("repository '%s' cannot track a commit with a single branch", r.Name)
+		}
+		if r.TrustedKeys != nil && len(r.TrustedKeys.OpenPGP) == 0 && len(r.TrustedKeys.SSH) == 0 {
+			return fmt.Errorf("repository '%s' does not trust any signing key", r.Name)
+		}
+	case BackendLocal:
+		if len(r.Path) == 0 {
+			return fmt.Errorf("repository '%s' has no path", r.Name)
+		}
+	default:
+		return fmt.Errorf("repository '%s' uses the unsupported backend '%s'", r.Name, r.Backend)
+	}
+	return nil
+}
+
+// Runtime holds the configuration currently in effect. Reloaded configurations are swapped as a whole so that
+// readers never observe a partially updated configuration
+type Runtime struct {
+	current atomic.Pointer[Configuration]
+}
+
+// NewRuntime creates a runtime holding the provided configuration
+func NewRuntime(c *Configuration) *Runtime {
+	runtime := &Runtime{}
+	runtime.current.Store(c)
+	return runtime
+}
+
+// Current returns the configuration currently in effect
+func (r *Runtime) Current() *Configuration {
+	return r.current.Load()
+}
+
+// Swap replaces the configuration in effect and returns the previous one
+func (r *Runtime) Swap(c *Configuration) *Configuration {
+	return r.current.Swap(c)
+}
+
 // LogEnvironment logs the current environment configuration
 func (c *Configuration) LogEnvironment() {
 	slog.Info("Configserver Runtime Environment",
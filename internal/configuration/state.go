@@ -0,0 +1,99 @@
+package configuration
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RepositoryState records the repositories administrated at runtime so that they survive restarts and configuration
+// reloads. The state is applied on top of the repositories declared in the configuration file
+type RepositoryState struct {
+	Repositories []*Repository `yaml:"repositories"` // repositories created or updated at runtime
+	Removed      []string      `yaml:"removed"`      // names of the repositories deleted at runtime
+}
+
+// LoadState reads the repositories state from the provided file, an empty state is returned if the file does not exist
+func LoadState(path string) (*RepositoryState, error) {
+	state := &RepositoryState{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("'%s' repositories state cannot be loaded : %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("'%s' cannot unmarshal repositories state : %w", path, err)
+	}
+	return state, nil
+}
+
+// Save writes the repositories state to the provided file. The file is replaced atomically so that a crash never
+// leaves a truncated state behind
+func (s *RepositoryState) Save(path string) error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("'%s' cannot marshal repositories state : %w", path, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("'%s' repositories state cannot be saved : %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("'%s' repositories state cannot be saved : %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("'%s' repositories state cannot be saved : %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("'%s' repositories state cannot be saved : %w", path, err)
+	}
+	return nil
+}
+
+// Upsert records the creation or the update of a repository
+func (s *RepositoryState) Upsert(repository *Repository) {
+	s.Removed = slices.DeleteFunc(s.Removed, func(name string) bool { return name == repository.Name })
+	for i, existing := range s.Repositories {
+		if existing.Name == repository.Name {
+			s.Repositories[i] = repository
+			return
+		}
+	}
+	s.Repositories = append(s.Repositories, repository)
+}
+
+// Remove records the deletion of a repository
+func (s *RepositoryState) Remove(name string) {
+	s.Repositories = slices.DeleteFunc(s.Repositories, func(repository *Repository) bool { return repository.Name == name })
+	if !slices.Contains(s.Removed, name) {
+		s.Removed = append(s.Removed, name)
+	}
+}
+
+// ApplyTo removes the deleted repositories from the provided configuration and replaces or appends the repositories
+// created or updated at runtime
+func (s *RepositoryState) ApplyTo(repositories *Repositories) {
+	configured := slices.DeleteFunc(slices.Clone(repositories.Configuration), func(repository *Repository) bool {
+		return repository != nil && slices.Contains(s.Removed, repository.Name)
+	})
+
+	for _, repository := range s.Repositories {
+		i := slices.IndexFunc(configured, func(existing *Repository) bool { return existing != nil && existing.Name == repository.Name })
+		if i >= 0 {
+			configured[i] = repository
+		} else {
+			configured = append(configured, repository)
+		}
+	}
+	repositories.Configuration = configured
+}
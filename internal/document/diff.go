@@ -0,0 +1,205 @@
+package document
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ChangeType qualifies how a key changed between two documents
+type ChangeType string
+
+const (
+	KeyAdded    ChangeType = "added"    // KeyAdded designates keys which only exist in the newer document
+	KeyRemoved  ChangeType = "removed"  // KeyRemoved designates keys which only exist in the older document
+	KeyModified ChangeType = "modified" // KeyModified designates keys whose value changed
+)
+
+// KeyChange describes the change of a single flattened key
+type KeyChange struct {
+	Key  string     `json:"key"`
+	Type ChangeType `json:"type"`
+	From any        `json:"from,omitempty"`
+	To   any        `json:"to,omitempty"`
+}
+
+// DiffKeys compares two documents key by key, nested keys being flattened using the dotted notation.
+// Changes are sorted by key
+func DiffKeys(from map[string]any, to map[string]any) []KeyChange {
+	before, after := Flatten(from), Flatten(to)
+
+	changes := []KeyChange{}
+	for key, value := range before {
+		updated, ok := after[key]
+		if !ok {
+			changes = append(changes, KeyChange{Key: key, Type: KeyRemoved, From: value})
+		} else if !reflect.DeepEqual(value, updated) {
+			changes = append(changes, KeyChange{Key: key, Type: KeyModified, From: value, To: updated})
+		}
+	}
+	for key, value := range after {
+		if _, ok := before[key]; !ok {
+			changes = append(changes, KeyChange{Key: key, Type: KeyAdded, To: value})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// diffContext is the number of unchanged lines surrounding the changes of a unified diff hunk
+const diffContext = 3
+
+// lineEdit is a single line of a line based diff
+type lineEdit struct {
+	kind byte   // ' ' for unchanged lines, '-' for removed lines and '+' for added lines
+	line string // line content
+	from int    // number of lines of the older text preceding the edit
+	to   int    // number of lines of the newer text preceding the edit
+}
+
+// maxDiffCells bounds the memory used to diff two texts : the product of the numbers of lines of the changed regions
+// of the texts, once the common prefix and suffix are trimmed, cannot exceed it
+const maxDiffCells = 1 << 20
+
+// ErrDiffTooLarge is returned when the changed regions of two texts are too large to be diffed
+var ErrDiffTooLarge = errors.New("the changes are too large to be diffed")
+
+// UnifiedDiff renders the line differences between two texts in the unified format, an empty string is returned if
+// the texts are identical. Lines are compared as is but rendered through display which can be used to hide sensitive
+// values, in which case a line can appear as both removed and added when only its hidden part changed
+func UnifiedDiff(fromName string, toName string, from string, to string, display func(string) string) (string, error) {
+	edits, err := diffLines(splitLines(from), splitLines(to))
+	if err != nil {
+		return "", err
+	}
+
+	var changed []int
+	for i, edit := range edits {
+		if edit.kind != ' ' {
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return "", nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	for i := 0; i < len(changed); {
+		// Changes separated by less than twice the context are rendered in the same hunk
+		j := i
+		for j+1 < len(changed) && changed[j+1]-changed[j] <= 2*diffContext {
+			j++
+		}
+		start, end := max(0, changed[i]-diffContext), min(len(edits), changed[j]+diffContext+1)
+		writeHunk(&sb, edits[start:end], display)
+		i = j + 1
+	}
+	return sb.String(), nil
+}
+
+// writeHunk renders a unified diff hunk
+func writeHunk(sb *strings.Builder, edits []lineEdit, display func(string) string) {
+	fromCount, toCount := 0, 0
+	for _, edit := range edits {
+		if edit.kind != '+' {
+			fromCount++
+		}
+		if edit.kind != '-' {
+			toCount++
+		}
+	}
+
+	// Empty ranges designate the line after which the lines are added or removed
+	fromStart, toStart := edits[0].from, edits[0].to
+	if fromCount > 0 {
+		fromStart++
+	}
+	if toCount > 0 {
+		toStart++
+	}
+
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", fromStart, fromCount, toStart, toCount)
+	for _, edit := range edits {
+		sb.WriteByte(edit.kind)
+		sb.WriteString(display(edit.line))
+		sb.WriteByte('\n')
+	}
+}
+
+// splitLines splits a text into lines, the trailing new line is not considered as an empty line
+func splitLines(text string) []string {
+	if len(text) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines computes the shortest edit script between two list of lines using their longest common subsequence.
+// Common prefixes and suffixes are trimmed beforehand as configuration changes usually are localized
+func diffLines(from []string, to []string) ([]lineEdit, error) {
+	prefix := 0
+	for prefix < len(from) && prefix < len(to) && from[prefix] == to[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(from)-prefix && suffix < len(to)-prefix && from[len(from)-1-suffix] == to[len(to)-1-suffix] {
+		suffix++
+	}
+	a, b := from[prefix:len(from)-suffix], to[prefix:len(to)-suffix]
+	if (len(a)+1)*(len(b)+1) > maxDiffCells {
+		return nil, fmt.Errorf("%d lines changed into %d lines : %w", len(a), len(b), ErrDiffTooLarge)
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	edits := make([]lineEdit, 0, len(from)+len(to))
+	i, j := 0, 0
+	emit := func(kind byte, line string) {
+		edits = append(edits, lineEdit{kind: kind, line: line, from: i, to: j})
+		if kind != '+' {
+			i++
+		}
+		if kind != '-' {
+			j++
+		}
+	}
+
+	for _, line := range from[:prefix] {
+		emit(' ', line)
+	}
+	x, y := 0, 0
+	for x < len(a) || y < len(b) {
+		switch {
+		case x < len(a) && y < len(b) && a[x] == b[y]:
+			emit(' ', a[x])
+			x, y = x+1, y+1
+		case y == len(b) || (x < len(a) && lcs[x+1][y] >= lcs[x][y+1]):
+			emit('-', a[x])
+			x++
+		default:
+			emit('+', b[y])
+			y++
+		}
+	}
+	for _, line := range from[len(from)-suffix:] {
+		emit(' ', line)
+	}
+	return edits, nil
+}
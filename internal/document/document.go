@@ -0,0 +1,194 @@
+// Package document parses, flattens and renders the configuration documents served by configserver
+package document
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is a configuration document serialization format
+type Format string
+
+const (
+	YAML       Format = "yaml"       // YAML is the YAML document format
+	JSON       Format = "json"       // JSON is the JSON document format
+	Properties Format = "properties" // Properties is the java .properties document format
+	Dotenv     Format = "dotenv"     // Dotenv is the KEY=value environment file format
+	TOML       Format = "toml"       // TOML is the TOML document format
+)
+
+// ErrUnsupportedFormat is returned when a document cannot be parsed or rendered in the requested format
+var ErrUnsupportedFormat = errors.New("unsupported document format")
+
+var extensions = map[string]Format{
+	".yml":        YAML,
+	".yaml":       YAML,
+	".json":       JSON,
+	".properties": Properties,
+	".env":        Dotenv,
+	".toml":       TOML,
+}
+
+// FormatOf returns the format of the provided file based on its extension
+func FormatOf(filename string) (Format, bool) {
+	format, ok := extensions[strings.ToLower(filepath.Ext(filename))]
+	return format, ok
+}
+
+var names = map[string]Format{
+	"yaml":       YAML,
+	"yml":        YAML,
+	"json":       JSON,
+	"properties": Properties,
+	"dotenv":     Dotenv,
+	"env":        Dotenv,
+	"toml":       TOML,
+}
+
+// ParseFormat returns the format designated by the provided name i.e yaml, json, properties, dotenv or toml
+func ParseFormat(name string) (Format, bool) {
+	format, ok := names[strings.ToLower(name)]
+	return format, ok
+}
+
+// Parse decodes a configuration document into a tree made of map[string]any, []any and scalar values
+func Parse(content []byte, format Format) (map[string]any, error) {
+	tree := make(map[string]any)
+	switch format {
+	case YAML:
+		var root any
+		if err := yaml.Unmarshal(content, &root); err != nil {
+			return nil, err
+		}
+		if root == nil {
+			return tree, nil
+		}
+		normalized, ok := normalize(root).(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("yaml document root is not a mapping")
+		}
+		return normalized, nil
+	case JSON:
+		if err := json.Unmarshal(content, &tree); err != nil {
+			return nil, err
+		}
+		return tree, nil
+	case Properties:
+		flat, err := parseProperties(content)
+		if err != nil {
+			return nil, err
+		}
+		return Expand(flat), nil
+	case Dotenv:
+		return parseDotenv(content)
+	case TOML:
+		if err := toml.Unmarshal(content, &tree); err != nil {
+			return nil, err
+		}
+		return tree, nil
+	default:
+		return nil, fmt.Errorf("'%s' cannot be parsed : %w", format, ErrUnsupportedFormat)
+	}
+}
+
+// Options controls how nested keys are flattened by the properties and dotenv formats
+type Options struct {
+	Separator string // inserted between nested keys, defaults to . for properties and _ for dotenv
+	Uppercase bool   // if true the flattened keys are converted to upper case
+}
+
+// DefaultOptions returns the options used to render the provided format when none are provided
+func DefaultOptions(format Format) *Options {
+	if format == Dotenv {
+		return &Options{Separator: "_", Uppercase: true}
+	}
+	return &Options{Separator: "."}
+}
+
+// Marshal renders a document tree in the requested format, options only apply to flat formats and default to
+// DefaultOptions when nil
+func Marshal(tree map[string]any, format Format, options *Options) ([]byte, error) {
+	if options == nil {
+		options = DefaultOptions(format)
+	}
+
+	switch format {
+	case YAML:
+		buffer := &bytes.Buffer{}
+		encoder := yaml.NewEncoder(buffer)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(tree); err != nil {
+			return nil, err
+		}
+		return buffer.Bytes(), nil
+	case JSON:
+		return json.MarshalIndent(tree, "", "  ")
+	case Properties:
+		return marshalProperties(FlattenWith(tree, options)), nil
+	case Dotenv:
+		return marshalDotenv(FlattenWith(tree, options)), nil
+	case TOML:
+		buffer := &bytes.Buffer{}
+		if err := toml.NewEncoder(buffer).Encode(withoutNulls(tree)); err != nil {
+			return nil, err
+		}
+		return buffer.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("'%s' cannot be rendered : %w", format, ErrUnsupportedFormat)
+	}
+}
+
+// normalize converts the map[any]any produced by the yaml decoder for non string keys to map[string]any
+func normalize(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = normalize(item)
+		}
+		return v
+	case map[any]any:
+		converted := make(map[string]any, len(v))
+		for key, item := range v {
+			converted[fmt.Sprint(key)] = normalize(item)
+		}
+		return converted
+	case []any:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// withoutNulls returns a copy of the tree without null values which cannot be represented by some formats such as TOML
+func withoutNulls(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		copied := make(map[string]any, len(v))
+		for key, item := range v {
+			if item != nil {
+				copied[key] = withoutNulls(item)
+			}
+		}
+		return copied
+	case []any:
+		copied := make([]any, 0, len(v))
+		for _, item := range v {
+			if item != nil {
+				copied = append(copied, withoutNulls(item))
+			}
+		}
+		return copied
+	default:
+		return v
+	}
+}
@@ -0,0 +1,230 @@
+package document
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlatten(t *testing.T) {
+	tree := map[string]any{
+		"server": map[string]any{
+			"port":  8080,
+			"hosts": []any{"a", map[string]any{"name": "b"}},
+		},
+	}
+
+	assert.Equal(t, map[string]any{
+		"server.port":          8080,
+		"server.hosts[0]":      "a",
+		"server.hosts[1].name": "b",
+	}, Flatten(tree))
+}
+
+func TestExpandIsFlattenInverse(t *testing.T) {
+	tree := map[string]any{
+		"server": map[string]any{
+			"port":   8080,
+			"hosts":  []any{"a", map[string]any{"name": "b"}},
+			"matrix": []any{[]any{1, 2}, []any{3}},
+		},
+		"empty": map[string]any{},
+	}
+
+	assert.Equal(t, tree, Expand(Flatten(tree)))
+}
+
+func TestParseProperties(t *testing.T) {
+	content := `
+# comment
+! other comment
+server.port=8080
+server.host : localhost
+server.name  configserver
+message=multi \
+        line
+escaped\:key=tab\tseparated é
+`
+	tree, err := Parse([]byte(content), Properties)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"server": map[string]any{
+			"port": "8080",
+			"host": "localhost",
+			"name": "configserver",
+		},
+		"message":     "multi line",
+		"escaped:key": "tab\tseparated é",
+	}, tree)
+}
+
+func TestMarshalProperties(t *testing.T) {
+	content, err := Marshal(map[string]any{
+		"server": map[string]any{"port": 8080, "hosts": []any{"a", "b"}},
+		"key=":   "multi\nline",
+	}, Properties, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "key\\==multi\\nline\nserver.hosts[0]=a\nserver.hosts[1]=b\nserver.port=8080\n", string(content))
+
+	tree, err := Parse(content, Properties)
+	assert.NoError(t, err)
+	assert.Equal(t, "multi\nline", tree["key="])
+}
+
+func TestParseYAML(t *testing.T) {
+	tree, err := Parse([]byte("server:\n  port: 8080\n  1: one\n"), YAML)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"server": map[string]any{"port": 8080, "1": "one"}}, tree)
+
+	_, err = Parse([]byte("- a\n- b\n"), YAML)
+	assert.Error(t, err)
+}
+
+func TestFormatOf(t *testing.T) {
+	format, ok := FormatOf("config/application.YML")
+	assert.True(t, ok)
+	assert.Equal(t, YAML, format)
+
+	_, ok = FormatOf("README.md")
+	assert.False(t, ok)
+}
+
+func TestMerge(t *testing.T) {
+	base := map[string]any{
+		"server": map[string]any{"host": "localhost", "port": 80},
+		"hosts":  []any{"a", "b"},
+		"name":   "base",
+	}
+	overlay := map[string]any{
+		"server": map[string]any{"port": 443, "tls": true},
+		"hosts":  []any{"c"},
+	}
+
+	assert.Equal(t, map[string]any{
+		"server": map[string]any{"host": "localhost", "port": 443, "tls": true},
+		"hosts":  []any{"c"},
+		"name":   "base",
+	}, Merge(base, overlay))
+	assert.Equal(t, 80, base["server"].(map[string]any)["port"], "base document must not be modified")
+}
+
+func TestMapStrings(t *testing.T) {
+	tree := map[string]any{"a": "x", "b": []any{"y", 1}, "c": map[string]any{"d": "z"}}
+	err := MapStrings(tree, func(s string) (string, error) { return s + s, nil })
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": "xx", "b": []any{"yy", 1}, "c": map[string]any{"d": "zz"}}, tree)
+}
+
+func TestDotenvRoundTrip(t *testing.T) {
+	tree := map[string]any{
+		"database": map[string]any{
+			"host":  "localhost",
+			"hosts": []any{"a", "b"},
+			"motd":  "hello \"world\"\n",
+		},
+		"server-port": 8080,
+	}
+
+	content, err := Marshal(tree, Dotenv, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "DATABASE_HOST=localhost\nDATABASE_HOSTS_0=a\nDATABASE_HOSTS_1=b\nDATABASE_MOTD=\"hello \\\"world\\\"\\n\"\nSERVER_PORT=8080\n", string(content))
+
+	parsed, err := Parse(content, Dotenv)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello \"world\"\n", parsed["DATABASE_MOTD"])
+	assert.Equal(t, "8080", parsed["SERVER_PORT"])
+}
+
+func TestDotenvCustomSeparator(t *testing.T) {
+	content, err := Marshal(map[string]any{"database": map[string]any{"host": "localhost"}}, Dotenv, &Options{Separator: "__"})
+	assert.NoError(t, err)
+	assert.Equal(t, "database__host=localhost\n", string(content))
+}
+
+func TestParseDotenv(t *testing.T) {
+	tree, err := Parse([]byte("# comment\nexport A=1\nB='single # quoted'\nC=value # comment\n"), Dotenv)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"A": "1", "B": "single # quoted", "C": "value"}, tree)
+
+	_, err = Parse([]byte("MISSING_SEPARATOR\n"), Dotenv)
+	assert.Error(t, err)
+}
+
+func TestTOMLRoundTrip(t *testing.T) {
+	tree, err := Parse([]byte("title = \"config\"\n[server]\nport = 8080\nhosts = [\"a\", \"b\"]\n"), TOML)
+	assert.NoError(t, err)
+
+	content, err := Marshal(Merge(tree, map[string]any{"ignored": nil}), TOML, nil)
+	assert.NoError(t, err)
+
+	parsed, err := Parse(content, TOML)
+	assert.NoError(t, err)
+	assert.Equal(t, tree, parsed)
+}
+
+func TestYAMLToJSON(t *testing.T) {
+	tree, err := Parse([]byte("server:\n  port: 8080\n"), YAML)
+	assert.NoError(t, err)
+
+	content, err := Marshal(tree, JSON, nil)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"server": {"port": 8080}}`, string(content))
+}
+
+func TestDiffKeys(t *testing.T) {
+	from := map[string]any{"server": map[string]any{"port": 8080, "host": "localhost"}, "debug": true}
+	to := map[string]any{"server": map[string]any{"port": 9090, "host": "localhost"}, "log": "info"}
+
+	assert.Equal(t, []KeyChange{
+		{Key: "debug", Type: KeyRemoved, From: true},
+		{Key: "log", Type: KeyAdded, To: "info"},
+		{Key: "server.port", Type: KeyModified, From: 8080, To: 9090},
+	}, DiffKeys(from, to))
+	assert.Empty(t, DiffKeys(from, from))
+}
+
+// unifiedDiff renders the unified diff between two texts
+func unifiedDiff(t *testing.T, from string, to string, display func(string) string) string {
+	diff, err := UnifiedDiff("from", "to", from, to, display)
+	assert.NoError(t, err)
+	return diff
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	from := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	to := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n"
+
+	expected := "--- from\n+++ to\n" +
+		"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n" +
+		"@@ -11,3 +11,4 @@\n k\n l\n m\n+n\n"
+	assert.Equal(t, expected, unifiedDiff(t, from, to, func(line string) string { return line }))
+	assert.Empty(t, unifiedDiff(t, from, from, func(line string) string { return line }))
+}
+
+func TestUnifiedDiffEmptyRanges(t *testing.T) {
+	identity := func(line string) string { return line }
+	assert.Equal(t, "--- from\n+++ to\n@@ -0,0 +1,2 @@\n+a\n+b\n", unifiedDiff(t, "", "a\nb\n", identity))
+	assert.Equal(t, "--- from\n+++ to\n@@ -1,2 +0,0 @@\n-a\n-b\n", unifiedDiff(t, "a\nb", "", identity))
+}
+
+func TestUnifiedDiffTooLarge(t *testing.T) {
+	var from, to strings.Builder
+	for i := 0; i < 1100; i++ {
+		fmt.Fprintf(&from, "key%d: a\n", i)
+		fmt.Fprintf(&to, "key%d: b\n", i)
+	}
+	_, err := UnifiedDiff("from", "to", from.String(), to.String(), func(line string) string { return line })
+	assert.ErrorIs(t, err, ErrDiffTooLarge)
+
+	diff := unifiedDiff(t, "header\n"+from.String()+"a\n", "header\n"+from.String()+"b\n", func(line string) string { return line })
+	assert.Contains(t, diff, "-a\n+b\n", "large files with localized changes are diffed")
+}
+
+func TestUnifiedDiffDisplay(t *testing.T) {
+	masked := unifiedDiff(t, "password: secret1\n", "password: secret2\n", func(line string) string {
+		return strings.ReplaceAll(strings.ReplaceAll(line, "secret1", "***"), "secret2", "***")
+	})
+	assert.Equal(t, "--- from\n+++ to\n@@ -1,1 +1,1 @@\n-password: ***\n+password: ***\n", masked)
+}
@@ -0,0 +1,119 @@
+package document
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// parseDotenv decodes a KEY=value environment file, keys are kept as is and values are always strings.
+// Comments, the export prefix and single or double quoted values are supported
+func parseDotenv(content []byte) (map[string]any, error) {
+	flat := make(map[string]any)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || text[0] == '#' {
+			continue
+		}
+
+		key, value, ok := strings.Cut(strings.TrimPrefix(text, "export "), "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d : missing '=' separator", line)
+		}
+
+		unquoted, err := unquoteDotenv(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d : %w", line, err)
+		}
+		flat[strings.TrimSpace(key)] = unquoted
+	}
+	return flat, scanner.Err()
+}
+
+func unquoteDotenv(value string) (string, error) {
+	if len(value) == 0 {
+		return value, nil
+	}
+
+	switch value[0] {
+	case '\'':
+		end := strings.IndexByte(value[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted value")
+		}
+		return value[1 : end+1], nil
+	case '"':
+		builder := strings.Builder{}
+		for i := 1; i < len(value); i++ {
+			switch value[i] {
+			case '"':
+				return builder.String(), nil
+			case '\\':
+				i++
+				if i == len(value) {
+					break
+				}
+				switch value[i] {
+				case 'n':
+					builder.WriteByte('\n')
+				case 'r':
+					builder.WriteByte('\r')
+				case 't':
+					builder.WriteByte('\t')
+				default:
+					builder.WriteByte(value[i])
+				}
+			default:
+				builder.WriteByte(value[i])
+			}
+		}
+		return "", fmt.Errorf("unterminated quoted value")
+	default:
+		// unquoted values end with inline comments
+		if comment := strings.Index(value, " #"); comment >= 0 {
+			value = value[:comment]
+		}
+		return strings.TrimSpace(value), nil
+	}
+}
+
+// marshalDotenv renders a flat map as an environment file with sorted keys.
+// Characters which are not allowed in environment variable names are replaced by underscores
+func marshalDotenv(flat map[string]any) []byte {
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buffer := &bytes.Buffer{}
+	for _, key := range keys {
+		buffer.WriteString(dotenvKey(key))
+		buffer.WriteByte('=')
+		buffer.WriteString(quoteDotenv(scalar(flat[key])))
+		buffer.WriteByte('\n')
+	}
+	return buffer.Bytes()
+}
+
+func dotenvKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// quoteDotenv double quotes values containing whitespaces, quotes or special characters
+func quoteDotenv(value string) string {
+	if !strings.ContainsAny(value, " \t\r\n\"'#$\\`") {
+		return value
+	}
+
+	replacer := strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n", "\r", "\\r", "\t", "\\t")
+	return "\"" + replacer.Replace(value) + "\""
+}
@@ -0,0 +1,159 @@
+package document
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Flatten converts a document tree into a map of dotted keys where list items are designated by their index
+// i.e {"server": {"ports": [80]}} becomes {"server.ports[0]": 80}
+func Flatten(tree map[string]any) map[string]any {
+	return FlattenWith(tree, &Options{Separator: "."})
+}
+
+// FlattenWith converts a document tree into a map of keys joined by the provided separator.
+// List items are designated by their index, between brackets when the separator is a dot, separated otherwise
+// i.e {"server": {"ports": [80]}} becomes {"SERVER_PORTS_0": 80} using the _ separator and upper case keys
+func FlattenWith(tree map[string]any, options *Options) map[string]any {
+	flat := make(map[string]any)
+	flatten("", tree, flat, options.Separator)
+	if !options.Uppercase {
+		return flat
+	}
+
+	upper := make(map[string]any, len(flat))
+	for key, value := range flat {
+		upper[strings.ToUpper(key)] = value
+	}
+	return upper
+}
+
+func flatten(prefix string, value any, flat map[string]any, separator string) {
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) == 0 && len(prefix) > 0 {
+			flat[prefix] = v
+		}
+		for key, item := range v {
+			if len(prefix) > 0 {
+				key = prefix + separator + key
+			}
+			flatten(key, item, flat, separator)
+		}
+	case []any:
+		if len(v) == 0 {
+			flat[prefix] = v
+		}
+		for i, item := range v {
+			if separator == "." {
+				flatten(prefix+"["+strconv.Itoa(i)+"]", item, flat, separator)
+			} else {
+				flatten(prefix+separator+strconv.Itoa(i), item, flat, separator)
+			}
+		}
+	default:
+		flat[prefix] = v
+	}
+}
+
+// Expand converts a map of dotted keys back into a document tree, it is the reverse operation of Flatten
+func Expand(flat map[string]any) map[string]any {
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	root := make(map[string]any)
+	for _, key := range keys {
+		path := splitKey(key)
+		if len(path) == 0 {
+			continue
+		}
+		root = assign(root, path, flat[key]).(map[string]any)
+	}
+	return compact(root).(map[string]any)
+}
+
+// keySegment is either a map key or, when index is positive, a list index
+type keySegment struct {
+	name  string
+	index int
+}
+
+// splitKey splits a flattened key into its segments i.e a.b[0].c becomes [a b [0] c]
+func splitKey(key string) []keySegment {
+	var segments []keySegment
+	for _, part := range strings.Split(key, ".") {
+		name := part
+		var indexes []int
+		for strings.HasSuffix(name, "]") {
+			open := strings.LastIndex(name, "[")
+			if open < 0 {
+				break
+			}
+			index, err := strconv.Atoi(name[open+1 : len(name)-1])
+			if err != nil || index < 0 {
+				break
+			}
+			indexes = append([]int{index}, indexes...)
+			name = name[:open]
+		}
+		if len(name) > 0 || len(indexes) == 0 {
+			segments = append(segments, keySegment{name: name, index: -1})
+		}
+		for _, index := range indexes {
+			segments = append(segments, keySegment{index: index})
+		}
+	}
+	return segments
+}
+
+// assign sets the value at the provided path within the node, creating the intermediate maps and lists.
+// Lists are built as map[int]any and converted to slices by compact once all the keys are assigned
+func assign(node any, path []keySegment, value any) any {
+	if len(path) == 0 {
+		return value
+	}
+
+	segment := path[0]
+	if segment.index < 0 {
+		m, ok := node.(map[string]any)
+		if !ok {
+			m = make(map[string]any)
+		}
+		m[segment.name] = assign(m[segment.name], path[1:], value)
+		return m
+	}
+
+	list, ok := node.(map[int]any)
+	if !ok {
+		list = make(map[int]any)
+	}
+	list[segment.index] = assign(list[segment.index], path[1:], value)
+	return list
+}
+
+// compact converts the map[int]any lists built by assign into slices
+func compact(node any) any {
+	switch v := node.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = compact(item)
+		}
+		return v
+	case map[int]any:
+		size := 0
+		for index := range v {
+			size = max(size, index+1)
+		}
+		list := make([]any, size)
+		for index, item := range v {
+			list[index] = compact(item)
+		}
+		return list
+	default:
+		return v
+	}
+}
@@ -0,0 +1,54 @@
+package document
+
+// Merge deep merges the overlay document into the base document and returns the result : maps are merged recursively
+// while scalars and lists are replaced by the overlay's values. Neither base nor overlay are modified
+func Merge(base map[string]any, overlay map[string]any) map[string]any {
+	merged := make(map[string]any, len(base)+len(overlay))
+	for key, value := range base {
+		merged[key] = value
+	}
+
+	for key, value := range overlay {
+		overlayMap, isOverlayMap := value.(map[string]any)
+		baseMap, isBaseMap := merged[key].(map[string]any)
+		if isOverlayMap && isBaseMap {
+			merged[key] = Merge(baseMap, overlayMap)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// MapStrings replaces in place all the string values found in the document tree by the result of the provided function
+func MapStrings(tree map[string]any, fn func(string) (string, error)) error {
+	_, err := mapStrings(tree, fn)
+	return err
+}
+
+func mapStrings(value any, fn func(string) (string, error)) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return fn(v)
+	case map[string]any:
+		for key, item := range v {
+			mapped, err := mapStrings(item, fn)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = mapped
+		}
+		return v, nil
+	case []any:
+		for i, item := range v {
+			mapped, err := mapStrings(item, fn)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = mapped
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
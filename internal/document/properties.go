@@ -0,0 +1,187 @@
+package document
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// parseProperties decodes a java .properties document into a flat map of string values.
+// Comments, line continuations, the = : and whitespace separators and the java escape sequences are supported
+func parseProperties(content []byte) (map[string]any, error) {
+	flat := make(map[string]any)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	logical := ""
+	for scanner.Scan() {
+		line := strings.TrimLeft(scanner.Text(), " \t\f")
+		if len(logical) == 0 && (len(line) == 0 || line[0] == '#' || line[0] == '!') {
+			continue
+		}
+
+		if continued(line) {
+			logical += line[:len(line)-1]
+			continue
+		}
+		logical += line
+
+		if err := addProperty(flat, logical); err != nil {
+			return nil, err
+		}
+		logical = ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(logical) > 0 {
+		if err := addProperty(flat, logical); err != nil {
+			return nil, err
+		}
+	}
+	return flat, nil
+}
+
+// addProperty parses a logical line and adds the resulting key and value to the provided map
+func addProperty(flat map[string]any, logical string) error {
+	key, value := splitProperty(logical)
+	unescapedKey, err := unescapeProperty(key)
+	if err != nil {
+		return err
+	}
+	unescapedValue, err := unescapeProperty(value)
+	if err != nil {
+		return err
+	}
+	flat[unescapedKey] = unescapedValue
+	return nil
+}
+
+// continued returns true if the line ends with an odd number of backslashes
+func continued(line string) bool {
+	count := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		count++
+	}
+	return count%2 == 1
+}
+
+// splitProperty splits a logical line on the first unescaped separator
+func splitProperty(line string) (string, string) {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '=', ':':
+			return line[:i], strings.TrimLeft(line[i+1:], " \t\f")
+		case ' ', '\t', '\f':
+			value := strings.TrimLeft(line[i:], " \t\f")
+			if len(value) > 0 && (value[0] == '=' || value[0] == ':') {
+				value = strings.TrimLeft(value[1:], " \t\f")
+			}
+			return line[:i], value
+		}
+	}
+	return line, ""
+}
+
+func unescapeProperty(value string) (string, error) {
+	if !strings.Contains(value, "\\") {
+		return value, nil
+	}
+
+	builder := strings.Builder{}
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i == len(value)-1 {
+			builder.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 't':
+			builder.WriteByte('\t')
+		case 'n':
+			builder.WriteByte('\n')
+		case 'r':
+			builder.WriteByte('\r')
+		case 'f':
+			builder.WriteByte('\f')
+		case 'u':
+			if i+5 > len(value) {
+				return "", fmt.Errorf("malformed \\uxxxx encoding in '%s'", value)
+			}
+			r, err := strconv.ParseUint(value[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("malformed \\uxxxx encoding in '%s'", value)
+			}
+			builder.WriteRune(rune(r))
+			i += 4
+		default:
+			builder.WriteByte(value[i])
+		}
+	}
+	return builder.String(), nil
+}
+
+// marshalProperties renders a flat map as a java .properties document with sorted keys
+func marshalProperties(flat map[string]any) []byte {
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buffer := &bytes.Buffer{}
+	for _, key := range keys {
+		buffer.WriteString(escapeProperty(key, true))
+		buffer.WriteByte('=')
+		buffer.WriteString(escapeProperty(scalar(flat[key]), false))
+		buffer.WriteByte('\n')
+	}
+	return buffer.Bytes()
+}
+
+// scalar renders a flattened value as a string
+func scalar(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]any, []any:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func escapeProperty(value string, isKey bool) string {
+	builder := strings.Builder{}
+	for i, r := range value {
+		switch {
+		case r == '\\':
+			builder.WriteString("\\\\")
+		case r == '\n':
+			builder.WriteString("\\n")
+		case r == '\r':
+			builder.WriteString("\\r")
+		case r == '\t':
+			builder.WriteString("\\t")
+		case r == '\f':
+			builder.WriteString("\\f")
+		case r == '=' || r == ':' || r == '#' || r == '!':
+			if isKey || i == 0 {
+				builder.WriteByte('\\')
+			}
+			builder.WriteRune(r)
+		case r == ' ' && (isKey || i == 0):
+			builder.WriteString("\\ ")
+		case r < 0x20:
+			builder.WriteString(fmt.Sprintf("\\u%04x", r))
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
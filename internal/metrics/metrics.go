@@ -0,0 +1,261 @@
+// Package metrics implements the metric types exposed by ConfigServer and their Prometheus text exposition format
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContentType is the media type of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultDurationBuckets are the upper bounds, in seconds, of the buckets used for request durations
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Collector is implemented by the metric families which can be registered and exposed
+type Collector interface {
+	write(w io.Writer)
+}
+
+// Registry groups the metric families exposed together
+type Registry struct {
+	mutex      sync.Mutex
+	collectors []Collector
+}
+
+// Default is the registry holding the metrics of the application
+var Default = NewRegistry()
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds the provided families to the registry, families are exposed in registration order
+func (r *Registry) Register(collectors ...Collector) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.collectors = append(r.collectors, collectors...)
+}
+
+// Write renders all the registered families in the Prometheus text exposition format
+func (r *Registry) Write(w io.Writer) {
+	r.mutex.Lock()
+	collectors := slices.Clone(r.collectors)
+	r.mutex.Unlock()
+
+	for _, collector := range collectors {
+		collector.write(w)
+	}
+}
+
+// family holds the description shared by all the series of a metric
+type family struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+// header writes the HELP and TYPE lines of the family
+func (f *family) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, strings.ReplaceAll(f.help, "\n", " "), f.name, f.kind)
+}
+
+// sample writes a single sample of the family, extra label name and value pairs are appended to the family labels
+func (f *family) sample(w io.Writer, suffix string, labelValues []string, value float64, extra ...string) {
+	var sb strings.Builder
+	sb.WriteString(f.name)
+	sb.WriteString(suffix)
+	names, values := slices.Clone(f.labels), slices.Clone(labelValues)
+	for i := 0; i+1 < len(extra); i += 2 {
+		names, values = append(names, extra[i]), append(values, extra[i+1])
+	}
+	if len(names) > 0 {
+		sb.WriteByte('{')
+		for i, name := range names {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			fmt.Fprintf(&sb, "%s=\"%s\"", name, escapeLabelValue(values[i]))
+		}
+		sb.WriteByte('}')
+	}
+	fmt.Fprintf(w, "%s %s\n", sb.String(), formatValue(value))
+}
+
+// key identifies a series by its label values
+func (f *family) key(labelValues []string) string {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("'%s' expects %d label values, got %d", f.name, len(f.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// escapeLabelValue escapes the backslashes, double quotes and line feeds of label values
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// formatValue renders a sample value
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// sortedKeys returns the keys of the provided series map sorted so that the output is stable
+func sortedKeys[T any](series map[string]T) []string {
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// CounterVec is a family of monotonically increasing counters partitioned by labels
+type CounterVec struct {
+	family
+	mutex  sync.Mutex
+	series map[string]*counter
+}
+
+type counter struct {
+	labelValues []string
+	value       float64
+}
+
+// NewCounterVec creates a counter family, counter names should end with _total
+func NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	return &CounterVec{family: family{name: name, help: help, kind: "counter", labels: labels}, series: make(map[string]*counter)}
+}
+
+// Inc increments the counter designated by the provided label values
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increases the counter designated by the provided label values
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	key := c.key(labelValues)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &counter{labelValues: slices.Clone(labelValues)}
+		c.series[key] = s
+	}
+	s.value += value
+}
+
+// Value returns the value of the counter designated by the provided label values
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	key := c.key(labelValues)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if s, ok := c.series[key]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.header(w)
+	for _, key := range sortedKeys(c.series) {
+		c.sample(w, "", c.series[key].labelValues, c.series[key].value)
+	}
+}
+
+// HistogramVec is a family of histograms partitioned by labels, observations are counted in cumulative buckets
+type HistogramVec struct {
+	family
+	buckets []float64
+	mutex   sync.Mutex
+	series  map[string]*histogram
+}
+
+type histogram struct {
+	labelValues []string
+	counts      []uint64 // observations per bucket, the last one counting the observations above the largest bound
+	sum         float64
+}
+
+// NewHistogramVec creates a histogram family using the provided bucket upper bounds
+func NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	bounds := slices.Clone(buckets)
+	slices.Sort(bounds)
+	return &HistogramVec{family: family{name: name, help: help, kind: "histogram", labels: labels}, buckets: bounds, series: make(map[string]*histogram)}
+}
+
+// Observe records a value in the histogram designated by the provided label values
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{labelValues: slices.Clone(labelValues), counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = s
+	}
+	i, _ := slices.BinarySearch(h.buckets, value)
+	s.counts[i]++
+	s.sum += value
+}
+
+// ObserveDuration records the time elapsed since start, in seconds
+func (h *HistogramVec) ObserveDuration(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.header(w)
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		cumulative := uint64(0)
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			h.sample(w, "_bucket", s.labelValues, float64(cumulative), "le", formatValue(bound))
+		}
+		cumulative += s.counts[len(h.buckets)]
+		h.sample(w, "_bucket", s.labelValues, float64(cumulative), "le", "+Inf")
+		h.sample(w, "_sum", s.labelValues, s.sum)
+		h.sample(w, "_count", s.labelValues, float64(cumulative))
+	}
+}
+
+// GaugeFunc is a family of gauges whose values are computed when the metrics are exposed
+type GaugeFunc struct {
+	family
+	collect func(emit func(value float64, labelValues ...string))
+}
+
+// NewGaugeFunc creates a gauge family, collect is called each time the metrics are exposed and must emit the value of
+// each series
+func NewGaugeFunc(name string, help string, labels []string, collect func(emit func(value float64, labelValues ...string))) *GaugeFunc {
+	return &GaugeFunc{family: family{name: name, help: help, kind: "gauge", labels: labels}, collect: collect}
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	g.header(w)
+	g.collect(func(value float64, labelValues ...string) {
+		g.key(labelValues)
+		g.sample(w, "", labelValues, value)
+	})
+}
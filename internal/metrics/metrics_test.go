@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounterVec(t *testing.T) {
+	counter := NewCounterVec("test_requests_total", "Requests served", "route", "status")
+	counter.Inc("/git", "200")
+	counter.Add(2, "/git", "200")
+	counter.Inc("/stats", "500")
+
+	var sb strings.Builder
+	counter.write(&sb)
+	assert.Equal(t, `# HELP test_requests_total Requests served
+# TYPE test_requests_total counter
+test_requests_total{route="/git",status="200"} 3
+test_requests_total{route="/stats",status="500"} 1
+`, sb.String())
+	assert.Equal(t, float64(3), counter.Value("/git", "200"))
+	assert.Equal(t, float64(0), counter.Value("/git", "404"))
+}
+
+func TestCounterVecRequiresLabelValues(t *testing.T) {
+	counter := NewCounterVec("test_requests_total", "Requests served", "route")
+	assert.Panics(t, func() { counter.Inc() })
+}
+
+func TestHistogramVec(t *testing.T) {
+	histogram := NewHistogramVec("test_duration_seconds", "Durations", []float64{1, 0.1}, "route")
+	histogram.Observe(0.05, "/git")
+	histogram.Observe(0.1, "/git")
+	histogram.Observe(0.5, "/git")
+	histogram.Observe(3, "/git")
+
+	var sb strings.Builder
+	histogram.write(&sb)
+	assert.Equal(t, `# HELP test_duration_seconds Durations
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/git",le="0.1"} 2
+test_duration_seconds_bucket{route="/git",le="1"} 3
+test_duration_seconds_bucket{route="/git",le="+Inf"} 4
+test_duration_seconds_sum{route="/git"} 3.65
+test_duration_seconds_count{route="/git"} 4
+`, sb.String())
+}
+
+func TestGaugeFunc(t *testing.T) {
+	gauge := NewGaugeFunc("test_up", "Up", []string{"name"}, func(emit func(float64, ...string)) {
+		emit(1, `quoted "name"`)
+	})
+
+	var sb strings.Builder
+	registry := NewRegistry()
+	registry.Register(gauge)
+	registry.Write(&sb)
+	assert.Equal(t, `# HELP test_up Up
+# TYPE test_up gauge
+test_up{name="quoted \"name\""} 1
+`, sb.String())
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ClientActivity is the latest access of a client to a repository file
+type ClientActivity struct {
+	ClientID   string    `json:"clientId"`
+	Repository string    `json:"repository"`
+	Path       string    `json:"path"`
+	Commit     string    `json:"commit"` // commit the file was served from
+	SeenAt     time.Time `json:"seenAt"`
+}
+
+// activityKey identifies the files accessed by the clients
+type activityKey struct {
+	clientID   string
+	repository string
+	path       string
+}
+
+// activityRetention is the duration after which the access of a client to a file is forgotten
+const activityRetention = 90 * 24 * time.Hour
+
+// maxActivityEntries bounds the size of the last seen table, the oldest accesses are forgotten first
+const maxActivityEntries = 10000
+
+// activityTracker maintains the last seen table of the clients, it is safe for concurrent use
+type activityTracker struct {
+	mutex     sync.Mutex
+	activity  map[activityKey]*ClientActivity
+	retention time.Duration // accesses older than the retention are forgotten
+	capacity  int           // maximum number of accesses tracked
+}
+
+// newActivityTracker creates an empty last seen table
+func newActivityTracker() *activityTracker {
+	return &activityTracker{activity: make(map[activityKey]*ClientActivity), retention: activityRetention, capacity: maxActivityEntries}
+}
+
+// seen records the access of a client to a file, older and expired accesses are ignored
+func (t *activityTracker) seen(activity *ClientActivity) {
+	if time.Since(activity.SeenAt) > t.retention {
+		return
+	}
+	key := activityKey{activity.ClientID, activity.Repository, activity.Path}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	previous, ok := t.activity[key]
+	if ok && !previous.SeenAt.Before(activity.SeenAt) {
+		return
+	}
+	t.activity[key] = activity
+	if !ok && len(t.activity) > t.capacity {
+		t.evict(time.Now())
+	}
+}
+
+// prune forgets the expired accesses and returns true if any was forgotten
+func (t *activityTracker) prune() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	size := len(t.activity)
+	t.evict(time.Now())
+	return len(t.activity) < size
+}
+
+// evict forgets the expired accesses and, if the table is still over capacity, the oldest accesses so that a tenth of
+// the capacity is freed. Must be called with the mutex held
+func (t *activityTracker) evict(now time.Time) {
+	for key, activity := range t.activity {
+		if now.Sub(activity.SeenAt) > t.retention {
+			delete(t.activity, key)
+		}
+	}
+	if len(t.activity) <= t.capacity {
+		return
+	}
+
+	keys := make([]activityKey, 0, len(t.activity))
+	for key := range t.activity {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b activityKey) int { return t.activity[a].SeenAt.Compare(t.activity[b].SeenAt) })
+	for _, key := range keys[:len(keys)-t.capacity*9/10] {
+		delete(t.activity, key)
+	}
+}
+
+// of returns the activity of a client, or of all the clients if clientID is empty, most recent first
+func (t *activityTracker) of(clientID string) []*ClientActivity {
+	t.mutex.Lock()
+	activities := make([]*ClientActivity, 0)
+	for key, activity := range t.activity {
+		if len(clientID) == 0 || key.clientID == clientID {
+			copied := *activity
+			activities = append(activities, &copied)
+		}
+	}
+	t.mutex.Unlock()
+
+	slices.SortFunc(activities, func(a, b *ClientActivity) int {
+		return cmp.Or(b.SeenAt.Compare(a.SeenAt), cmp.Compare(a.Repository, b.Repository), cmp.Compare(a.Path, b.Path))
+	})
+	return activities
+}
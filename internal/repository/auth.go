@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+const defaultGitUser = "git"
+
+// authMethod builds the go-git authentication method matching the repository's configured credentials.
+// A nil method is returned for public repositories.
+// Secrets are resolved on every call so that credentials mounted from files or environment variables can be rotated
+func authMethod(repository *configuration.Repository) (transport.AuthMethod, error) {
+	auth := repository.Auth
+	if auth == nil {
+		if len(repository.Token) == 0 {
+			return nil, nil
+		}
+		auth = &configuration.Authentication{Password: repository.Token}
+	}
+
+	username := auth.Username
+	if len(username) == 0 {
+		username = defaultGitUser
+	}
+
+	switch {
+	case len(auth.SSHKey) > 0:
+		key, err := configuration.ResolveSecret(auth.SSHKey)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' : cannot read ssh key : %w", repository.Name, err)
+		}
+		passphrase, err := configuration.ResolveSecret(auth.SSHKeyPassphrase)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' : cannot read ssh key passphrase : %w", repository.Name, err)
+		}
+		keys, err := ssh.NewPublicKeys(username, []byte(key), passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' : invalid ssh key : %w", repository.Name, err)
+		}
+		keys.HostKeyCallback, err = ssh.NewKnownHostsCallback(auth.KnownHosts...)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' : cannot load known hosts : %w", repository.Name, err)
+		}
+		return keys, nil
+	case len(auth.BearerToken) > 0:
+		token, err := configuration.ResolveSecret(auth.BearerToken)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' : cannot read bearer token : %w", repository.Name, err)
+		}
+		return &http.TokenAuth{Token: token}, nil
+	case len(auth.Password) > 0:
+		password, err := configuration.ResolveSecret(auth.Password)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' : cannot read password : %w", repository.Name, err)
+		}
+		return &http.BasicAuth{Username: username, Password: password}, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/stretchr/testify/assert"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestPublicRepositoryAuth(t *testing.T) {
+	auth, err := authMethod(&configuration.Repository{Name: "public"})
+	assert.NoError(t, err)
+	assert.Nil(t, auth)
+}
+
+func TestTokenAuth(t *testing.T) {
+	auth, err := authMethod(&configuration.Repository{Name: "token", Token: "my-token"})
+	assert.NoError(t, err)
+	assert.Equal(t, &http.BasicAuth{Username: defaultGitUser, Password: "my-token"}, auth)
+}
+
+func TestBearerTokenFromEnvironment(t *testing.T) {
+	t.Setenv("CONFIGSERVER_TEST_TOKEN", "bearer-token")
+	auth, err := authMethod(&configuration.Repository{Name: "bearer", Auth: &configuration.Authentication{BearerToken: "env:CONFIGSERVER_TEST_TOKEN"}})
+	assert.NoError(t, err)
+	assert.Equal(t, &http.TokenAuth{Token: "bearer-token"}, auth)
+}
+
+func TestMissingSecret(t *testing.T) {
+	_, err := authMethod(&configuration.Repository{Name: "missing", Auth: &configuration.Authentication{Password: "env:CONFIGSERVER_UNDEFINED_VARIABLE"}})
+	assert.Error(t, err)
+}
+
+func TestSSHKeyFromFile(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	block, err := gossh.MarshalPrivateKeyWithPassphrase(priv, "", []byte("key passphrase"))
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "id_ed25519")
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600))
+
+	sshPub, _ := gossh.NewPublicKey(pub)
+	knownHosts := filepath.Join(dir, "known_hosts")
+	assert.NoError(t, os.WriteFile(knownHosts, []byte("github.com "+string(gossh.MarshalAuthorizedKey(sshPub))), 0600))
+
+	auth, err := authMethod(&configuration.Repository{Name: "ssh", Auth: &configuration.Authentication{
+		SSHKey:           "file:" + keyFile,
+		SSHKeyPassphrase: "key passphrase",
+		KnownHosts:       []string{knownHosts},
+	}})
+	assert.NoError(t, err)
+
+	keys, ok := auth.(*ssh.PublicKeys)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, defaultGitUser, keys.User)
+	assert.NotNil(t, keys.HostKeyCallback)
+}
+
+func TestSSHKeyWrongPassphrase(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	block, _ := gossh.MarshalPrivateKeyWithPassphrase(priv, "", []byte("key passphrase"))
+	t.Setenv("CONFIGSERVER_TEST_KEY", string(pem.EncodeToMemory(block)))
+
+	_, err := authMethod(&configuration.Repository{Name: "ssh", Auth: &configuration.Authentication{
+		SSHKey:           "env:CONFIGSERVER_TEST_KEY",
+		SSHKeyPassphrase: "wrong passphrase",
+	}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+)
+
+// Backend is the storage holding the files served for a repository. Backends keep their content up to date in
+// background once watched and report each update attempt via the heartbeat channel they were created with
+type Backend interface {
+	// Watch starts the background process keeping the backend content up to date, the process runs until the provided
+	// context is cancelled or Stop is called
+	Watch(ctx context.Context)
+	// Refresh requests an update without waiting for the next scheduled one, Refresh never blocks
+	Refresh()
+	// Reconfigure applies an updated configuration, the changes are effective from the next update which is
+	// immediately requested
+	Reconfigure(repository *configuration.Repository)
+	// Stop terminates the background process, aborting the update in progress if any. The content served so far
+	// remains readable
+	Stop()
+	// Done returns a channel which is closed once the background process terminated
+	Done() <-chan struct{}
+	// Discard deletes the local copy of the content if the backend maintains one, the backend must be stopped and no
+	// longer served
+	Discard() error
+	// Available returns true if the backend content can be served
+	Available() bool
+	// Revision returns the version of the served content or an empty string if the backend is not available
+	Revision() string
+	// FileAt reads a file as it exists at the provided reference, or in the served revision if no reference is provided
+	FileAt(ref string, path string) (*File, error)
+	// List returns the entries of a directory as it exists at the provided reference, or in the served revision if no
+	// reference is provided
+	List(ref string, dir string) ([]*Entry, error)
+	// History returns the commits which modified a file, most recent first
+	History(ref string, path string, limit int) ([]*CommitRef, error)
+	// Hash returns the git blob hash of a file in the provided revision or an empty string if the file does not exist
+	Hash(revision string, path string) string
+}
+
+// NewBackend creates the backend matching the repository configuration
+func NewBackend(checkoutLocation string, repository *configuration.Repository, heartbeat chan UpdateEvent) (Backend, error) {
+	switch repository.Backend {
+	case "", configuration.BackendGit:
+		return NewBeholder(checkoutLocation, repository, heartbeat), nil
+	case configuration.BackendLocal:
+		return NewLocalBackend(repository, heartbeat)
+	default:
+		return nil, fmt.Errorf("'%s' : unsupported backend '%s'", repository.Name, repository.Backend)
+	}
+}
+
+// scheduler holds the state shared by the backends update loops
+type scheduler struct {
+	configuration atomic.Pointer[configuration.Repository] // The current configured repository, swapped on reload
+	heartbeat     chan UpdateEvent                         // Uplink to the backend's initiator
+	refresh       chan struct{}                            // Used to wake up the backend before its next scheduled update
+	stop          chan struct{}                            // Closed to terminate the update loop
+	stopOnce      sync.Once
+	done          chan struct{} // Closed once the update loop terminated
+}
+
+// init prepares the scheduler for the provided configuration
+func (s *scheduler) init(repository *configuration.Repository, heartbeat chan UpdateEvent) {
+	s.configuration.Store(repository)
+	s.heartbeat = heartbeat
+	s.refresh = make(chan struct{}, 1)
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+}
+
+// config returns the configuration currently in effect
+func (s *scheduler) config() *configuration.Repository {
+	return s.configuration.Load()
+}
+
+// Refresh wakes up the backend so that it is updated without waiting for the next scheduled update.
+// Refresh never blocks, requests issued while a refresh is already pending are coalesced
+func (s *scheduler) Refresh() {
+	select {
+	case s.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// Reconfigure swaps the configuration in effect and requests an update so that the changes are applied immediately
+func (s *scheduler) Reconfigure(repository *configuration.Repository) {
+	s.configuration.Store(repository)
+	s.Refresh()
+}
+
+// Stop terminates the update loop, the update in progress if any is aborted
+func (s *scheduler) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// Done returns a channel which is closed once the update loop terminated
+func (s *scheduler) Done() <-chan struct{} {
+	return s.done
+}
+
+// run executes the update loop shared by the backends : update is called every refresh interval, defaultInterval being
+// used when the repository does not configure one, or following the repository retry policy when failing.
+// The outcome of each attempt is broadcast via the heartbeat channel.
+// The loop, and the update in progress, are terminated when the context is cancelled or Stop is called
+func (s *scheduler) run(ctx context.Context, backend Backend, defaultInterval time.Duration, update func(ctx context.Context) error) {
+	defer close(s.done)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-s.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	failures := 0
+	for {
+		repository := s.config()
+		last := time.Now()
+		err := update(ctx)
+		if ctx.Err() != nil {
+			slog.Info(fmt.Sprintf("'%s' is no longer watched", repository.Name), logKeyRepositoryName, repository.Name)
+			return
+		}
+
+		next := time.Duration(repository.RefreshIntervalSeconds) * time.Second
+		if next <= 0 {
+			next = defaultInterval
+		}
+		state := StateHealthy
+		if err != nil {
+			failures++
+			next = backoff(repository.Retry, failures)
+			state = StateUnavailable
+			if backend.Available() {
+				state = StateDegraded
+			}
+			slog.Error(fmt.Sprintf("'%s' cannot be updated, next attempt will occur @ %s", repository.Name, time.Now().Add(next)), slog.Any("error", err), slog.Int("repository.failures", failures), logKeyRepositoryName, repository.Name, logKeyRepositoryURL, repository.URL)
+		} else {
+			failures = 0
+			slog.Info(fmt.Sprintf("'%s' next update will occur @ %s", repository.Name, time.Now().Add(next)), logKeyRepositoryName, repository.Name, logKeyRepositoryURL, repository.URL)
+		}
+
+		select {
+		case s.heartbeat <- UpdateEvent{
+			RepositoryName:      repository.Name,
+			LastUpdate:          last,
+			NextUpdate:          time.Now().Add(next),
+			LastError:           err,
+			State:               state,
+			ConsecutiveFailures: failures,
+			Revision:            backend.Revision(),
+			RejectedRevision:    rejectedRevision(err),
+			backend:             backend,
+		}:
+		case <-ctx.Done():
+		}
+
+		select {
+		case <-time.After(next):
+		case <-s.refresh:
+			slog.Info(fmt.Sprintf("'%s' refresh requested ahead of schedule", repository.Name), logKeyRepositoryName, repository.Name)
+		case <-ctx.Done():
+			slog.Info(fmt.Sprintf("'%s' is no longer watched", repository.Name), logKeyRepositoryName, repository.Name)
+			return
+		}
+	}
+}
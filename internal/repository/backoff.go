@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+)
+
+// backoff computes the delay to wait before retrying an update which failed the given number of consecutive times.
+// Unset policy values fall back to configuration.DefaultRetryPolicy
+func backoff(policy *configuration.RetryPolicy, failures int) time.Duration {
+	if policy == nil {
+		policy = configuration.DefaultRetryPolicy
+	}
+
+	initial := float64(policy.InitialDelaySeconds)
+	if initial <= 0 {
+		initial = float64(configuration.DefaultRetryPolicy.InitialDelaySeconds)
+	}
+	maximum := float64(policy.MaxDelaySeconds)
+	if maximum <= 0 {
+		maximum = float64(configuration.DefaultRetryPolicy.MaxDelaySeconds)
+	}
+	multiplier := policy.Multiplier
+	if multiplier < 1 {
+		multiplier = configuration.DefaultRetryPolicy.Multiplier
+	}
+	jitter := math.Min(math.Max(policy.Jitter, 0), 1)
+
+	delay := math.Min(initial*math.Pow(multiplier, float64(max(failures-1, 0))), maximum)
+	delay += delay * jitter * (2*rand.Float64() - 1)
+
+	return time.Duration(math.Min(delay, maximum) * float64(time.Second))
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoffGrowsExponentially(t *testing.T) {
+	policy := &configuration.RetryPolicy{InitialDelaySeconds: 1, MaxDelaySeconds: 60, Multiplier: 2}
+	assert.Equal(t, 1*time.Second, backoff(policy, 1))
+	assert.Equal(t, 2*time.Second, backoff(policy, 2))
+	assert.Equal(t, 4*time.Second, backoff(policy, 3))
+}
+
+func TestBackoffIsCapped(t *testing.T) {
+	policy := &configuration.RetryPolicy{InitialDelaySeconds: 1, MaxDelaySeconds: 60, Multiplier: 2}
+	assert.Equal(t, 60*time.Second, backoff(policy, 1000))
+
+	policy.Jitter = 0.5
+	assert.LessOrEqual(t, backoff(policy, 10), 60*time.Second)
+}
+
+func TestBackoffJitter(t *testing.T) {
+	policy := &configuration.RetryPolicy{InitialDelaySeconds: 10, MaxDelaySeconds: 60, Multiplier: 2, Jitter: 0.2}
+	for i := 0; i < 100; i++ {
+		delay := backoff(policy, 1)
+		assert.GreaterOrEqual(t, delay, 8*time.Second)
+		assert.LessOrEqual(t, delay, 12*time.Second)
+	}
+}
+
+func TestBackoffDefaultPolicy(t *testing.T) {
+	delay := backoff(nil, 1)
+	assert.GreaterOrEqual(t, delay, 4*time.Second)
+	assert.LessOrEqual(t, delay, 6*time.Second)
+}
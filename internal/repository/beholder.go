@@ -1,150 +1,524 @@
 package repository
 
-import "C"
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"path"
 	"path/filepath"
-	"sync"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/tracing"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/google/uuid"
 )
 
+// Beholder is the git Backend.
 // Beholder are responsible for maintaining local copies of git repositories up to date based on the provided configuration
 // As they are running in background they make use of a heartbeat channel towards their initiator to communicate about
 // repositories update event
 type Beholder struct {
-	configuration    *configuration.Repository // The current configured repository
-	checkoutLocation string                    // Place where the repositories are checked out
-	Active           bool                      // true if the repository is actively monitored and can be used
-	heartbeat        chan UpdateEvent          // Uplink to the beholder's initiator
-	mutex            *sync.RWMutex             // Used to ensure no read operation is allowed while the repository is being updated
+	scheduler
+	checkoutLocation string                   // Place where the repositories are checked out
+	snapshot         atomic.Pointer[snapshot] // Revision currently served, nil until the repository is checked out
 }
 
 // NewBeholder initiates a new beholder for the provided configuration
 // a call to Watch() is mandatory to start the beholder process
 func NewBeholder(checkoutLocation string, configuration *configuration.Repository, heartbeat chan UpdateEvent) *Beholder {
 	uid := uuid.New()
-	return &Beholder{configuration, filepath.Join(checkoutLocation, uid.String()), true, heartbeat, &sync.RWMutex{}}
+	beholder := &Beholder{checkoutLocation: filepath.Join(checkoutLocation, uid.String())}
+	beholder.init(configuration, heartbeat)
+	return beholder
+}
+
+// Discard deletes the local copy of the repository
+func (w *Beholder) Discard() error {
+	return os.RemoveAll(w.checkoutLocation)
+}
+
+// Available returns true if a local copy of the repository can be served.
+// A repository stays available when its updates are failing, in which case the last successfully checked out copy is served
+func (w *Beholder) Available() bool {
+	return w.snapshot.Load() != nil
+}
+
+// Revision returns the commit hash of the currently served snapshot or an empty string if the repository is not available
+func (w *Beholder) Revision() string {
+	current := w.snapshot.Load()
+	if current == nil {
+		return ""
+	}
+	return current.commit.String()
 }
 
 // Watch initiates the creation of a local copy of the configured repository and will periodically update the repository
-// to its latest state
-func (w *Beholder) Watch() {
-	go w.watchInternal()
+// to its latest state until the context is cancelled or Stop is called
+func (w *Beholder) Watch(ctx context.Context) {
+	go w.watchInternal(ctx)
 }
 
 // see Watch
-func (w *Beholder) watchInternal() {
-	var lastError error
+func (w *Beholder) watchInternal(ctx context.Context) {
+	w.run(ctx, w, 0, w.update)
+}
 
-	for {
-		w.mutex.Lock()
-		last := time.Now()
+// update creates or refreshes the local copy of the repository and, once done, swaps the served snapshot for the
+// updated revision. Readers are never blocked by updates as they only access immutable git objects.
+// Network operations are aborted when the context is cancelled
+func (w *Beholder) update(ctx context.Context) (err error) {
+	cfg := w.config()
+	ctx, span := tracing.Start(ctx, "repository.update", tracing.String("repository.name", cfg.Name), tracing.String("repository.url", cfg.URL))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+	slog.Info("cloning repository", logKeyRepositoryName, cfg.Name, logKeyCheckoutLocation, w.checkoutLocation, logKeyRepositoryURL, cfg.URL)
 
-		slog.Info("cloning repository", logKeyRepositoryName, w.configuration.Name, logKeyCheckoutLocation, w.checkoutLocation, logKeyRepositoryURL, w.configuration.URL)
+	if err := os.MkdirAll(w.checkoutLocation, os.ModePerm); err != nil {
+		return fmt.Errorf("cannot create path '%s' to checkout '%s': %w", w.checkoutLocation, cfg.Name, err)
+	}
 
-		if err := os.MkdirAll(w.checkoutLocation, os.ModePerm); err != nil {
-			lastError = fmt.Errorf("cannot create path '%s' to checkout '%s': %w", w.checkoutLocation, w.configuration.Name, err)
-			break
+	auth, err := authMethod(cfg)
+	if err != nil {
+		return err
+	}
+
+	workspace, err := git.PlainOpen(w.checkoutLocation)
+	if err != nil {
+		slog.Info("no local copy found, creating a fresh clone", logKeyRepositoryName, cfg.Name)
+		if workspace, err = w.clone(ctx, cfg, auth); err != nil {
+			return err
 		}
+	}
 
-		workspace, err := git.PlainOpen(w.checkoutLocation)
-		if err != nil {
-			slog.Info("no local copy found, creating a fresh clone", logKeyRepositoryName, w.configuration.Name)
-			workspace, err = git.PlainClone(w.checkoutLocation, false, &git.CloneOptions{
-				URL:      w.configuration.URL,
-				Progress: os.Stdout,
-			})
-
-			if err != nil {
-				lastError = fmt.Errorf("could not clone '%s' to '%s' : %w", w.configuration.URL, w.checkoutLocation, err)
-				break
-			}
+	if err := w.pull(ctx, workspace, cfg, auth); err != nil {
+		return err
+	}
+	if cfg.Submodules {
+		if err := updateSubmodules(ctx, workspace, cfg, auth); err != nil {
+			return err
 		}
+	}
 
-		tree, err := workspace.Worktree()
-		if err != nil {
-			lastError = fmt.Errorf("'%s' : unable to open local copy : %w", w.checkoutLocation, err)
-			break
-		}
-
-		if len(w.configuration.Branch) > 0 {
-			// Fetch remote branches
-			err = workspace.Fetch(&git.FetchOptions{
-				RefSpecs: []config.RefSpec{"refs/*:refs/*", "HEAD:refs/heads/HEAD"},
-			})
-			if err != nil {
-				lastError = fmt.Errorf("'%s' : unable to fetch repository : %w", w.configuration.URL, err)
-				break
+	head, err := workspace.Head()
+	if err != nil {
+		return fmt.Errorf("'%s' : unable to resolve HEAD : %w", cfg.Name, err)
+	}
+	if cfg.TrustedKeys != nil {
+		if err := w.verify(workspace, cfg, head.Hash()); err != nil {
+			return err
+		}
+	}
+
+	if current := w.snapshot.Load(); current == nil || current.commit != head.Hash() {
+		if cfg.LFS {
+			if err := w.fetchLFS(ctx, workspace, cfg, head.Hash()); err != nil {
+				return err
 			}
-			err = tree.Checkout(&git.CheckoutOptions{
-				Branch: plumbing.NewBranchReferenceName(w.configuration.Branch),
-				Force:  true,
-			})
-			if err != nil {
-				lastError = fmt.Errorf("'%s' : unable to checkout branch '%s': %w", w.configuration.URL, w.configuration.Branch, err)
-				break
+		}
+		slog.Info(fmt.Sprintf("'%s' now serving revision %s", cfg.Name, head.Hash()), logKeyRepositoryName, cfg.Name)
+		w.snapshot.Store(&snapshot{commit: head.Hash()})
+	}
+
+	return nil
+}
+
+// verify checks that the revision is signed by one of the repository trusted keys, the last verified revision keeps
+// being served when it is not
+func (w *Beholder) verify(workspace *git.Repository, cfg *configuration.Repository, revision plumbing.Hash) error {
+	commit, err := workspace.CommitObject(revision)
+	if err != nil {
+		return fmt.Errorf("'%s' : unable to read revision %s : %w", cfg.Name, revision, err)
+	}
+	signer, err := verifySignature(commit, cfg.TrustedKeys)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("'%s' refused to serve revision %s", cfg.Name, revision), "error", err, logKeyRepositoryName, cfg.Name, "repository.revision", revision.String())
+		return fmt.Errorf("'%s' : %w", cfg.Name, err)
+	}
+	slog.Debug(fmt.Sprintf("'%s' revision %s is signed by %s", cfg.Name, revision, signer), logKeyRepositoryName, cfg.Name, "repository.revision", revision.String())
+	return nil
+}
+
+// clone creates the local copy of the repository, the operation is recorded in the repository update metrics
+func (w *Beholder) clone(ctx context.Context, cfg *configuration.Repository, auth transport.AuthMethod) (workspace *git.Repository, err error) {
+	defer observeUpdate(ctx, cfg.Name, operationClone, time.Now(), &err)
+	ctx, span := tracing.StartClient(ctx, "git.clone", tracing.String("repository.url", cfg.URL))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	options := &git.CloneOptions{
+		URL:          cfg.URL,
+		Auth:         auth,
+		Progress:     os.Stdout,
+		Depth:        cfg.Depth,
+		SingleBranch: cfg.SingleBranch,
+		// Sparse checkouts, tags and commits are checked out once fetched, see pull
+		NoCheckout: len(cfg.SparseCheckout) > 0 || !cfg.FollowsBranch(),
+	}
+	if cfg.SingleBranch {
+		options.Tags = git.NoTags
+		if len(cfg.Branch) > 0 {
+			options.ReferenceName = plumbing.NewBranchReferenceName(cfg.Branch)
+		}
+	}
+	workspace, err = git.PlainCloneContext(ctx, w.checkoutLocation, false, options)
+	if err != nil {
+		// A partial clone would be mistaken for a local copy by the next attempt
+		_ = os.RemoveAll(w.checkoutLocation)
+		return nil, fmt.Errorf("could not clone '%s' to '%s' : %w", cfg.URL, w.checkoutLocation, err)
+	}
+	return workspace, nil
+}
+
+// pull fetches the remote changes and updates the local copy to the tracked branch, the operation is recorded in the
+// repository update metrics
+func (w *Beholder) pull(ctx context.Context, workspace *git.Repository, cfg *configuration.Repository, auth transport.AuthMethod) (err error) {
+	defer observeUpdate(ctx, cfg.Name, operationPull, time.Now(), &err)
+	ctx, span := tracing.StartClient(ctx, "git.pull", tracing.String("repository.url", cfg.URL), tracing.String("repository.branch", cfg.Branch))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	tree, err := workspace.Worktree()
+	if err != nil {
+		return fmt.Errorf("'%s' : unable to open local copy : %w", w.checkoutLocation, err)
+	}
+
+	tracked, tracking := trackedRefSpec(cfg)
+	// Fetch remote branches and tags so that they can be served even if they are not checked out, unless the
+	// repository is restricted to a single branch
+	options := &git.FetchOptions{RefSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf(config.DefaultFetchRefSpec, git.DefaultRemoteName)), tracked}, Tags: git.AllTags, Depth: cfg.Depth, Auth: auth}
+	switch {
+	case len(cfg.Tag) > 0 && cfg.SingleBranch:
+		options = &git.FetchOptions{Tags: git.NoTags, Depth: cfg.Depth, Auth: auth}
+	case cfg.SingleBranch:
+		options = &git.FetchOptions{RefSpecs: []config.RefSpec{tracked}, Tags: git.NoTags, Depth: cfg.Depth, Auth: auth}
+	case len(cfg.Branch) > 0:
+		options = &git.FetchOptions{RefSpecs: []config.RefSpec{"refs/*:refs/*", "HEAD:refs/heads/HEAD", tracked}, Depth: cfg.Depth, Auth: auth}
+	}
+	if len(cfg.Tag) > 0 {
+		// Tags tracked by the repository are force updated so that tags moved on the remote are served
+		options.RefSpecs = append(options.RefSpecs, tagsRefSpec)
+	}
+	if err := fetch(ctx, workspace, cfg, options); err != nil {
+		return err
+	}
+
+	if !cfg.FollowsBranch() {
+		revision, err := pinnedRevision(workspace, cfg)
+		if errors.Is(err, ErrRefNotFound) && plumbing.IsHash(cfg.Commit) {
+			// The pinned commit is not reachable from the fetched branches and tags or lies beyond the fetched depth
+			if err := fetch(ctx, workspace, cfg, &git.FetchOptions{RefSpecs: []config.RefSpec{pinnedRefSpec(cfg.Commit)}, Tags: git.NoTags, Depth: cfg.Depth, Auth: auth}); err != nil {
+				return err
 			}
+			revision, err = pinnedRevision(workspace, cfg)
+		}
+		if err != nil {
+			return err
+		}
+		if len(cfg.SparseCheckout) > 0 {
+			return w.checkoutSparsely(workspace, cfg, revision)
+		}
+		if err := tree.Checkout(&git.CheckoutOptions{Hash: revision, Force: true}); err != nil {
+			return fmt.Errorf("'%s' : unable to checkout revision %s : %w", cfg.Name, revision, err)
+		}
+		return nil
+	}
+
+	if len(cfg.SparseCheckout) > 0 {
+		ref, err := workspace.Reference(tracking, true)
+		if err != nil {
+			return fmt.Errorf("'%s' : unable to resolve '%s' : %w", cfg.Name, tracking, err)
 		}
+		return w.checkoutSparsely(workspace, cfg, ref.Hash())
+	}
 
-		err = tree.Pull(&git.PullOptions{
-			Force: true,
+	pull := &git.PullOptions{Auth: auth, Force: true, Depth: cfg.Depth, SingleBranch: cfg.SingleBranch}
+	if len(cfg.Branch) > 0 {
+		err = tree.Checkout(&git.CheckoutOptions{
+			Branch: plumbing.NewBranchReferenceName(cfg.Branch),
+			Force:  true,
 		})
+		if err != nil {
+			return fmt.Errorf("'%s' : unable to checkout branch '%s': %w", cfg.URL, cfg.Branch, err)
+		}
+		pull.ReferenceName = plumbing.NewBranchReferenceName(cfg.Branch)
+	}
+
+	err = tree.PullContext(ctx, pull)
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("'%s' : unable to pull latest changes : %w", cfg.Name, err)
+	}
+	return nil
+}
+
+// trackedRefSpec returns the refspec fetching the tracked branch, or the remote default branch when the repository
+// does not configure one, along the remote-tracking reference it is fetched to
+func trackedRefSpec(cfg *configuration.Repository) (config.RefSpec, plumbing.ReferenceName) {
+	source := plumbing.HEAD
+	if len(cfg.Branch) > 0 {
+		source = plumbing.NewBranchReferenceName(cfg.Branch)
+	}
+	tracking := plumbing.NewRemoteReferenceName(git.DefaultRemoteName, source.Short())
+	return config.RefSpec(fmt.Sprintf("+%s:%s", source, tracking)), tracking
+}
+
+// checkoutSparsely updates the local copy to the provided revision, only the sparse checkout directories are written
+// to the working directory. go-git sparse checkouts materialize the whole tree on the first checkout hence the
+// directories are written from the commit tree and HEAD is detached to the revision
+func (w *Beholder) checkoutSparsely(workspace *git.Repository, cfg *configuration.Repository, revision plumbing.Hash) error {
+	commit, err := workspace.CommitObject(revision)
+	if err != nil {
+		return fmt.Errorf("'%s' : unable to read revision %s : %w", cfg.Name, revision, err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return fmt.Errorf("'%s' : unable to read tree of %s : %w", cfg.Name, revision, err)
+	}
 
-		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
-			lastError = fmt.Errorf("'%s' : unable to pull latest changes : %s", w.configuration.Name, err)
-			break
+	for _, dir := range newSparseSet(cfg.SparseCheckout) {
+		target := filepath.Join(w.checkoutLocation, filepath.FromSlash(dir))
+		if err := os.RemoveAll(target); err != nil {
+			return fmt.Errorf("'%s' : unable to clean '%s' : %w", cfg.Name, target, err)
 		}
+		subtree, err := tree.Tree(dir)
+		if errors.Is(err, object.ErrDirectoryNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("'%s' : unable to read '%s' at %s : %w", cfg.Name, dir, revision, err)
+		}
+		if err := materialize(subtree, target); err != nil {
+			return fmt.Errorf("'%s' : unable to checkout '%s' : %w", cfg.Name, dir, err)
+		}
+	}
+
+	return workspace.Storer.SetReference(plumbing.NewHashReference(plumbing.HEAD, revision))
+}
+
+// materialize writes the files of a tree to the provided directory
+func materialize(tree *object.Tree, dir string) error {
+	return tree.Files().ForEach(func(file *object.File) error {
+		target := filepath.Join(dir, filepath.FromSlash(file.Name))
+		if !strings.HasPrefix(target, dir+string(filepath.Separator)) {
+			return fmt.Errorf("'%s' escapes the checkout directory", file.Name)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+			return err
+		}
+
+		content, err := file.Contents()
+		if err != nil {
+			return err
+		}
+		if file.Mode == filemode.Symlink {
+			return os.Symlink(content, target)
+		}
+		mode, err := file.Mode.ToOSFileMode()
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, []byte(content), mode.Perm())
+	})
+}
+
+// fetch downloads the remote objects and references
+func fetch(ctx context.Context, workspace *git.Repository, cfg *configuration.Repository, options *git.FetchOptions) (err error) {
+	ctx, span := tracing.StartClient(ctx, "git.fetch", tracing.String("repository.url", cfg.URL))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	err = workspace.FetchContext(ctx, options)
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("'%s' : unable to fetch repository : %w", cfg.URL, err)
+	}
+	return nil
+}
+
+// File retrieves the requested path from the managed repository as it exists in the currently served snapshot.
+// Reads are never blocked by repository updates, a read started before an update completes against the previous snapshot
+func (w *Beholder) File(filepath string) (*File, error) {
+	return w.FileAt("", filepath)
+}
+
+// FileAt retrieves the requested path as it exists at the provided git reference (branch, tag or commit hash).
+// The file is read from the git object store, the working copy is left untouched. Files of the submodules are read at
+// the revision recorded by the repository and lfs pointer files are replaced by their content when enabled
+func (w *Beholder) FileAt(ref string, filepath string) (*File, error) {
+	cfg := w.config()
+	if !newSparseSet(cfg.SparseCheckout).contains(cleanPath(filepath)) {
+		return nil, errOutsideSparseCheckout(cfg.Name, filepath)
+	}
+
+	workspace, commit, err := w.commit(ref)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := w.locate(workspace, commit, filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := readFile(loc.commit, loc.path)
+	if err != nil {
+		return nil, err
+	}
+	file.Path, file.Revision = cleanPath(filepath), commit.Hash.String()
+	if cfg.LFS {
+		if err := w.resolveLFS(cfg, loc, file); err != nil {
+			return nil, err
+		}
+	}
 
-		nextRefresh := time.Duration(w.configuration.RefreshIntervalSeconds) * time.Second
-		slog.Info(fmt.Sprintf("'%s' next pull will occur @ %s", w.configuration.Name, time.Now().Add(nextRefresh)), logKeyRepositoryName, w.configuration.Name, logKeyCheckoutLocation, w.checkoutLocation, logKeyRepositoryURL, w.configuration.URL)
-		w.broadcast(last, time.Now().Add(nextRefresh), nil)
-		w.mutex.Unlock()
-		time.Sleep(nextRefresh)
+	// History walks are only cached for the served snapshot, other revisions are seldom requested
+	var cache *snapshot
+	if current := w.snapshot.Load(); current != nil && current.commit == commit.Hash && len(loc.submodule) == 0 {
+		cache = current
+	}
+	file.LastModified, err = lastModified(cache, loc.commit, loc.path, shallowBoundary(loc.workspace))
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Hash returns the blob hash of the provided file in the given revision or an empty string if the file does not exist
+func (w *Beholder) Hash(revision string, filepath string) string {
+	if !newSparseSet(w.config().SparseCheckout).contains(cleanPath(filepath)) {
+		return ""
+	}
+
+	workspace, err := w.open()
+	if err != nil {
+		return ""
+	}
+	commit, err := workspace.CommitObject(plumbing.NewHash(revision))
+	if err != nil {
+		return ""
+	}
+	loc, err := w.locate(workspace, commit, filepath)
+	if err != nil {
+		return ""
+	}
+	file, err := loc.commit.File(loc.path)
+	if err != nil {
+		return ""
+	}
+	return file.Hash.String()
+}
+
+// List returns the entries of the requested directory as it exists at the provided git reference or, if no reference
+// is provided, in the currently served snapshot. Entries outside the sparse checkout directories are not listed
+func (w *Beholder) List(ref string, dir string) ([]*Entry, error) {
+	cfg := w.config()
+	sparse := newSparseSet(cfg.SparseCheckout)
+	if !sparse.reaches(cleanPath(dir)) {
+		return nil, errOutsideSparseCheckout(cfg.Name, dir)
 	}
 
-	// If we are here something bad happend
-	slog.Error("Cannot update repository - stopping beholder", slog.Any("error", lastError), logKeyRepositoryName, w.configuration.Name, logKeyCheckoutLocation, w.checkoutLocation, logKeyRepositoryURL, w.configuration.URL)
-	w.broadcast(time.Now(), time.Now(), lastError)
-	w.mutex.Unlock()
-	w.Active = false
+	workspace, commit, err := w.commit(ref)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := w.locate(workspace, commit, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := listDirectory(loc.workspace, loc.commit, loc.path)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.LFS {
+		lfsSizes(loc.workspace, entries)
+	}
+	for _, entry := range entries {
+		entry.Path = path.Join(loc.submodule, entry.Path)
+	}
+	return slices.DeleteFunc(entries, func(entry *Entry) bool {
+		return !sparse.contains(entry.Path) && (entry.Type != EntryDirectory || !sparse.reaches(entry.Path))
+	}), nil
 }
 
-// broadcast issues the provided info via the heartbeat channel
-func (w *Beholder) broadcast(last time.Time, next time.Time, error error) {
-	w.heartbeat <- *&UpdateEvent{
-		LastUpdate:     last,
-		NextUpdate:     next,
-		LastError:      error,
-		RepositoryName: w.configuration.Name,
+// locate resolves the provided path to the repository holding it, following the submodules when they are enabled
+func (w *Beholder) locate(workspace *git.Repository, commit *object.Commit, filepath string) (*location, error) {
+	if !w.config().Submodules {
+		return &location{workspace: workspace, commit: commit, path: cleanPath(filepath)}, nil
 	}
+	return locate(workspace, commit, cleanPath(filepath))
 }
 
-// File retrieves the requested path from the managed repository
-// File will ensure no file can be read if the repository is being updated
-func (w *Beholder) File(filepath string) ([]byte, error) {
-	w.mutex.RLock()
-	defer w.mutex.RUnlock()
+// commit returns the commit designated by the provided reference, or the currently served snapshot commit when no
+// reference is provided, along with the repository handle it was read from
+func (w *Beholder) commit(ref string) (*git.Repository, *object.Commit, error) {
+	current := w.snapshot.Load()
+	if current == nil && len(ref) == 0 {
+		return nil, nil, ErrRepositoryUnavailable
+	}
+
+	workspace, err := w.open()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(ref) == 0 {
+		commit, err := workspace.CommitObject(current.commit)
+		if err != nil {
+			return nil, nil, fmt.Errorf("'%s' : unable to read revision %s : %w", w.config().Name, current.commit, err)
+		}
+		return workspace, commit, nil
+	}
 
-	target := path.Join(w.checkoutLocation, path.Clean(filepath))
-	info, err := os.Stat(target)
-	if err != nil || info.IsDir() {
-		return nil, fmt.Errorf("'%s' is not accessible or is a directory", target)
+	cfg := w.config()
+	commit, err := resolveCommit(workspace, ref)
+	if err != nil {
+		return nil, nil, fmt.Errorf("'%s' cannot be resolved in '%s' : %w", ref, cfg.Name, ErrRefNotFound)
+	}
+	// Revisions other than the served one must be signed as well
+	if cfg.TrustedKeys != nil {
+		if _, err := verifySignature(commit, cfg.TrustedKeys); err != nil {
+			return nil, nil, fmt.Errorf("'%s' cannot be served from '%s' : %w", ref, cfg.Name, err)
+		}
 	}
+	return workspace, commit, nil
+}
 
-	content, err := os.ReadFile(target)
-	if err != nil || info.IsDir() {
-		return nil, fmt.Errorf("an unexpected error occured while reading '%s' : %w", target, err)
+// open provides a fresh handle on the local copy object store.
+// go-git repositories are not safe for concurrent use hence each reader gets its own handle
+func (w *Beholder) open() (*git.Repository, error) {
+	workspace, err := git.PlainOpen(w.checkoutLocation)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' : unable to open local copy : %w", w.checkoutLocation, err)
 	}
+	return workspace, nil
+}
 
-	return content, nil
+// resolveCommit finds the commit designated by the provided reference.
+// Branches which are not checked out locally are looked up amongst the remote branches
+func resolveCommit(workspace *git.Repository, ref string) (*object.Commit, error) {
+	hash, err := workspace.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		hash, err = workspace.ResolveRevision(plumbing.Revision(path.Join(git.DefaultRemoteName, ref)))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return workspace.CommitObject(*hash)
 }
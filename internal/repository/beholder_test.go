@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+)
+
+// testRepository is a local git repository used to exercise beholders without any remote
+type testRepository struct {
+	t         *testing.T
+	dir       string
+	workspace *git.Repository
+}
+
+func newTestRepository(t *testing.T) *testRepository {
+	dir := t.TempDir()
+	workspace, err := git.PlainInit(dir, false)
+	assert.NoError(t, err)
+	return &testRepository{t, dir, workspace}
+}
+
+// commit writes the provided files and commits them, returning the commit hash
+func (r *testRepository) commit(message string, files map[string]string) plumbing.Hash {
+	tree, err := r.workspace.Worktree()
+	assert.NoError(r.t, err)
+
+	for name, content := range files {
+		target := filepath.Join(r.dir, name)
+		assert.NoError(r.t, os.MkdirAll(filepath.Dir(target), os.ModePerm))
+		assert.NoError(r.t, os.WriteFile(target, []byte(content), 0600))
+		_, err = tree.Add(name)
+		assert.NoError(r.t, err)
+	}
+
+	hash, err := tree.Commit(message, &git.CommitOptions{
+		Author: &object.Signature{Name: "configserver", Email: "configserver@localhost", When: time.Now()},
+	})
+	assert.NoError(r.t, err)
+	return hash
+}
+
+// beholder returns a beholder serving the test repository as its local copy
+func (r *testRepository) beholder() *Beholder {
+	b := NewBeholder(r.dir, &configuration.Repository{Name: "test"}, make(chan UpdateEvent, 16))
+	b.checkoutLocation = r.dir
+	return b
+}
+
+func TestFileAtRefs(t *testing.T) {
+	repo := newTestRepository(t)
+	first := repo.commit("first", map[string]string{"config/app.yml": "version: 1"})
+	_, err := repo.workspace.CreateTag("v1.0.0", first, nil)
+	assert.NoError(t, err)
+	second := repo.commit("second", map[string]string{"config/app.yml": "version: 2"})
+
+	b := repo.beholder()
+
+	file, err := b.FileAt("v1.0.0", "config/app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 1", string(file.Content))
+
+	file, err = b.FileAt(first.String()[:8], "/config/app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 1", string(file.Content))
+
+	file, err = b.FileAt(second.String(), "config/app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 2", string(file.Content))
+
+	file, err = b.FileAt("master", "config/app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 2", string(file.Content))
+}
+
+func TestFileAtUnknownRef(t *testing.T) {
+	repo := newTestRepository(t)
+	repo.commit("first", map[string]string{"app.yml": "version: 1"})
+
+	_, err := repo.beholder().FileAt("v9.9.9", "app.yml")
+	assert.ErrorIs(t, err, ErrRefNotFound)
+}
+
+func TestFileAtUnknownFile(t *testing.T) {
+	repo := newTestRepository(t)
+	repo.commit("first", map[string]string{"config/app.yml": "version: 1"})
+
+	b := repo.beholder()
+	_, err := b.FileAt("master", "missing.yml")
+	assert.ErrorIs(t, err, ErrFileNotFound)
+
+	_, err = b.FileAt("master", "config")
+	assert.ErrorIs(t, err, ErrFileNotFound)
+}
+
+func TestFileServesSnapshot(t *testing.T) {
+	repo := newTestRepository(t)
+	first := repo.commit("first", map[string]string{"app.yml": "version: 1"})
+
+	b := repo.beholder()
+	_, err := b.File("app.yml")
+	assert.ErrorIs(t, err, ErrRepositoryUnavailable)
+
+	b.snapshot.Store(&snapshot{commit: first})
+	second := repo.commit("second", map[string]string{"app.yml": "version: 2"})
+
+	file, err := b.File("app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 1", string(file.Content), "updates must not be visible until the snapshot is swapped")
+
+	b.snapshot.Store(&snapshot{commit: second})
+	file, err = b.File("app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 2", string(file.Content))
+}
+
+func TestFileMetadata(t *testing.T) {
+	repo := newTestRepository(t)
+	first := repo.commit("first", map[string]string{"app.yml": "version: 1", "db.yml": "host: localhost"})
+	time.Sleep(time.Second) // commit dates have a one second resolution
+	second := repo.commit("second", map[string]string{"app.yml": "version: 2"})
+
+	b := repo.beholder()
+	b.snapshot.Store(&snapshot{commit: second})
+
+	app, err := b.File("app.yml")
+	assert.NoError(t, err)
+	db, err := b.File("/db.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, second.String(), app.Revision)
+	assert.Equal(t, "db.yml", db.Path)
+	assert.True(t, app.LastModified.After(db.LastModified), "db.yml was last modified by the first commit")
+
+	previous, err := b.FileAt(first.String(), "app.yml")
+	assert.NoError(t, err)
+	assert.NotEqual(t, previous.Hash, app.Hash)
+	unchanged, err := b.FileAt(first.String(), "db.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, unchanged.Hash, db.Hash)
+	assert.Equal(t, unchanged.LastModified, db.LastModified)
+
+	cached, ok := b.snapshot.Load().lastModified.Load("app.yml")
+	assert.True(t, ok)
+	assert.Equal(t, app.LastModified, cached)
+}
+
+func TestFileCannotEscapeRepository(t *testing.T) {
+	repo := newTestRepository(t)
+	b := repo.beholder()
+	b.snapshot.Store(&snapshot{commit: repo.commit("first", map[string]string{"app.yml": "version: 1"})})
+
+	file, err := b.File("../../app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 1", string(file.Content))
+
+	_, err = b.File("../" + filepath.Base(repo.dir) + "/.git/config")
+	assert.ErrorIs(t, err, ErrFileNotFound)
+}
+
+func TestList(t *testing.T) {
+	repo := newTestRepository(t)
+	first := repo.commit("first", map[string]string{"config/app.yml": "version: 1", "config/db.yml": "host: localhost", "README.md": "readme"})
+	second := repo.commit("second", map[string]string{"config/app.yml": "version: 2"})
+
+	b := repo.beholder()
+	b.snapshot.Store(&snapshot{commit: second})
+
+	entries, err := b.List("", "/")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "README.md", entries[0].Name)
+	assert.Equal(t, EntryFile, entries[0].Type)
+	assert.Equal(t, int64(6), entries[0].Size)
+	assert.Equal(t, first.String(), entries[0].LastModified.Hash)
+	assert.Equal(t, "config", entries[1].Name)
+	assert.Equal(t, EntryDirectory, entries[1].Type)
+	assert.Equal(t, second.String(), entries[1].LastModified.Hash)
+
+	entries, err = b.List("", "config/")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "config/app.yml", entries[0].Path)
+	assert.Equal(t, second.String(), entries[0].LastModified.Hash)
+	assert.Equal(t, "config/db.yml", entries[1].Path)
+	assert.Equal(t, first.String(), entries[1].LastModified.Hash)
+
+	entries, err = b.List(first.String(), "config")
+	assert.NoError(t, err)
+	assert.Equal(t, first.String(), entries[0].LastModified.Hash)
+}
+
+func TestListInvalidDirectory(t *testing.T) {
+	repo := newTestRepository(t)
+	b := repo.beholder()
+	b.snapshot.Store(&snapshot{commit: repo.commit("first", map[string]string{"config/app.yml": "version: 1"})})
+
+	_, err := b.List("", "config/app.yml")
+	assert.ErrorIs(t, err, ErrNotADirectory)
+
+	_, err = b.List("", "missing")
+	assert.ErrorIs(t, err, ErrFileNotFound)
+}
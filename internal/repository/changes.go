@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"sync"
+)
+
+// Change describes the state of a watched file after a repository update
+type Change struct {
+	Repository string `json:"repository"`
+	Path       string `json:"path"`
+	Revision   string `json:"revision"` // commit served once the update completed
+	Hash       string `json:"hash"`     // blob hash of the file, empty if the file no longer exists
+}
+
+// Subscription receives the changes of a single file, see Manager.Watch
+type Subscription struct {
+	repository string
+	path       string
+	hash       string      // blob hash last notified to the subscriber
+	changes    chan Change // holds at most the latest unread change
+}
+
+// Changes returns the channel on which the file changes are delivered.
+// Slow subscribers only receive the latest change, intermediate ones are discarded
+func (s *Subscription) Changes() <-chan Change {
+	return s.changes
+}
+
+// changeDetector tracks the files watched by clients and notifies them whenever the content of a file changes in the
+// served snapshot. The detector is fed by the backends heartbeat, see Manager.listen
+type changeDetector struct {
+	mutex         sync.Mutex
+	revisions     map[string]string                     // last revision checked for each repository
+	subscriptions map[string]map[*Subscription]struct{} // subscriptions by repository
+}
+
+// newChangeDetector creates a change detector without any subscription
+func newChangeDetector() *changeDetector {
+	return &changeDetector{
+		revisions:     make(map[string]string),
+		subscriptions: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// subscribe starts watching the provided file. current provides the state of the file in the served snapshot, it is
+// called while detections are suspended so that no change occurring during the subscription can be missed
+func (d *changeDetector) subscribe(repository string, filepath string, current func(filepath string) Change) (*Subscription, Change) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	subscription := &Subscription{
+		repository: repository,
+		path:       cleanPath(filepath),
+		changes:    make(chan Change, 1),
+	}
+	state := current(subscription.path)
+	subscription.hash = state.Hash
+
+	if _, ok := d.subscriptions[repository]; !ok {
+		d.subscriptions[repository] = make(map[*Subscription]struct{})
+	}
+	d.subscriptions[repository][subscription] = struct{}{}
+	return subscription, state
+}
+
+// unsubscribe stops watching the file, no change is delivered once it returns
+func (d *changeDetector) unsubscribe(subscription *Subscription) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	delete(d.subscriptions[subscription.repository], subscription)
+	if len(d.subscriptions[subscription.repository]) == 0 {
+		delete(d.subscriptions, subscription.repository)
+	}
+}
+
+// forget discards the last revision checked for a repository which is no longer served by the same backend, so that
+// a repository re-created under the same name does not inherit it
+func (d *changeDetector) forget(repository string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	delete(d.revisions, repository)
+}
+
+// detect notifies the subscribers of the files whose blob hash changed in the provided revision.
+// hashOf returns the blob hash of a file in this revision or an empty string if the file does not exist
+func (d *changeDetector) detect(repository string, revision string, hashOf func(filepath string) string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if len(revision) == 0 || d.revisions[repository] == revision {
+		return
+	}
+	d.revisions[repository] = revision
+
+	hashes := make(map[string]string)
+	for subscription := range d.subscriptions[repository] {
+		hash, ok := hashes[subscription.path]
+		if !ok {
+			hash = hashOf(subscription.path)
+			hashes[subscription.path] = hash
+		}
+		if hash == subscription.hash {
+			continue
+		}
+
+		subscription.hash = hash
+		change := Change{Repository: repository, Path: subscription.path, Revision: revision, Hash: hash}
+		// Only the latest change matters, a pending one is replaced rather than blocking the heartbeat
+		select {
+		case <-subscription.changes:
+		default:
+		}
+		subscription.changes <- change
+	}
+}
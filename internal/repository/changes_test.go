@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/assert"
+)
+
+// manager returns a manager serving the test repository to the "client" client id
+func (r *testRepository) manager() (*Manager, *Beholder) {
+	b := r.beholder()
+	b.config().Clients = []string{"client"}
+	mgr, _ := NewManager(&configuration.Repositories{Configuration: []*configuration.Repository{b.config()}})
+	mgr.Repositories["test"].Backend = b
+	return mgr, b
+}
+
+// swap simulates a beholder update to the provided revision
+func swap(mgr *Manager, b *Beholder, revision string) {
+	b.snapshot.Store(&snapshot{commit: plumbing.NewHash(revision)})
+	mgr.changes.detect("test", revision, func(path string) string { return b.Hash(revision, path) })
+}
+
+func TestWatchNotifiesChanges(t *testing.T) {
+	repo := newTestRepository(t)
+	first := repo.commit("first", map[string]string{"app.yml": "version: 1", "db.yml": "host: localhost"})
+	mgr, b := repo.manager()
+	swap(mgr, b, first.String())
+
+	subscription, current, err := mgr.Watch("test", "/app.yml", "client")
+	assert.NoError(t, err)
+	defer mgr.Unwatch(subscription)
+	assert.Equal(t, first.String(), current.Revision)
+	assert.Equal(t, "app.yml", current.Path)
+	assert.NotEmpty(t, current.Hash)
+
+	second := repo.commit("second", map[string]string{"db.yml": "host: remote"})
+	swap(mgr, b, second.String())
+	assert.Empty(t, subscription.Changes(), "changes to other files must not be notified")
+
+	third := repo.commit("third", map[string]string{"app.yml": "version: 3"})
+	swap(mgr, b, third.String())
+	change := <-subscription.Changes()
+	assert.Equal(t, third.String(), change.Revision)
+	assert.NotEqual(t, current.Hash, change.Hash)
+}
+
+func TestWatchKeepsLatestChange(t *testing.T) {
+	repo := newTestRepository(t)
+	mgr, b := repo.manager()
+	swap(mgr, b, repo.commit("first", map[string]string{"app.yml": "version: 1"}).String())
+
+	subscription, _, _ := mgr.Watch("test", "app.yml", "client")
+	defer mgr.Unwatch(subscription)
+
+	swap(mgr, b, repo.commit("second", map[string]string{"app.yml": "version: 2"}).String())
+	latest := repo.commit("third", map[string]string{"app.yml": "version: 3"})
+	swap(mgr, b, latest.String())
+
+	change := <-subscription.Changes()
+	assert.Equal(t, latest.String(), change.Revision)
+	assert.Empty(t, subscription.Changes())
+}
+
+func TestWatchDeletedFile(t *testing.T) {
+	repo := newTestRepository(t)
+	mgr, b := repo.manager()
+	swap(mgr, b, repo.commit("first", map[string]string{"app.yml": "version: 1"}).String())
+
+	subscription, current, _ := mgr.Watch("test", "missing.yml", "client")
+	defer mgr.Unwatch(subscription)
+	assert.Empty(t, current.Hash)
+
+	swap(mgr, b, repo.commit("second", map[string]string{"missing.yml": "found: true"}).String())
+	change := <-subscription.Changes()
+	assert.NotEmpty(t, change.Hash)
+}
+
+func TestWatchAccessControl(t *testing.T) {
+	repo := newTestRepository(t)
+	mgr, b := repo.manager()
+	swap(mgr, b, repo.commit("first", map[string]string{"app.yml": "version: 1"}).String())
+
+	_, _, err := mgr.Watch("test", "app.yml", "intruder")
+	assert.ErrorIs(t, err, ErrClientNotAllowed)
+
+	_, _, err = mgr.Watch("unknown", "app.yml", "client")
+	assert.ErrorIs(t, err, ErrRepositoryNotFound)
+}
+
+func TestUnwatch(t *testing.T) {
+	repo := newTestRepository(t)
+	mgr, b := repo.manager()
+	swap(mgr, b, repo.commit("first", map[string]string{"app.yml": "version: 1"}).String())
+
+	subscription, _, _ := mgr.Watch("test", "app.yml", "client")
+	mgr.Unwatch(subscription)
+	assert.Empty(t, mgr.changes.subscriptions)
+
+	swap(mgr, b, repo.commit("second", map[string]string{"app.yml": "version: 2"}).String())
+	assert.Empty(t, subscription.Changes())
+}
+
+func TestRemovedRepositoryRevisionIsForgotten(t *testing.T) {
+	repo := newTestRepository(t)
+	mgr, b := repo.manager()
+	swap(mgr, b, repo.commit("first", map[string]string{"app.yml": "version: 1"}).String())
+	assert.Contains(t, mgr.changes.revisions, "test")
+
+	assert.NoError(t, mgr.Remove("test"))
+	assert.NotContains(t, mgr.changes.revisions, "test", "re-created repositories must not inherit the last revision")
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/assert"
+)
+
+// clonedBeholder returns a beholder cloning the test repository with the provided checkout options
+func (r *testRepository) clonedBeholder(cfg *configuration.Repository) *Beholder {
+	cfg.Name, cfg.URL = "test", r.dir
+	b := NewBeholder(r.t.TempDir(), cfg, make(chan UpdateEvent, 16))
+	assert.NoError(r.t, b.update(context.Background()))
+	return b
+}
+
+func TestShallowSingleBranchCheckout(t *testing.T) {
+	repo := newTestRepository(t)
+	first := repo.commit("first", map[string]string{"config/app.yml": "version: 1"})
+	_, err := repo.workspace.CreateTag("v1.0.0", first, nil)
+	assert.NoError(t, err)
+	repo.commit("second", map[string]string{"config/app.yml": "version: 2"})
+	assert.NoError(t, repo.workspace.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("other"), first)))
+
+	b := repo.clonedBeholder(&configuration.Repository{Branch: "master", Depth: 1, SingleBranch: true})
+	file, err := b.File("config/app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 2", string(file.Content))
+
+	_, err = b.FileAt("other", "config/app.yml")
+	assert.ErrorIs(t, err, ErrRefNotFound, "other branches are not fetched")
+	_, err = b.FileAt("v1.0.0", "config/app.yml")
+	assert.ErrorIs(t, err, ErrRefNotFound, "tags are not fetched")
+
+	third := repo.commit("third", map[string]string{"config/app.yml": "version: 3"})
+	assert.NoError(t, b.update(context.Background()))
+	assert.Equal(t, third.String(), b.Revision())
+	file, err = b.File("config/app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 3", string(file.Content))
+
+	workspace, err := git.PlainOpen(b.checkoutLocation)
+	assert.NoError(t, err)
+	_, err = workspace.CommitObject(first)
+	assert.Error(t, err, "commits beyond the depth are not fetched")
+}
+
+func TestSparseCheckout(t *testing.T) {
+	repo := newTestRepository(t)
+	repo.commit("first", map[string]string{"config/app.yml": "version: 1", "config/prod/app.yml": "version: 1", "docs/readme.md": "# readme", "root.yml": "root: true"})
+
+	b := repo.clonedBeholder(&configuration.Repository{SparseCheckout: []string{"config/prod"}})
+	file, err := b.File("config/prod/app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 1", string(file.Content))
+	assert.FileExists(t, filepath.Join(b.checkoutLocation, "config", "prod", "app.yml"))
+
+	for _, outside := range []string{"config/app.yml", "docs/readme.md", "root.yml"} {
+		_, err = b.File(outside)
+		assert.ErrorIs(t, err, ErrFileNotFound)
+		assert.ErrorContains(t, err, "outside the sparse checkout")
+		_, err = os.Stat(filepath.Join(b.checkoutLocation, outside))
+		assert.ErrorIs(t, err, os.ErrNotExist, "%s must not be materialized", outside)
+	}
+	_, err = b.History("", "docs/readme.md", 10)
+	assert.ErrorIs(t, err, ErrFileNotFound)
+	assert.Empty(t, b.Hash(b.Revision(), "root.yml"))
+
+	entries, err := b.List("", "/")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "config", entries[0].Path)
+	entries, err = b.List("", "config")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "config/prod", entries[0].Path)
+	_, err = b.List("", "docs")
+	assert.ErrorIs(t, err, ErrFileNotFound)
+
+	second := repo.commit("second", map[string]string{"config/prod/app.yml": "version: 2", "docs/readme.md": "# updated"})
+	assert.NoError(t, b.update(context.Background()))
+	assert.Equal(t, second.String(), b.Revision())
+	content, err := os.ReadFile(filepath.Join(b.checkoutLocation, "config", "prod", "app.yml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 2", string(content))
+	_, err = os.Stat(filepath.Join(b.checkoutLocation, "docs"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestSparseSet(t *testing.T) {
+	sparse := newSparseSet([]string{"/config/prod/", "shared"})
+	assert.True(t, sparse.contains("config/prod/app.yml"))
+	assert.True(t, sparse.contains("shared"))
+	assert.False(t, sparse.contains("config/production.yml"))
+	assert.False(t, sparse.contains("config"))
+	assert.True(t, sparse.reaches("config"))
+	assert.True(t, sparse.reaches(""))
+	assert.False(t, sparse.reaches("conf"))
+	assert.True(t, newSparseSet(nil).contains("anything"))
+}
+
+func TestBranchCheckout(t *testing.T) {
+	repo := newTestRepository(t)
+	first := repo.commit("first", map[string]string{"app.yml": "version: 1"})
+	repo.commit("second", map[string]string{"app.yml": "version: 2"})
+	assert.NoError(t, repo.workspace.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("release"), first)))
+
+	b := repo.clonedBeholder(&configuration.Repository{Branch: "release"})
+	assert.Equal(t, first.String(), b.Revision())
+
+	tree, err := repo.workspace.Worktree()
+	assert.NoError(t, err)
+	assert.NoError(t, tree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("release")}))
+	fix := repo.commit("fix", map[string]string{"app.yml": "version: 1.1"})
+	assert.NoError(t, tree.Checkout(&git.CheckoutOptions{Branch: plumbing.Master}))
+	assert.NoError(t, b.update(context.Background()))
+	assert.Equal(t, fix.String(), b.Revision(), "the tracked branch is pulled even if it diverged from the default branch")
+
+	file, err := b.FileAt("master", "app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 2", string(file.Content), "other branches are fetched")
+}
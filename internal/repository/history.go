@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// History returns, most recent first, the commits which modified the provided file up to the provided git reference or,
+// if no reference is provided, up to the currently served snapshot. At most limit commits are returned
+func (w *Beholder) History(ref string, filepath string, limit int) ([]*CommitRef, error) {
+	if cfg := w.config(); !newSparseSet(cfg.SparseCheckout).contains(cleanPath(filepath)) {
+		return nil, errOutsideSparseCheckout(cfg.Name, filepath)
+	}
+
+	workspace, commit, err := w.commit(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := w.locate(workspace, commit, filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := fileHistory(loc.commit, loc.path, limit, shallowBoundary(loc.workspace))
+	if err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		return nil, fmt.Errorf("'%s' never existed at %s : %w", filepath, commit.Hash, ErrFileNotFound)
+	}
+	return history, nil
+}
+
+// fileHistory walks the history from the provided commit and collects the commits which created, modified or deleted
+// the file. As for lastCommits, commits are compared with their first parent and the walk stops at the boundary
+func fileHistory(head *object.Commit, filepath string, limit int, boundary []plumbing.Hash) ([]*CommitRef, error) {
+	history := []*CommitRef{}
+
+	commits := object.NewCommitPreorderIter(head, nil, boundary)
+	defer commits.Close()
+
+	err := commits.ForEach(func(commit *object.Commit) error {
+		if len(history) >= limit {
+			return storer.ErrStop
+		}
+
+		current, err := blobHashIn(commit, filepath)
+		if err != nil {
+			return err
+		}
+
+		previous := ""
+		if parent, err := commit.Parent(0); err == nil {
+			if previous, err = blobHashIn(parent, filepath); err != nil {
+				return err
+			}
+		}
+
+		if current != previous {
+			history = append(history, newCommitRef(commit))
+		}
+		return nil
+	})
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unable to walk history from %s : %w", head.Hash, err)
+	}
+	return history, nil
+}
+
+// shallowBoundary returns the parents of the shallow commits of a repository cloned with a limited depth. These commits
+// were not fetched hence history walks must not go past them
+func shallowBoundary(workspace *git.Repository) []plumbing.Hash {
+	shallows, err := workspace.Storer.Shallow()
+	if err != nil {
+		return nil
+	}
+
+	var boundary []plumbing.Hash
+	for _, hash := range shallows {
+		if commit, err := workspace.CommitObject(hash); err == nil {
+			boundary = append(boundary, commit.ParentHashes...)
+		}
+	}
+	return boundary
+}
+
+// blobHashIn returns the hash of the provided file in the commit tree or an empty string if the file does not exist
+func blobHashIn(commit *object.Commit, filepath string) (string, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return "", err
+	}
+	entry, err := tree.FindEntry(filepath)
+	if err != nil {
+		return "", nil
+	}
+	return entry.Hash.String(), nil
+}
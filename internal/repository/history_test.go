@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	repo := newTestRepository(t)
+	first := repo.commit("first", map[string]string{"app.yml": "version: 1", "db.yml": "host: localhost"})
+	repo.commit("second", map[string]string{"db.yml": "host: remote"})
+	third := repo.commit("third", map[string]string{"app.yml": "version: 3"})
+
+	b := repo.beholder()
+	b.snapshot.Store(&snapshot{commit: third})
+
+	history, err := b.History("", "/app.yml", 10)
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, third.String(), history[0].Hash)
+	assert.Equal(t, first.String(), history[1].Hash)
+	assert.Equal(t, "configserver <configserver@localhost>", history[0].Author)
+	assert.Equal(t, "third", history[0].Message)
+
+	history, err = b.History("", "app.yml", 1)
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	history, err = b.History(first.String(), "app.yml", 10)
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+}
+
+func TestHistoryDeletedFile(t *testing.T) {
+	repo := newTestRepository(t)
+	repo.commit("first", map[string]string{"app.yml": "version: 1", "db.yml": "host: localhost"})
+
+	tree, _ := repo.workspace.Worktree()
+	assert.NoError(t, os.Remove(filepath.Join(repo.dir, "db.yml")))
+	_, err := tree.Remove("db.yml")
+	assert.NoError(t, err)
+	deletion := repo.commit("delete db", map[string]string{})
+
+	history, err := repo.beholder().History(deletion.String(), "db.yml", 10)
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, deletion.String(), history[0].Hash)
+
+	_, err = repo.beholder().History(deletion.String(), "missing.yml", 10)
+	assert.ErrorIs(t, err, ErrFileNotFound)
+}
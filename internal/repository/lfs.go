@@ -0,0 +1,410 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/tracing"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// lfsPointerVersion is the first line of the pointer files git lfs commits in place of the actual content
+const lfsPointerVersion = "version https://git-lfs.github.com/spec/v1"
+
+// lfsMaxPointerSize is the size above which a blob cannot be a pointer file
+const lfsMaxPointerSize = 1024
+
+// lfsMediaType is the media type of the git lfs batch api
+const lfsMediaType = "application/vnd.git-lfs+json"
+
+// lfsObjectsDir is the directory of a git directory where git lfs stores the objects
+const lfsObjectsDir = "lfs/objects"
+
+// lfsMaxBatchResponseSize bounds the batch api responses, which only describe the requested object
+const lfsMaxBatchResponseSize = 1 << 20
+
+// lfsClient downloads the lfs objects, reads are not bound to a request context hence the timeout
+var lfsClient = &http.Client{Timeout: time.Minute}
+
+// lfsPointer designates an object stored by git lfs
+type lfsPointer struct {
+	OID  string `json:"oid"` // sha256 of the object content
+	Size int64  `json:"size"`
+}
+
+// parseLFSPointer returns the object designated by the content of a pointer file, ok is false if the content is not a
+// pointer file
+func parseLFSPointer(content []byte) (pointer *lfsPointer, ok bool) {
+	if len(content) > lfsMaxPointerSize || !bytes.HasPrefix(content, []byte(lfsPointerVersion+"\n")) {
+		return nil, false
+	}
+
+	pointer = &lfsPointer{Size: -1}
+	for _, line := range strings.Split(string(content), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "oid":
+			pointer.OID, _ = strings.CutPrefix(value, "sha256:")
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, false
+			}
+			pointer.Size = size
+		}
+	}
+	if _, err := hex.DecodeString(pointer.OID); err != nil || len(pointer.OID) != sha256.Size*2 || pointer.Size < 0 {
+		return nil, false
+	}
+	return pointer, true
+}
+
+// lfsStore fetches the lfs objects of a repository either from an lfs server, using the batch api, or from a local
+// directory laid out as the git lfs object storage. Fetched objects are cached in the local copy
+type lfsStore struct {
+	name     string // repository name
+	endpoint string // lfs server url
+	local    string // directory holding the objects, takes precedence over the endpoint
+	cache    string // directory caching the fetched objects
+	auth     transport.AuthMethod
+}
+
+// newLFSStore creates the lfs store of a repository, the objects are cached in the provided git directory
+func newLFSStore(cfg *configuration.Repository, gitDir string) (*lfsStore, error) {
+	auth, err := authMethod(cfg)
+	if err != nil {
+		return nil, err
+	}
+	store := &lfsStore{name: cfg.Name, cache: filepath.Join(gitDir, filepath.FromSlash(lfsObjectsDir)), auth: auth}
+
+	endpoint := cfg.LFSEndpoint
+	if len(endpoint) == 0 {
+		endpoint = cfg.URL
+	}
+	remote, err := transport.NewEndpoint(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' : invalid lfs endpoint '%s' : %w", cfg.Name, endpoint, err)
+	}
+	switch {
+	case remote.Protocol == "file" && len(cfg.LFSEndpoint) > 0:
+		store.local = remote.Path
+	case remote.Protocol == "file":
+		// Local remotes keep their objects in their git directory, as git lfs does for any clone
+		store.local = filepath.Join(remote.Path, lfsObjectsDir)
+		if info, err := os.Stat(filepath.Join(remote.Path, ".git")); err == nil && info.IsDir() {
+			store.local = filepath.Join(remote.Path, ".git", lfsObjectsDir)
+		}
+	case len(cfg.LFSEndpoint) > 0:
+		store.endpoint = strings.TrimSuffix(cfg.LFSEndpoint, "/")
+	default:
+		// As git lfs does, the lfs server of a remote is found under the info/lfs path of its https url
+		scheme, host := "https", remote.Host
+		if remote.Protocol == "http" || remote.Protocol == "https" {
+			scheme = remote.Protocol
+			if remote.Port > 0 {
+				host = net.JoinHostPort(remote.Host, strconv.Itoa(remote.Port))
+			}
+		}
+		repository := strings.Trim(remote.Path, "/")
+		if !strings.HasSuffix(repository, ".git") {
+			repository += ".git"
+		}
+		store.endpoint = fmt.Sprintf("%s://%s/%s/info/lfs", scheme, host, repository)
+	}
+	return store, nil
+}
+
+// content returns the content of the object designated by the pointer
+func (s *lfsStore) content(pointer *lfsPointer) ([]byte, error) {
+	cached := lfsObjectPath(s.cache, pointer.OID)
+	if content, err := os.ReadFile(cached); err == nil && pointer.verify(content) == nil {
+		return content, nil
+	}
+
+	var content []byte
+	var err error
+	if len(s.local) > 0 {
+		content, err = os.ReadFile(lfsObjectPath(s.local, pointer.OID))
+	} else {
+		content, err = s.download(pointer)
+	}
+	if err == nil {
+		err = pointer.verify(content)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("'%s' : lfs object %s cannot be fetched : %w", s.name, pointer.OID, err)
+	}
+
+	if err := writeFileAtomically(cached, content); err != nil {
+		slog.Warn("lfs object cannot be cached", logKeyRepositoryName, s.name, "lfs.oid", pointer.OID, "error", err)
+	}
+	return content, nil
+}
+
+// fetch caches the object designated by the pointer unless it is already cached
+func (s *lfsStore) fetch(pointer *lfsPointer) error {
+	if info, err := os.Stat(lfsObjectPath(s.cache, pointer.OID)); err == nil && info.Size() == pointer.Size {
+		return nil
+	}
+	_, err := s.content(pointer)
+	return err
+}
+
+// lfsBatchRequest is the body of a git lfs batch api request
+type lfsBatchRequest struct {
+	Operation string        `json:"operation"`
+	Transfers []string      `json:"transfers"`
+	Objects   []*lfsPointer `json:"objects"`
+}
+
+// lfsBatchResponse is the body of a git lfs batch api response
+type lfsBatchResponse struct {
+	Objects []struct {
+		OID     string `json:"oid"`
+		Actions struct {
+			Download *struct {
+				Href   string            `json:"href"`
+				Header map[string]string `json:"header"`
+			} `json:"download"`
+		} `json:"actions"`
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"objects"`
+}
+
+// httpAuth is implemented by the go-git http authentication methods
+type httpAuth interface {
+	SetAuth(r *http.Request)
+}
+
+// download asks the lfs server where the object can be downloaded from, using the batch api, and downloads it
+func (s *lfsStore) download(pointer *lfsPointer) ([]byte, error) {
+	body, err := json.Marshal(&lfsBatchRequest{Operation: "download", Transfers: []string{"basic"}, Objects: []*lfsPointer{pointer}})
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequest(http.MethodPost, s.endpoint+"/objects/batch", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", lfsMediaType)
+	request.Header.Set("Content-Type", lfsMediaType)
+	if auth, ok := s.auth.(httpAuth); ok {
+		auth.SetAuth(request)
+	}
+
+	batch := &lfsBatchResponse{}
+	if err := s.do(request, func(body io.Reader) error {
+		return json.NewDecoder(io.LimitReader(body, lfsMaxBatchResponseSize)).Decode(batch)
+	}); err != nil {
+		return nil, err
+	}
+
+	for _, object := range batch.Objects {
+		if object.OID != pointer.OID {
+			continue
+		}
+		if object.Error != nil {
+			return nil, fmt.Errorf("lfs server error %d : %s", object.Error.Code, object.Error.Message)
+		}
+		if object.Actions.Download == nil {
+			return nil, errors.New("the lfs server did not provide a download link")
+		}
+
+		request, err := http.NewRequest(http.MethodGet, object.Actions.Download.Href, nil)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range object.Actions.Download.Header {
+			request.Header.Set(name, value)
+		}
+		var content []byte
+		err = s.do(request, func(body io.Reader) (err error) {
+			content, err = io.ReadAll(io.LimitReader(body, pointer.Size+1))
+			return err
+		})
+		return content, err
+	}
+	return nil, errors.New("the lfs server does not know the object")
+}
+
+// do sends the request to the lfs server and reads the response body if the request succeeded
+func (s *lfsStore) do(request *http.Request, read func(body io.Reader) error) error {
+	response, err := lfsClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("'%s %s' returned status %d", request.Method, request.URL.Redacted(), response.StatusCode)
+	}
+	return read(response.Body)
+}
+
+// verify checks that the content is the one designated by the pointer
+func (p *lfsPointer) verify(content []byte) error {
+	sum := sha256.Sum256(content)
+	if int64(len(content)) != p.Size || hex.EncodeToString(sum[:]) != p.OID {
+		return errors.New("the object content does not match its pointer")
+	}
+	return nil
+}
+
+// lfsObjectPath returns the path of an object within a git lfs object storage
+func lfsObjectPath(dir string, oid string) string {
+	return filepath.Join(dir, oid[0:2], oid[2:4], oid)
+}
+
+// lfsStore returns the store holding the lfs objects of the repository, or of the submodule, a location belongs to.
+// Submodules objects are fetched from the lfs server of their remote. As objects are content addressed, they are all
+// cached in the git directory of the repository
+func (w *Beholder) lfsStore(cfg *configuration.Repository, loc *location) (*lfsStore, error) {
+	gitDir := filepath.Join(w.checkoutLocation, git.GitDirName)
+	if len(loc.submodule) == 0 {
+		return newLFSStore(cfg, gitDir)
+	}
+
+	remote, err := loc.workspace.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' : unable to read the remote of submodule '%s' : %w", cfg.Name, loc.submodule, err)
+	}
+	if len(remote.Config().URLs) == 0 {
+		return nil, fmt.Errorf("'%s' : submodule '%s' has no remote url", cfg.Name, loc.submodule)
+	}
+	module := *cfg
+	module.URL, module.LFSEndpoint = remote.Config().URLs[0], ""
+	return newLFSStore(&module, gitDir)
+}
+
+// fetchLFS caches the lfs objects designated by the pointer files of a revision, including the ones found in its
+// submodules when they are enabled, so that the revision is served without contacting the lfs servers
+func (w *Beholder) fetchLFS(ctx context.Context, workspace *git.Repository, cfg *configuration.Repository, revision plumbing.Hash) (err error) {
+	_, span := tracing.Start(ctx, "lfs.fetch", tracing.String("repository.name", cfg.Name), tracing.String("repository.revision", revision.String()))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	commit, err := workspace.CommitObject(revision)
+	if err != nil {
+		return fmt.Errorf("'%s' : unable to read revision %s : %w", cfg.Name, revision, err)
+	}
+	return w.fetchLFSObjects(cfg, &location{workspace: workspace, commit: commit}, newSparseSet(cfg.SparseCheckout))
+}
+
+// fetchLFSObjects caches the lfs objects designated by the pointer files of the tree of a location, following the
+// submodules it holds when they are enabled
+func (w *Beholder) fetchLFSObjects(cfg *configuration.Repository, loc *location, sparse sparseSet) error {
+	store, err := w.lfsStore(cfg, loc)
+	if err != nil {
+		return err
+	}
+	tree, err := loc.commit.Tree()
+	if err != nil {
+		return fmt.Errorf("'%s' : unable to read tree of %s : %w", cfg.Name, loc.commit.Hash, err)
+	}
+
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("'%s' : unable to walk tree of %s : %w", cfg.Name, loc.commit.Hash, err)
+		}
+
+		entryPath := path.Join(loc.submodule, name)
+		switch {
+		case entry.Mode == filemode.Submodule && cfg.Submodules && sparse.reaches(entryPath):
+			module, err := openSubmodule(loc.workspace, loc.commit, name)
+			if err != nil {
+				return fmt.Errorf("'%s' : %w", entryPath, err)
+			}
+			revision, err := module.CommitObject(entry.Hash)
+			if err != nil {
+				return fmt.Errorf("'%s' submodule revision %s is not checked out : %w", entryPath, entry.Hash, err)
+			}
+			if err := w.fetchLFSObjects(cfg, &location{workspace: module, commit: revision, submodule: entryPath}, sparse); err != nil {
+				return err
+			}
+		case entry.Mode.IsFile() && sparse.contains(entryPath):
+			if pointer, ok := readLFSPointer(loc.workspace, entry.Hash); ok {
+				if err := store.fetch(pointer); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// readLFSPointer returns the object designated by a blob, ok is false if the blob is not a pointer file
+func readLFSPointer(workspace *git.Repository, hash plumbing.Hash) (pointer *lfsPointer, ok bool) {
+	blob, err := workspace.BlobObject(hash)
+	if err != nil || blob.Size > lfsMaxPointerSize {
+		return nil, false
+	}
+	reader, err := blob.Reader()
+	if err != nil {
+		return nil, false
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, false
+	}
+	return parseLFSPointer(content)
+}
+
+// resolveLFS replaces the content of an lfs pointer file by the object it designates, other files are left untouched.
+// The objects of the served revision are cached when it is checked out, see fetchLFS
+func (w *Beholder) resolveLFS(cfg *configuration.Repository, loc *location, file *File) error {
+	pointer, ok := parseLFSPointer(file.Content)
+	if !ok {
+		return nil
+	}
+	store, err := w.lfsStore(cfg, loc)
+	if err != nil {
+		return err
+	}
+	if file.Content, err = store.content(pointer); err != nil {
+		return err
+	}
+	return nil
+}
+
+// lfsSizes reports the size of the objects designated by the lfs pointer files of a listing instead of the size of the
+// pointers
+func lfsSizes(workspace *git.Repository, entries []*Entry) {
+	for _, entry := range entries {
+		if entry.Type != EntryFile || entry.Size > lfsMaxPointerSize {
+			continue
+		}
+		if pointer, ok := readLFSPointer(workspace, plumbing.NewHash(entry.Hash)); ok {
+			entry.Size = pointer.Size
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/stretchr/testify/assert"
+)
+
+// lfsObject stores the content in the provided git lfs object storage and returns the pointer file designating it
+func lfsObject(t *testing.T, dir string, content string) string {
+	sum := sha256.Sum256([]byte(content))
+	oid := hex.EncodeToString(sum[:])
+	target := lfsObjectPath(dir, oid)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(target), os.ModePerm))
+	assert.NoError(t, os.WriteFile(target, []byte(content), 0600))
+	return fmt.Sprintf("%s\noid sha256:%s\nsize %d\n", lfsPointerVersion, oid, len(content))
+}
+
+func TestParseLFSPointer(t *testing.T) {
+	oid := "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393"
+	pointer, ok := parseLFSPointer([]byte(fmt.Sprintf("%s\noid sha256:%s\nsize 12345\n", lfsPointerVersion, oid)))
+	assert.True(t, ok)
+	assert.Equal(t, &lfsPointer{OID: oid, Size: 12345}, pointer)
+
+	_, ok = parseLFSPointer([]byte("timeout: 1"))
+	assert.False(t, ok, "regular files are not pointers")
+	_, ok = parseLFSPointer([]byte(fmt.Sprintf("%s\noid sha256:1234\nsize 12345\n", lfsPointerVersion)))
+	assert.False(t, ok, "oids are sha256 sums")
+	_, ok = parseLFSPointer([]byte(fmt.Sprintf("%s\noid sha256:%s\n", lfsPointerVersion, oid)))
+	assert.False(t, ok, "pointers provide the object size")
+}
+
+func TestLFSLocalRemote(t *testing.T) {
+	repo := newTestRepository(t)
+	pointer := lfsObject(t, filepath.Join(repo.dir, ".git", lfsObjectsDir), "-----BEGIN CERTIFICATE-----")
+	repo.commit("first", map[string]string{"certs/bundle.pem": pointer, "config/app.yml": "version: 1"})
+
+	b := repo.clonedBeholder(&configuration.Repository{LFS: true})
+	file, err := b.File("certs/bundle.pem")
+	assert.NoError(t, err)
+	assert.Equal(t, "-----BEGIN CERTIFICATE-----", string(file.Content))
+	file, err = b.File("config/app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 1", string(file.Content), "regular files are served as is")
+
+	entries, err := b.List("", "certs")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len("-----BEGIN CERTIFICATE-----")), entries[0].Size)
+
+	assert.NoError(t, os.RemoveAll(filepath.Join(repo.dir, ".git", "lfs")))
+	file, err = b.File("certs/bundle.pem")
+	assert.NoError(t, err, "objects are cached once fetched")
+	assert.Equal(t, "-----BEGIN CERTIFICATE-----", string(file.Content))
+
+	b.configuration.Store(&configuration.Repository{Name: "test", URL: repo.dir})
+	file, err = b.File("certs/bundle.pem")
+	assert.NoError(t, err)
+	assert.Equal(t, pointer, string(file.Content), "pointers are served as is unless lfs is enabled")
+}
+
+func TestLFSLocalEndpoint(t *testing.T) {
+	objects := t.TempDir()
+	repo := newTestRepository(t)
+	repo.commit("first", map[string]string{"certs/bundle.pem": lfsObject(t, objects, "bundle")})
+
+	b := repo.clonedBeholder(&configuration.Repository{LFS: true, LFSEndpoint: "file://" + objects})
+	file, err := b.File("certs/bundle.pem")
+	assert.NoError(t, err)
+	assert.Equal(t, "bundle", string(file.Content))
+}
+
+func TestLFSServer(t *testing.T) {
+	objects := t.TempDir()
+	repo := newTestRepository(t)
+	pointer := lfsObject(t, objects, "bundle")
+	repo.commit("first", map[string]string{"certs/bundle.pem": pointer})
+
+	var batches atomic.Int32
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("POST /lfs/objects/batch", func(w http.ResponseWriter, r *http.Request) {
+		batches.Add(1)
+		username, password, _ := r.BasicAuth()
+		assert.Equal(t, "git", username)
+		assert.Equal(t, "secret", password)
+		assert.Equal(t, lfsMediaType, r.Header.Get("Accept"))
+
+		batch := &lfsBatchRequest{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(batch))
+		assert.Equal(t, "download", batch.Operation)
+		oid := batch.Objects[0].OID
+		w.Header().Set("Content-Type", lfsMediaType)
+		_, _ = fmt.Fprintf(w, `{"objects":[{"oid":"%s","actions":{"download":{"href":"%s/objects/%s","header":{"X-Object":"%s"}}}}]}`, oid, server.URL, oid, oid)
+	})
+	mux.HandleFunc("GET /objects/{oid}", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.PathValue("oid"), r.Header.Get("X-Object"))
+		http.ServeFile(w, r, lfsObjectPath(objects, r.PathValue("oid")))
+	})
+
+	b := repo.clonedBeholder(&configuration.Repository{LFS: true, LFSEndpoint: server.URL + "/lfs", Token: "secret"})
+	assert.Equal(t, int32(1), batches.Load(), "objects are fetched when the revision is checked out")
+	file, err := b.File("certs/bundle.pem")
+	assert.NoError(t, err)
+	assert.Equal(t, "bundle", string(file.Content))
+	assert.Equal(t, int32(1), batches.Load(), "reads do not contact the lfs server")
+
+	served := b.Revision()
+	corrupted, _ := parseLFSPointer([]byte(lfsObject(t, t.TempDir(), "corrupted")))
+	repo.commit("second", map[string]string{"certs/corrupted.pem": lfsObject(t, objects, "corrupted")})
+	assert.NoError(t, os.WriteFile(lfsObjectPath(objects, corrupted.OID), []byte("tampered"), 0600))
+	assert.ErrorContains(t, b.update(context.Background()), "does not match its pointer")
+	assert.Equal(t, served, b.Revision(), "revisions whose objects cannot be fetched are not served")
+}
+
+func TestLFSSubmodule(t *testing.T) {
+	shared := newTestRepository(t)
+	revision := shared.commit("first", map[string]string{"certs/bundle.pem": lfsObject(t, filepath.Join(shared.dir, ".git", lfsObjectsDir), "bundle")})
+	repo := newTestRepository(t)
+	repo.commit("first", map[string]string{"config/app.yml": "version: 1"})
+	repo.submodule("shared", shared, revision)
+
+	b := repo.clonedBeholder(&configuration.Repository{LFS: true, Submodules: true})
+	assert.NoError(t, os.RemoveAll(filepath.Join(shared.dir, ".git", "lfs")))
+	file, err := b.File("shared/certs/bundle.pem")
+	assert.NoError(t, err)
+	assert.Equal(t, "bundle", string(file.Content), "submodules objects are fetched from their remote when checked out")
+
+	entries, err := b.List("", "shared/certs")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len("bundle")), entries[0].Size)
+}
+
+func TestLFSBatchResponseIsBounded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"objects":[{"oid":"`)
+		_, _ = w.Write(bytes.Repeat([]byte("0"), 2*lfsMaxBatchResponseSize))
+		_, _ = fmt.Fprint(w, `"}]}`)
+	}))
+	defer server.Close()
+
+	store, err := newLFSStore(&configuration.Repository{Name: "test", URL: server.URL, LFSEndpoint: server.URL}, t.TempDir())
+	assert.NoError(t, err)
+	pointer, _ := parseLFSPointer([]byte(lfsObject(t, t.TempDir(), "bundle")))
+	_, err = store.download(pointer)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "responses are truncated")
+}
+
+func TestLFSEndpoint(t *testing.T) {
+	for url, endpoint := range map[string]string{
+		"https://github.com/owner/repository":          "https://github.com/owner/repository.git/info/lfs",
+		"http://localhost:8080/owner/repository.git":   "http://localhost:8080/owner/repository.git/info/lfs",
+		"git@github.com:owner/repository.git":          "https://github.com/owner/repository.git/info/lfs",
+		"ssh://git@github.com:22/owner/repository.git": "https://github.com/owner/repository.git/info/lfs",
+	} {
+		store, err := newLFSStore(&configuration.Repository{Name: "test", URL: url}, t.TempDir())
+		assert.NoError(t, err)
+		assert.Equal(t, endpoint, store.endpoint, url)
+	}
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// EntryType designates the kind of a directory entry
+type EntryType string
+
+const (
+	EntryFile      EntryType = "file"      // EntryFile designates regular and executable files
+	EntryDirectory EntryType = "directory" // EntryDirectory designates directories
+	EntrySymlink   EntryType = "symlink"   // EntrySymlink designates symbolic links
+	EntrySubmodule EntryType = "submodule" // EntrySubmodule designates git submodules
+)
+
+// hiddenEntries lists the entries which are never listed
+var hiddenEntries = map[string]bool{
+	git.GitDirName: true,
+}
+
+// Entry describes a file or a directory of a repository
+type Entry struct {
+	Name         string     `json:"name"`
+	Path         string     `json:"path"`
+	Type         EntryType  `json:"type"`
+	Size         int64      `json:"size"`
+	Hash         string     `json:"hash"`         // blob hash for files, tree hash for directories
+	LastModified *CommitRef `json:"lastModified"` // last commit which modified the entry
+}
+
+// CommitRef summarizes a commit
+type CommitRef struct {
+	Hash    string    `json:"hash"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+	Message string    `json:"message"`
+}
+
+// newCommitRef summarizes the provided commit
+func newCommitRef(commit *object.Commit) *CommitRef {
+	return &CommitRef{
+		Hash:    commit.Hash.String(),
+		Author:  fmt.Sprintf("%s <%s>", commit.Author.Name, commit.Author.Email),
+		Date:    commit.Author.When,
+		Message: commit.Message,
+	}
+}
+
+// listDirectory lists the entries of the provided directory as it exists in the given commit
+func listDirectory(workspace *git.Repository, commit *object.Commit, dir string) ([]*Entry, error) {
+	dir = cleanPath(dir)
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read tree of %s : %w", commit.Hash, err)
+	}
+
+	if len(dir) > 0 {
+		entry, err := tree.FindEntry(dir)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not accessible at %s : %w", dir, commit.Hash, ErrFileNotFound)
+		}
+		if entry.Mode != filemode.Dir {
+			return nil, fmt.Errorf("'%s' : %w", dir, ErrNotADirectory)
+		}
+		if tree, err = tree.Tree(dir); err != nil {
+			return nil, fmt.Errorf("unable to read '%s' at %s : %w", dir, commit.Hash, err)
+		}
+	}
+
+	entries := make([]*Entry, 0, len(tree.Entries))
+	for _, treeEntry := range tree.Entries {
+		if hiddenEntries[treeEntry.Name] {
+			continue
+		}
+
+		entry := &Entry{
+			Name: treeEntry.Name,
+			Path: path.Join(dir, treeEntry.Name),
+			Hash: treeEntry.Hash.String(),
+		}
+		switch treeEntry.Mode {
+		case filemode.Dir:
+			entry.Type = EntryDirectory
+		case filemode.Submodule:
+			entry.Type = EntrySubmodule
+		case filemode.Symlink:
+			entry.Type = EntrySymlink
+		default:
+			entry.Type = EntryFile
+		}
+
+		if entry.Type == EntryFile || entry.Type == EntrySymlink {
+			object, err := workspace.Storer.EncodedObject(plumbing.BlobObject, treeEntry.Hash)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read '%s' at %s : %w", entry.Path, commit.Hash, err)
+			}
+			entry.Size = object.Size()
+		}
+		entries = append(entries, entry)
+	}
+
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		paths = append(paths, entry.Path)
+	}
+	commits, err := lastCommits(commit, paths, shallowBoundary(workspace))
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if last, ok := commits[entry.Path]; ok {
+			entry.LastModified = newCommitRef(last)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+// lastCommits walks the history from the provided commit, up to the boundary, to find the last commit which modified
+// each path. A path was modified by a commit if its hash differs from the one found in the commit's first parent
+func lastCommits(head *object.Commit, paths []string, boundary []plumbing.Hash) (map[string]*object.Commit, error) {
+	pending := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		pending[p] = true
+	}
+	found := make(map[string]*object.Commit, len(paths))
+
+	commits := object.NewCommitPreorderIter(head, nil, boundary)
+	defer commits.Close()
+
+	err := commits.ForEach(func(commit *object.Commit) error {
+		if len(pending) == 0 {
+			return storer.ErrStop
+		}
+
+		tree, err := commit.Tree()
+		if err != nil {
+			return err
+		}
+
+		var parentTree *object.Tree
+		if parent, err := commit.Parent(0); err == nil {
+			parentTree, _ = parent.Tree()
+		}
+
+		for p := range pending {
+			current, err := tree.FindEntry(p)
+			if err != nil {
+				continue
+			}
+			if parentTree != nil {
+				previous, err := parentTree.FindEntry(p)
+				if err == nil && previous.Hash == current.Hash {
+					continue
+				}
+			}
+			found[p] = commit
+			delete(pending, p)
+		}
+		return nil
+	})
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unable to walk history from %s : %w", head.Hash, err)
+	}
+	return found, nil
+}
@@ -0,0 +1,267 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// defaultLocalPollInterval is the interval at which local directories are scanned for changes when the repository
+// does not configure a refresh interval
+const defaultLocalPollInterval = 5 * time.Second
+
+// LocalBackend is the Backend serving the files of a local directory, i.e a mounted Kubernetes volume or a working
+// copy during development. Local directories have no history, the only revision available is the current content of
+// the directory which is polled for changes
+type LocalBackend struct {
+	scheduler
+	root     string                 // Served directory
+	revision atomic.Pointer[string] // Version of the directory content, nil until the directory is first scanned
+}
+
+// NewLocalBackend initiates a new local backend for the provided configuration
+// a call to Watch() is mandatory to start scanning the directory
+func NewLocalBackend(configuration *configuration.Repository, heartbeat chan UpdateEvent) (*LocalBackend, error) {
+	if len(configuration.Path) == 0 {
+		return nil, fmt.Errorf("'%s' : local repositories require a path", configuration.Name)
+	}
+
+	root, err := filepath.Abs(configuration.Path)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' : invalid path '%s' : %w", configuration.Name, configuration.Path, err)
+	}
+
+	local := &LocalBackend{root: root}
+	local.init(configuration, heartbeat)
+	return local, nil
+}
+
+// Watch starts scanning the served directory for changes until the context is cancelled or Stop is called.
+// The served directory is set at creation, changing the path of a local repository requires a new backend
+func (l *LocalBackend) Watch(ctx context.Context) {
+	go l.run(ctx, l, defaultLocalPollInterval, l.update)
+}
+
+// Discard does nothing as the served directory is not owned by the backend
+func (l *LocalBackend) Discard() error {
+	return nil
+}
+
+// Available returns true once the served directory was successfully scanned
+func (l *LocalBackend) Available() bool {
+	return l.revision.Load() != nil
+}
+
+// Revision returns the version of the directory content or an empty string if the directory was never scanned
+func (l *LocalBackend) Revision() string {
+	current := l.revision.Load()
+	if current == nil {
+		return ""
+	}
+	return *current
+}
+
+// update scans the served directory and computes the version of its content
+func (l *LocalBackend) update(ctx context.Context) (err error) {
+	defer observeUpdate(ctx, l.config().Name, operationScan, time.Now(), &err)
+
+	version, err := l.scan(ctx)
+	if err != nil {
+		return err
+	}
+
+	if current := l.revision.Load(); current == nil || *current != version {
+		slog.Info(fmt.Sprintf("'%s' now serving revision %s", l.config().Name, version), logKeyRepositoryName, l.config().Name)
+		l.revision.Store(&version)
+	}
+	return nil
+}
+
+// scan derives a version from the path, size and modification date of every file found in the served directory.
+// Symbolic links to files are followed so that the atomic updates of Kubernetes volumes, which swap a symbolic link
+// to the directory holding the files, are detected
+func (l *LocalBackend) scan(ctx context.Context) (string, error) {
+	info, err := os.Stat(l.root)
+	if err != nil {
+		return "", fmt.Errorf("'%s' : cannot access '%s' : %w", l.config().Name, l.root, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("'%s' : '%s' is not a directory", l.config().Name, l.root)
+	}
+
+	hash := sha1.New()
+	err = filepath.WalkDir(l.root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p == l.root {
+			return nil
+		}
+		if isHiddenLocalEntry(entry.Name()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		info, err := os.Stat(p)
+		if err != nil || info.IsDir() {
+			return nil // broken or directory links are not served
+		}
+		rel, _ := filepath.Rel(l.root, p)
+		fmt.Fprintf(hash, "%s\x00%d\x00%d\x00", filepath.ToSlash(rel), info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("'%s' : unable to scan '%s' : %w", l.config().Name, l.root, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16], nil
+}
+
+// isHiddenLocalEntry returns true for the entries which are never served : the git metadata and the Kubernetes
+// volumes internals, whose names start with two dots
+func isHiddenLocalEntry(name string) bool {
+	return hiddenEntries[name] || strings.HasPrefix(name, "..")
+}
+
+// resolve converts a requested path to its location on disk, symbolic links are resolved and must not point outside
+// of the served directory
+func (l *LocalBackend) resolve(requested string) (string, string, error) {
+	name := cleanPath(requested)
+	for _, segment := range strings.Split(name, "/") {
+		if isHiddenLocalEntry(segment) {
+			return "", "", fmt.Errorf("'%s' is not accessible : %w", requested, ErrFileNotFound)
+		}
+	}
+
+	root, err := filepath.EvalSymlinks(l.root)
+	if err != nil {
+		return "", "", fmt.Errorf("'%s' : cannot access '%s' : %w", l.config().Name, l.root, err)
+	}
+	location, err := filepath.EvalSymlinks(filepath.Join(l.root, filepath.FromSlash(name)))
+	if err != nil {
+		return "", "", fmt.Errorf("'%s' is not accessible : %w", requested, ErrFileNotFound)
+	}
+	if location != root && !strings.HasPrefix(location, root+string(filepath.Separator)) {
+		return "", "", fmt.Errorf("'%s' points outside of the repository : %w", requested, ErrFileNotFound)
+	}
+	return name, location, nil
+}
+
+// checkRef ensures that the requested reference designates the served content, local directories have no history
+func (l *LocalBackend) checkRef(ref string) error {
+	revision := l.Revision()
+	if len(revision) == 0 {
+		return ErrRepositoryUnavailable
+	}
+	if len(ref) > 0 && ref != revision {
+		return fmt.Errorf("'%s' : local repositories only serve their current revision : %w", ref, ErrRefNotFound)
+	}
+	return nil
+}
+
+// FileAt reads the requested file from the served directory, the only supported reference is the current revision
+func (l *LocalBackend) FileAt(ref string, filepath string) (*File, error) {
+	if err := l.checkRef(ref); err != nil {
+		return nil, err
+	}
+
+	name, location, err := l.resolve(filepath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(location)
+	if err != nil || info.IsDir() {
+		return nil, fmt.Errorf("'%s' is not accessible or is a directory : %w", filepath, ErrFileNotFound)
+	}
+	content, err := os.ReadFile(location)
+	if err != nil {
+		return nil, fmt.Errorf("an unexpected error occured while reading '%s' : %w", filepath, err)
+	}
+
+	return &File{
+		Path:         name,
+		Content:      content,
+		Hash:         plumbing.ComputeHash(plumbing.BlobObject, content).String(),
+		Revision:     l.Revision(),
+		LastModified: info.ModTime(),
+	}, nil
+}
+
+// List returns the entries of the requested directory, the only supported reference is the current revision.
+// Entries have no last modification commit
+func (l *LocalBackend) List(ref string, dir string) ([]*Entry, error) {
+	if err := l.checkRef(ref); err != nil {
+		return nil, err
+	}
+
+	name, location, err := l.resolve(dir)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(location); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("'%s' : %w", dir, ErrNotADirectory)
+	}
+
+	children, err := os.ReadDir(location)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read '%s' : %w", dir, err)
+	}
+
+	entries := make([]*Entry, 0, len(children))
+	for _, child := range children {
+		if isHiddenLocalEntry(child.Name()) {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(location, child.Name()))
+		if err != nil {
+			continue // broken links are not served
+		}
+
+		entry := &Entry{Name: child.Name(), Path: path.Join(name, child.Name()), Type: EntryDirectory}
+		if !info.IsDir() {
+			content, err := os.ReadFile(filepath.Join(location, child.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("unable to read '%s' : %w", entry.Path, err)
+			}
+			entry.Type = EntryFile
+			entry.Size = info.Size()
+			entry.Hash = plumbing.ComputeHash(plumbing.BlobObject, content).String()
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// History is not supported by local directories
+func (l *LocalBackend) History(ref string, filepath string, limit int) ([]*CommitRef, error) {
+	return nil, fmt.Errorf("'%s' has no history : %w", l.config().Name, ErrNotSupported)
+}
+
+// Hash returns the blob hash of the provided file as it currently exists in the served directory, local directories
+// only hold their current revision
+func (l *LocalBackend) Hash(revision string, filepath string) string {
+	file, err := l.FileAt("", filepath)
+	if err != nil {
+		return ""
+	}
+	return file.Hash
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/assert"
+)
+
+// newLocalBackend returns a scanned local backend serving the provided files
+func newLocalBackend(t *testing.T, files map[string]string) (*LocalBackend, string) {
+	dir := t.TempDir()
+	for name, content := range files {
+		target := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(target), os.ModePerm))
+		assert.NoError(t, os.WriteFile(target, []byte(content), 0600))
+	}
+
+	l, err := NewLocalBackend(&configuration.Repository{Name: "local", Path: dir}, make(chan UpdateEvent, 16))
+	assert.NoError(t, err)
+	return l, dir
+}
+
+func TestLocalBackendRequiresPath(t *testing.T) {
+	_, err := NewBackend("", &configuration.Repository{Name: "local", Backend: configuration.BackendLocal}, nil)
+	assert.Error(t, err)
+
+	_, err = NewBackend("", &configuration.Repository{Name: "svn", Backend: "svn"}, nil)
+	assert.Error(t, err)
+}
+
+func TestLocalBackendFile(t *testing.T) {
+	l, _ := newLocalBackend(t, map[string]string{"config/app.yml": "version: 1"})
+
+	_, err := l.FileAt("", "config/app.yml")
+	assert.ErrorIs(t, err, ErrRepositoryUnavailable)
+
+	assert.NoError(t, l.update(context.Background()))
+	file, err := l.FileAt("", "/config/app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 1", string(file.Content))
+	assert.Equal(t, "config/app.yml", file.Path)
+	assert.Equal(t, l.Revision(), file.Revision)
+	assert.Equal(t, plumbing.ComputeHash(plumbing.BlobObject, []byte("version: 1")).String(), file.Hash, "blob hashes must match git's")
+	assert.Equal(t, file.Hash, l.Hash(l.Revision(), "config/app.yml"))
+	assert.False(t, file.LastModified.IsZero())
+
+	_, err = l.FileAt("", "config")
+	assert.ErrorIs(t, err, ErrFileNotFound)
+	_, err = l.FileAt("", "missing.yml")
+	assert.ErrorIs(t, err, ErrFileNotFound)
+	_, err = l.FileAt("v1.0.0", "config/app.yml")
+	assert.ErrorIs(t, err, ErrRefNotFound)
+	_, err = l.FileAt(l.Revision(), "config/app.yml")
+	assert.NoError(t, err)
+	_, err = l.History("", "config/app.yml", 10)
+	assert.ErrorIs(t, err, ErrNotSupported)
+}
+
+func TestLocalBackendCannotEscapeDirectory(t *testing.T) {
+	l, dir := newLocalBackend(t, map[string]string{"app.yml": "version: 1", ".git/config": "[core]"})
+	outside := filepath.Join(t.TempDir(), "secret.yml")
+	assert.NoError(t, os.WriteFile(outside, []byte("password: secret"), 0600))
+	assert.NoError(t, os.Symlink(outside, filepath.Join(dir, "link.yml")))
+	assert.NoError(t, l.update(context.Background()))
+
+	file, err := l.FileAt("", "../../app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 1", string(file.Content))
+
+	_, err = l.FileAt("", "link.yml")
+	assert.ErrorIs(t, err, ErrFileNotFound)
+	_, err = l.FileAt("", ".git/config")
+	assert.ErrorIs(t, err, ErrFileNotFound)
+}
+
+func TestLocalBackendKubernetesVolume(t *testing.T) {
+	// Kubernetes volumes expose files as links to a ..data link, which is swapped to a new directory on update
+	l, dir := newLocalBackend(t, map[string]string{"..2024_01_01/app.yml": "version: 1", "..2024_01_02/app.yml": "version: 2!"})
+	assert.NoError(t, os.Symlink("..2024_01_01", filepath.Join(dir, "..data")))
+	assert.NoError(t, os.Symlink(filepath.Join("..data", "app.yml"), filepath.Join(dir, "app.yml")))
+	assert.NoError(t, l.update(context.Background()))
+	first := l.Revision()
+
+	file, err := l.FileAt("", "app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 1", string(file.Content))
+
+	entries, err := l.List("", "")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "volume internals must be hidden")
+	assert.Equal(t, EntryFile, entries[0].Type)
+	assert.Equal(t, file.Hash, entries[0].Hash)
+
+	assert.NoError(t, os.Remove(filepath.Join(dir, "..data")))
+	assert.NoError(t, os.Symlink("..2024_01_02", filepath.Join(dir, "..data")))
+	assert.NoError(t, l.update(context.Background()))
+	assert.NotEqual(t, first, l.Revision())
+
+	file, err = l.FileAt("", "app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 2!", string(file.Content))
+}
+
+func TestLocalBackendList(t *testing.T) {
+	l, _ := newLocalBackend(t, map[string]string{"config/app.yml": "version: 1", "config/db/db.yml": "host: localhost"})
+	assert.NoError(t, l.update(context.Background()))
+
+	entries, err := l.List("", "config/")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "config/app.yml", entries[0].Path)
+	assert.Equal(t, EntryFile, entries[0].Type)
+	assert.Equal(t, int64(10), entries[0].Size)
+	assert.Equal(t, "config/db", entries[1].Path)
+	assert.Equal(t, EntryDirectory, entries[1].Type)
+
+	_, err = l.List("", "config/app.yml")
+	assert.ErrorIs(t, err, ErrNotADirectory)
+}
+
+func TestLocalBackendWatch(t *testing.T) {
+	l, dir := newLocalBackend(t, map[string]string{"app.yml": "version: 1"})
+	l.Watch(context.Background())
+
+	event := <-l.heartbeat
+	assert.Equal(t, StateHealthy, event.State)
+	assert.Equal(t, l.Revision(), event.Revision)
+	first := event.Revision
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "app.yml"), []byte("version: 2"), 0600))
+	assert.NoError(t, os.Chtimes(filepath.Join(dir, "app.yml"), time.Now(), time.Now().Add(time.Minute)))
+	l.Refresh()
+
+	event = <-l.heartbeat
+	assert.NotEqual(t, first, event.Revision)
+	file, err := l.FileAt("", "app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 2", string(file.Content))
+	assert.Equal(t, event.Revision, file.Revision)
+}
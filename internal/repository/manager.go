@@ -1,18 +1,41 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	config "github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/tracing"
 )
 
 // Manager is one-stop shop for managing multiple repositories configured via yaml files
 type Manager struct {
-	Configuration *config.Repositories   // git configuration
-	Repositories  map[string]*Repository // list of configured repository
-	Heartbeat     chan UpdateEvent       // uplink channel used by beholders to communicate
+	Configuration  *config.Repositories   // git configuration
+	Repositories   map[string]*Repository // list of configured repository
+	Heartbeat      chan UpdateEvent       // uplink channel used by backends to communicate
+	changes        *changeDetector        // notifies the clients watching files, fed by the heartbeat
+	mutex          sync.RWMutex           // guards Configuration and Repositories which are replaced on reload
+	updating       sync.Mutex             // serializes the reloads and the runtime administration of the repositories
+	ctx            context.Context        // context the backends are watched with, set by Start
+	activity       *activityTracker       // last seen table of the clients
+	statisticsFile string                 // file the statistics are saved to, see PersistStatistics
+	saving         sync.Mutex             // serializes the statistics saves
+	modified       atomic.Bool            // true when the statistics changed since they were last saved
+}
+
+// ReloadSummary lists the repositories affected by a configuration reload
+type ReloadSummary struct {
+	Added     []string // repositories which were not configured so far
+	Removed   []string // repositories which are no longer configured
+	Updated   []string // repositories whose settings were updated in place
+	Restarted []string // repositories whose storage changed and which are served by a new backend
 }
 
 // NewManager creates a new repository manager by parsing the provided target repository configuration location
@@ -22,68 +45,527 @@ func NewManager(configuration *config.Repositories) (*Manager, error) {
 
 	repos := make(map[string]*Repository)
 	for _, repo := range configuration.Configuration {
+		backend, err := NewBackend(configuration.CheckoutLocation, repo, hb)
+		if err != nil {
+			return nil, err
+		}
 		repos[repo.Name] = &Repository{
 			Configuration: repo,
-			Beholder:      NewBeholder(configuration.CheckoutLocation, repo, hb),
-			Statistics:    &Statistics{},
+			Backend:       backend,
+			Statistics:    newStatistics(),
+			usage:         &backendUsage{},
 		}
 	}
 
-	return &Manager{configuration, repos, hb}, nil
+	return &Manager{
+		Configuration: configuration,
+		Repositories:  repos,
+		Heartbeat:     hb,
+		changes:       newChangeDetector(),
+		ctx:           context.Background(),
+		activity:      newActivityTracker(),
+	}, nil
 }
 
-// Start will generate a repository beholder for each found configuration and will attempt to create a local copy
-func (mgr *Manager) Start() {
-	go mgr.listen()
+// Start watches the backend of each configured repository, git backends will attempt to create a local copy.
+// Backends, including the ones started by later reloads, are watched until the context is cancelled
+func (mgr *Manager) Start(ctx context.Context) {
+	mgr.updating.Lock()
+	defer mgr.updating.Unlock()
+	mgr.ctx = ctx
+	go mgr.listen(ctx)
+	if len(mgr.statisticsFile) > 0 {
+		go mgr.persist(ctx)
+	}
+
+	mgr.mutex.RLock()
+	defer mgr.mutex.RUnlock()
 	for name, repo := range mgr.Repositories {
-		slog.Info("starting beholder", "name", name)
-		repo.Beholder.Watch()
+		slog.Info("starting backend", "name", name)
+		mgr.watch(repo)
+	}
+}
+
+// watch starts the backend of a repository unless it was already started, the caller must hold the updating lock
+func (mgr *Manager) watch(repo *Repository) {
+	if !repo.usage.watched {
+		repo.usage.watched = true
+		repo.Backend.Watch(mgr.ctx)
+	}
+}
+
+// Shutdown stops the backends of all the repositories and waits for their updates in progress to be aborted or for the
+// context to expire. The repositories statistics are then logged and saved, the local copies are left untouched
+func (mgr *Manager) Shutdown(ctx context.Context) error {
+	mgr.updating.Lock()
+	defer mgr.updating.Unlock()
+
+	mgr.mutex.RLock()
+	repos := make(map[string]*Repository, len(mgr.Repositories))
+	for name, repo := range mgr.Repositories {
+		repos[name] = repo
+		repo.Backend.Stop()
+	}
+	mgr.mutex.RUnlock()
+
+	for name, repo := range repos {
+		select {
+		case <-repo.Backend.Done():
+		case <-ctx.Done():
+			return fmt.Errorf("'%s' backend did not stop in time : %w", name, ctx.Err())
+		}
+		statistics := repo.Statistics.Snapshot()
+		slog.Info(fmt.Sprintf("'%s' stopped", name), logKeyRepositoryName, name, "repository.hit_count", statistics.HitCount, "repository.state", statistics.State, "repository.last_update", statistics.LastUpdate)
+	}
+	return mgr.saveStatistics()
+}
+
+// Reload applies an updated repositories configuration : backends are started for the new repositories and stopped
+// for the removed ones while the settings of the other repositories are updated in place. Repositories whose
+// storage changed (backend, url or path) are served by a new backend.
+// Nothing is changed if any of the new backends cannot be created
+func (mgr *Manager) Reload(configuration *config.Repositories) (*ReloadSummary, error) {
+	mgr.updating.Lock()
+	defer mgr.updating.Unlock()
+	return mgr.reload(configuration)
+}
+
+// reload applies an updated repositories configuration, the caller must hold the updating lock. The local copies of
+// the removed and restarted repositories are deleted once their backends are stopped
+func (mgr *Manager) reload(configuration *config.Repositories) (*ReloadSummary, error) {
+	mgr.mutex.RLock()
+	current := mgr.Repositories
+	mgr.mutex.RUnlock()
+
+	summary := &ReloadSummary{}
+	repos := make(map[string]*Repository)
+	var started, stopped []*Repository
+	updated := make(map[Backend]*config.Repository)
+	for _, repo := range configuration.Configuration {
+		existing, ok := current[repo.Name]
+		if ok && !requiresNewBackend(existing.Configuration, repo) {
+			repos[repo.Name] = &Repository{Configuration: repo, Statistics: existing.Statistics, Backend: existing.Backend, usage: existing.usage}
+			if !reflect.DeepEqual(existing.Configuration, repo) {
+				summary.Updated = append(summary.Updated, repo.Name)
+				updated[existing.Backend] = repo
+			}
+			continue
+		}
+
+		backend, err := NewBackend(configuration.CheckoutLocation, repo, mgr.Heartbeat)
+		if err != nil {
+			return nil, err
+		}
+		repos[repo.Name] = &Repository{Configuration: repo, Statistics: newStatistics(), Backend: backend, usage: &backendUsage{}}
+		started = append(started, repos[repo.Name])
+		if ok {
+			summary.Restarted = append(summary.Restarted, repo.Name)
+			stopped = append(stopped, existing)
+		} else {
+			summary.Added = append(summary.Added, repo.Name)
+		}
+	}
+	for name, repo := range current {
+		if _, ok := repos[name]; !ok {
+			summary.Removed = append(summary.Removed, name)
+			stopped = append(stopped, repo)
+		}
+	}
+
+	mgr.mutex.Lock()
+	mgr.Configuration = configuration
+	mgr.Repositories = repos
+	mgr.mutex.Unlock()
+
+	for _, repo := range stopped {
+		repo.Backend.Stop()
+		mgr.changes.forget(repo.Configuration.Name)
+		go discard(repo.Backend, repo.usage.watched, &repo.usage.readers)
+	}
+	for backend, repo := range updated {
+		backend.Reconfigure(repo)
+	}
+	for _, repo := range started {
+		mgr.watch(repo)
+	}
+	return summary, nil
+}
+
+// discard deletes the local copy of a stopped backend once its background process, if it was started, terminated and
+// once the requests which were reading it completed
+func discard(backend Backend, watched bool, readers *sync.WaitGroup) {
+	if watched {
+		<-backend.Done()
+	}
+	readers.Wait()
+	if err := backend.Discard(); err != nil {
+		slog.Error("local copy of a stopped repository cannot be deleted", "error", err)
+	}
+}
+
+// Definitions returns the configuration of the served repositories in configuration order
+func (mgr *Manager) Definitions() []*config.Repository {
+	mgr.mutex.RLock()
+	defer mgr.mutex.RUnlock()
+	return slices.Clone(mgr.Configuration.Configuration)
+}
+
+// Definition returns the configuration of the named repository
+func (mgr *Manager) Definition(name string) (*config.Repository, bool) {
+	r, ok := mgr.repository(name)
+	if !ok {
+		return nil, false
+	}
+	return r.Configuration, true
+}
+
+// Add starts serving a new repository, the repository is recorded in the state file if one is configured
+func (mgr *Manager) Add(repository *config.Repository) error {
+	return mgr.administrate(repository.Name, func(current []*config.Repository, i int) ([]*config.Repository, error) {
+		if i >= 0 {
+			return nil, fmt.Errorf("'%s' : %w", repository.Name, ErrRepositoryExists)
+		}
+		if err := repository.Validate(); err != nil {
+			return nil, fmt.Errorf("%w : %w", ErrInvalidRepository, err)
+		}
+		return append(current, repository), nil
+	}, func(state *config.RepositoryState) { state.Upsert(repository) })
+}
+
+// Update replaces the configuration of an existing repository, the repository is recorded in the state file if one is
+// configured
+func (mgr *Manager) Update(repository *config.Repository) error {
+	return mgr.administrate(repository.Name, func(current []*config.Repository, i int) ([]*config.Repository, error) {
+		if i < 0 {
+			return nil, fmt.Errorf("'%s' : %w", repository.Name, ErrRepositoryNotFound)
+		}
+		if err := repository.Validate(); err != nil {
+			return nil, fmt.Errorf("%w : %w", ErrInvalidRepository, err)
+		}
+		current[i] = repository
+		return current, nil
+	}, func(state *config.RepositoryState) { state.Upsert(repository) })
+}
+
+// Remove stops serving a repository, the deletion is recorded in the state file if one is configured
+func (mgr *Manager) Remove(name string) error {
+	return mgr.administrate(name, func(current []*config.Repository, i int) ([]*config.Repository, error) {
+		if i < 0 {
+			return nil, fmt.Errorf("'%s' : %w", name, ErrRepositoryNotFound)
+		}
+		return slices.Delete(current, i, i+1), nil
+	}, func(state *config.RepositoryState) { state.Remove(name) })
+}
+
+// Refresh requests the named repository to be updated without waiting for its next scheduled update
+func (mgr *Manager) Refresh(name string) error {
+	r, ok := mgr.repository(name)
+	if !ok {
+		return fmt.Errorf("'%s' : %w", name, ErrRepositoryNotFound)
+	}
+	r.Backend.Refresh()
+	return nil
+}
+
+// administrate applies a runtime change to the configured repositories : change receives a copy of the current
+// configuration along the index of the named repository (-1 if it is not configured) and returns the configuration to
+// apply. Once applied, the change is recorded in the state file using record
+func (mgr *Manager) administrate(name string, change func(current []*config.Repository, i int) ([]*config.Repository, error), record func(state *config.RepositoryState)) error {
+	mgr.updating.Lock()
+	defer mgr.updating.Unlock()
+
+	mgr.mutex.RLock()
+	configuration := *mgr.Configuration
+	mgr.mutex.RUnlock()
+
+	current := slices.Clone(configuration.Configuration)
+	updated, err := change(current, slices.IndexFunc(current, func(repo *config.Repository) bool { return repo.Name == name }))
+	if err != nil {
+		return err
+	}
+	configuration.Configuration = updated
+	if _, err := mgr.reload(&configuration); err != nil {
+		return fmt.Errorf("%w : %w", ErrInvalidRepository, err)
+	}
+
+	if len(configuration.StateFile) == 0 {
+		return nil
+	}
+	state, err := config.LoadState(configuration.StateFile)
+	if err == nil {
+		record(state)
+		err = state.Save(configuration.StateFile)
+	}
+	if err != nil {
+		return fmt.Errorf("'%s' was applied but cannot be persisted : %w", name, err)
+	}
+	return nil
+}
+
+// requiresNewBackend returns true if the storage of a repository changed, or the way it is checked out, in which case its
+// content cannot be updated in place
+func requiresNewBackend(current *config.Repository, updated *config.Repository) bool {
+	backendOf := func(repo *config.Repository) string {
+		if len(repo.Backend) == 0 {
+			return config.BackendGit
+		}
+		return repo.Backend
+	}
+	return backendOf(current) != backendOf(updated) || current.URL != updated.URL || current.Path != updated.Path ||
+		current.Depth != updated.Depth || current.SingleBranch != updated.SingleBranch || !slices.Equal(current.SparseCheckout, updated.SparseCheckout) ||
+		current.FollowsBranch() != updated.FollowsBranch()
+}
+
+// repository returns the named repository
+func (mgr *Manager) repository(name string) (*Repository, bool) {
+	mgr.mutex.RLock()
+	defer mgr.mutex.RUnlock()
+	r, ok := mgr.Repositories[name]
+	return r, ok
+}
+
+// acquire returns the named repository whose backend cannot be discarded until release is called
+func (mgr *Manager) acquire(name string) (r *Repository, release func(), ok bool) {
+	mgr.mutex.RLock()
+	defer mgr.mutex.RUnlock()
+	r, ok = mgr.Repositories[name]
+	if !ok {
+		return nil, nil, false
 	}
+	r.usage.readers.Add(1)
+	return r, r.usage.readers.Done, true
 }
 
-// Statistics returns underlying git repository access statistics
-func (mgr *Manager) Statistics() map[string]*Statistics {
-	stats := make(map[string]*Statistics)
+// Statistics returns a snapshot of the repositories access statistics
+func (mgr *Manager) Statistics() map[string]*StatisticsSnapshot {
+	mgr.mutex.RLock()
+	defer mgr.mutex.RUnlock()
+	stats := make(map[string]*StatisticsSnapshot)
 	for name, repo := range mgr.Repositories {
-		stats[name] = repo.Statistics
+		stats[name] = repo.Statistics.Snapshot()
 	}
 	return stats
 }
 
+// ClientActivity returns the files accessed by a client along the commit they were last served from, most recent first
+func (mgr *Manager) ClientActivity(clientID string) []*ClientActivity {
+	return mgr.activity.of(clientID)
+}
+
+// RecordStatus counts the responses with an unauthorized, not found or internal error status served for a repository
+func (mgr *Manager) RecordStatus(repository string, status int) {
+	if r, ok := mgr.repository(repository); ok {
+		r.Statistics.recordStatus(status)
+		mgr.modified.Store(true)
+	}
+}
+
 // ErrClientNotAllowed is returned whenever a client tries to access a repository it has not been whitelisted for
 var ErrClientNotAllowed = errors.New("client is not allowed to access the requested resource")
 
 // ErrRepositoryNotFound is returned whenever a client requests a repository which is not existing
 var ErrRepositoryNotFound = errors.New("the requested repository does not exist")
 
-// Get scans the target repository for the file pointed by the provided path
-func (mgr *Manager) Get(repository string, path string, clientID string) ([]byte, error) {
-	r, ok := mgr.Repositories[repository]
-	if !ok {
-		return nil, ErrRepositoryNotFound
+// ErrRepositoryExists is returned whenever a repository is created with the name of an existing repository
+var ErrRepositoryExists = errors.New("a repository with the same name already exists")
+
+// ErrInvalidRepository is returned whenever a repository configuration cannot be applied
+var ErrInvalidRepository = errors.New("the repository configuration is invalid")
+
+// ErrRepositoryUnavailable is returned whenever a client requests a repository which has not been checked out yet
+var ErrRepositoryUnavailable = errors.New("the requested repository is not available yet")
+
+// ErrRefNotFound is returned whenever a client requests a git reference which does not exist in the repository
+var ErrRefNotFound = errors.New("the requested reference does not exist")
+
+// ErrFileNotFound is returned whenever a client requests a file which does not exist in the repository
+var ErrFileNotFound = errors.New("the requested file does not exist")
+
+// ErrNotSupported is returned whenever a client requests an operation the repository backend cannot perform
+var ErrNotSupported = errors.New("the requested operation is not supported by the repository")
+
+// ErrNotADirectory is returned whenever a client requests the listing of a path which is not a directory
+var ErrNotADirectory = errors.New("the requested path is not a directory")
+
+// ErrUntrustedRevision is returned whenever a revision of a repository which only serves signed revisions is not signed
+// by one of its trusted keys
+var ErrUntrustedRevision = errors.New("the requested revision is not signed by a trusted key")
+
+// Get scans the target repository for the file pointed by the provided path.
+// If a ref (branch, tag or commit) is provided, the file is read as it exists at this ref otherwise the file is read
+// from the tracked branch
+func (mgr *Manager) Get(ctx context.Context, repository string, ref string, path string, clientID string) (*File, error) {
+	ctx, span := tracing.Start(ctx, "Manager.Get", tracing.String("repository.name", repository), tracing.String("repository.ref", ref), tracing.String("file.path", path))
+	defer span.End()
+
+	r, file, err := mgr.read(ctx, repository, ref, path, clientID)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
 	}
+	span.SetAttributes(tracing.String("repository.revision", file.Revision), tracing.Int("file.size", len(file.Content)))
+	r.Statistics.hit(clientID, file.Path, file.Revision)
+	mgr.activity.seen(&ClientActivity{ClientID: clientID, Repository: repository, Path: file.Path, Commit: file.Revision, SeenAt: time.Now()})
+	mgr.modified.Store(true)
+	return file, nil
+}
 
-	if !r.IsClientAllowed(clientID) {
-		return nil, ErrClientNotAllowed
+// Read reads a file in the same way as Get without recording a hit nor the client activity, it is meant for the
+// requests which inspect files, such as diffs, rather than serving them
+func (mgr *Manager) Read(ctx context.Context, repository string, ref string, path string, clientID string) (*File, error) {
+	ctx, span := tracing.Start(ctx, "Manager.Read", tracing.String("repository.name", repository), tracing.String("repository.ref", ref), tracing.String("file.path", path))
+	defer span.End()
+
+	_, file, err := mgr.read(ctx, repository, ref, path, clientID)
+	span.RecordError(err)
+	return file, err
+}
+
+// read reads a file from the requested repository provided that the client is allowed to access it
+func (mgr *Manager) read(ctx context.Context, repository string, ref string, path string, clientID string) (*Repository, *File, error) {
+	r, release, err := mgr.lookup(repository, clientID)
+	if err != nil {
+		return nil, nil, err
 	}
+	defer release()
 
-	if !r.Beholder.Active {
-		return nil, fmt.Errorf("'%s' cannot be checked out due to %w", repository, r.Statistics.LastError)
+	_, read := tracing.Start(ctx, "repository.read", tracing.String("repository.name", repository), tracing.String("file.path", path))
+	file, err := r.Backend.FileAt(ref, path)
+	read.RecordError(err)
+	read.End()
+	return r, file, err
+}
+
+// List returns the content of the directory pointed by the provided path.
+// If a ref (branch, tag or commit) is provided, the directory is listed as it exists at this ref otherwise it is listed
+// from the tracked branch
+func (mgr *Manager) List(repository string, ref string, path string, clientID string) ([]*Entry, error) {
+	r, release, err := mgr.lookup(repository, clientID)
+	if err != nil {
+		return nil, err
 	}
+	defer release()
 
-	contents, err := r.Beholder.File(path)
+	return r.Backend.List(ref, path)
+}
+
+// History returns the commits which modified the file pointed by the provided path, most recent first.
+// If a ref (branch, tag or commit) is provided, the history is walked from this ref otherwise it is walked from the
+// tracked branch
+func (mgr *Manager) History(repository string, ref string, path string, limit int, clientID string) ([]*CommitRef, error) {
+	r, release, err := mgr.lookup(repository, clientID)
 	if err != nil {
 		return nil, err
 	}
-	mgr.Repositories[repository].Statistics.HitCount++
-	return contents, nil
+	defer release()
+
+	return r.Backend.History(ref, path, limit)
+}
+
+// Watch subscribes to the changes of the provided file in the tracked branch, the state of the file in the currently
+// served snapshot is returned along the subscription. Subscriptions must be released using Unwatch
+func (mgr *Manager) Watch(repository string, path string, clientID string) (*Subscription, Change, error) {
+	r, release, err := mgr.lookup(repository, clientID)
+	if err != nil {
+		return nil, Change{}, err
+	}
+	defer release()
+
+	subscription, current := mgr.changes.subscribe(repository, path, func(path string) Change {
+		revision := r.Backend.Revision()
+		return Change{Repository: repository, Path: path, Revision: revision, Hash: r.Backend.Hash(revision, path)}
+	})
+	return subscription, current, nil
+}
+
+// Unwatch releases a subscription obtained from Watch
+func (mgr *Manager) Unwatch(subscription *Subscription) {
+	mgr.changes.unsubscribe(subscription)
+}
+
+// lookup returns the requested repository provided that it exists, is available and can be accessed by the client.
+// The repository backend is not discarded until release is called
+func (mgr *Manager) lookup(repository string, clientID string) (r *Repository, release func(), err error) {
+	r, release, ok := mgr.acquire(repository)
+	if !ok {
+		return nil, nil, ErrRepositoryNotFound
+	}
+
+	if !r.IsClientAllowed(clientID) {
+		release()
+		return nil, nil, ErrClientNotAllowed
+	}
+
+	if !r.Backend.Available() {
+		release()
+		if update := r.Statistics.lastUpdate(); update.LastError != nil {
+			return nil, nil, fmt.Errorf("'%s' cannot be checked out due to %w : %w", repository, update.LastError, ErrRepositoryUnavailable)
+		}
+		return nil, nil, ErrRepositoryUnavailable
+	}
+	return r, release, nil
+}
+
+// FindByURL returns the repositories cloned from any of the provided remote urls
+func (mgr *Manager) FindByURL(urls ...string) []*Repository {
+	remotes := make(map[string]bool)
+	for _, u := range urls {
+		if len(u) > 0 {
+			remotes[NormalizeURL(u)] = true
+		}
+	}
+
+	mgr.mutex.RLock()
+	defer mgr.mutex.RUnlock()
+	var found []*Repository
+	for _, repo := range mgr.Repositories {
+		if remotes[NormalizeURL(repo.Configuration.URL)] {
+			found = append(found, repo)
+		}
+	}
+	return found
+}
+
+// FindByLabel returns the repository designated by a Spring Cloud Config label.
+// Labels are matched against the repositories names first and then against their tracked branch, when several
+// repositories track the same branch the first one in configuration order is returned
+func (mgr *Manager) FindByLabel(label string) (*Repository, bool) {
+	mgr.mutex.RLock()
+	defer mgr.mutex.RUnlock()
+	if repo, ok := mgr.Repositories[label]; ok {
+		return repo, true
+	}
+
+	for _, configuration := range mgr.Configuration.Configuration {
+		repo := mgr.Repositories[configuration.Name]
+		if repo.TracksBranch(label, "") {
+			return repo, true
+		}
+	}
+	return nil, false
 }
 
-// listen reads the heartbeat channel for beholder events
-func (mgr *Manager) listen() {
-	for event := range mgr.Heartbeat {
-		mgr.Repositories[event.RepositoryName].Statistics.LastError = event.LastError
-		mgr.Repositories[event.RepositoryName].Statistics.NextUpdate = event.NextUpdate
-		mgr.Repositories[event.RepositoryName].Statistics.LastUpdate = event.LastUpdate
+// listen reads the heartbeat channel for backend events until the context is cancelled.
+// Events issued by backends which were replaced or removed by a reload are ignored
+func (mgr *Manager) listen(ctx context.Context) {
+	for {
+		var event UpdateEvent
+		select {
+		case event = <-mgr.Heartbeat:
+		case <-ctx.Done():
+			return
+		}
+
+		repo, release, ok := mgr.acquire(event.RepositoryName)
+		if !ok {
+			continue
+		}
+		if repo.Backend == event.backend {
+			repo.Statistics.updated(event)
+			mgr.changes.detect(event.RepositoryName, event.Revision, func(path string) string {
+				return repo.Backend.Hash(event.Revision, path)
+			})
+		}
+		release()
 	}
 }
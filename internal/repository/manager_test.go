@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/stretchr/testify/assert"
+)
+
+func localRepository(t *testing.T, name string, clients ...string) *configuration.Repository {
+	return &configuration.Repository{Name: name, Backend: configuration.BackendLocal, Path: t.TempDir(), Clients: clients}
+}
+
+func TestManagerReload(t *testing.T) {
+	kept, removed, moved := localRepository(t, "kept", "client"), localRepository(t, "removed"), localRepository(t, "moved")
+	mgr, err := NewManager(&configuration.Repositories{Configuration: []*configuration.Repository{kept, removed, moved}})
+	assert.NoError(t, err)
+	backend := mgr.Repositories["kept"].Backend
+	statistics := mgr.Repositories["kept"].Statistics
+	previous := mgr.Repositories["moved"].Backend
+
+	updatedKept := *kept
+	updatedKept.Clients = []string{"client", "newcomer"}
+	movedElsewhere := *moved
+	movedElsewhere.Path = t.TempDir()
+	added := localRepository(t, "added")
+
+	summary, err := mgr.Reload(&configuration.Repositories{Configuration: []*configuration.Repository{&updatedKept, &movedElsewhere, added}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"added"}, summary.Added)
+	assert.Equal(t, []string{"removed"}, summary.Removed)
+	assert.Equal(t, []string{"kept"}, summary.Updated)
+	assert.Equal(t, []string{"moved"}, summary.Restarted)
+
+	assert.Len(t, mgr.Repositories, 3)
+	assert.Same(t, backend, mgr.Repositories["kept"].Backend, "updated repositories must keep their backend")
+	assert.Same(t, statistics, mgr.Repositories["kept"].Statistics)
+	assert.True(t, mgr.Repositories["kept"].IsClientAllowed("newcomer"))
+	assert.Same(t, &updatedKept, backend.(*LocalBackend).config())
+	assert.NotSame(t, previous, mgr.Repositories["moved"].Backend)
+	assert.Equal(t, StatePending, mgr.Repositories["moved"].Statistics.Snapshot().State)
+}
+
+func TestManagerReloadRejectsInvalidBackend(t *testing.T) {
+	kept := localRepository(t, "kept")
+	mgr, err := NewManager(&configuration.Repositories{Configuration: []*configuration.Repository{kept}})
+	assert.NoError(t, err)
+
+	_, err = mgr.Reload(&configuration.Repositories{Configuration: []*configuration.Repository{{Name: "invalid", Backend: "svn"}}})
+	assert.Error(t, err)
+	assert.Len(t, mgr.Repositories, 1)
+	assert.Contains(t, mgr.Repositories, "kept")
+}
+
+func TestManagerIgnoresReplacedBackendEvents(t *testing.T) {
+	repo := localRepository(t, "repo")
+	mgr, err := NewManager(&configuration.Repositories{Configuration: []*configuration.Repository{repo}})
+	assert.NoError(t, err)
+	go mgr.listen(context.Background())
+
+	stale := NewBeholder("", repo, mgr.Heartbeat)
+	mgr.Heartbeat <- UpdateEvent{RepositoryName: "repo", State: StateUnavailable, backend: stale}
+	mgr.Heartbeat <- UpdateEvent{RepositoryName: "repo", State: StateHealthy, backend: mgr.Repositories["repo"].Backend}
+	mgr.Heartbeat <- UpdateEvent{RepositoryName: "unknown", State: StateHealthy}
+
+	assert.Equal(t, StateHealthy, mgr.Statistics()["repo"].State)
+}
+
+func TestManagerAdministration(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.yml")
+	configured := localRepository(t, "configured")
+	mgr, err := NewManager(&configuration.Repositories{Configuration: []*configuration.Repository{configured}, StateFile: stateFile})
+	assert.NoError(t, err)
+
+	added := localRepository(t, "added")
+	assert.NoError(t, mgr.Add(added))
+	assert.ErrorIs(t, mgr.Add(added), ErrRepositoryExists)
+	assert.ErrorIs(t, mgr.Add(&configuration.Repository{Name: "invalid", Backend: "svn"}), ErrInvalidRepository)
+	assert.Equal(t, []*configuration.Repository{configured, added}, mgr.Definitions())
+
+	updated := *added
+	updated.Clients = []string{"client"}
+	assert.NoError(t, mgr.Update(&updated))
+	assert.ErrorIs(t, mgr.Update(localRepository(t, "unknown")), ErrRepositoryNotFound)
+	assert.True(t, mgr.Repositories["added"].IsClientAllowed("client"))
+
+	assert.NoError(t, mgr.Remove("configured"))
+	assert.ErrorIs(t, mgr.Remove("configured"), ErrRepositoryNotFound)
+	assert.NotContains(t, mgr.Repositories, "configured")
+
+	state, err := configuration.LoadState(stateFile)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"configured"}, state.Removed)
+	assert.Len(t, state.Repositories, 1)
+	assert.Equal(t, []string{"client"}, state.Repositories[0].Clients)
+}
+
+func TestManagerShutdown(t *testing.T) {
+	repo := localRepository(t, "repo")
+	mgr, err := NewManager(&configuration.Repositories{Configuration: []*configuration.Repository{repo}})
+	assert.NoError(t, err)
+	mgr.Start(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, mgr.Shutdown(ctx))
+	_, open := <-mgr.Repositories["repo"].Backend.Done()
+	assert.False(t, open)
+}
+
+func TestManagerStopsWithContext(t *testing.T) {
+	repo := localRepository(t, "repo")
+	mgr, err := NewManager(&configuration.Repositories{Configuration: []*configuration.Repository{repo}})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	mgr.Start(ctx)
+	cancel()
+
+	select {
+	case <-mgr.Repositories["repo"].Backend.Done():
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "the backend must stop once the context is cancelled")
+	}
+}
+
+func TestManagerDiscardsUnusedCheckouts(t *testing.T) {
+	removed := &configuration.Repository{Name: "removed", URL: "https://example.com/removed.git"}
+	mgr, err := NewManager(&configuration.Repositories{CheckoutLocation: t.TempDir(), Configuration: []*configuration.Repository{removed}})
+	assert.NoError(t, err)
+	checkout := mgr.Repositories["removed"].Backend.(*Beholder).checkoutLocation
+	assert.NoError(t, os.MkdirAll(checkout, 0o755))
+
+	_, release, _ := mgr.acquire("removed")
+	assert.NoError(t, mgr.Remove("removed"))
+	assert.Never(t, func() bool {
+		_, err := os.Stat(checkout)
+		return os.IsNotExist(err)
+	}, 100*time.Millisecond, 10*time.Millisecond, "local copies are kept while being read")
+
+	release()
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(checkout)
+		return os.IsNotExist(err)
+	}, 5*time.Second, 10*time.Millisecond, "the local copy of backends which were never started is deleted")
+}
+
+func TestManagerDiscardsStoppedCheckouts(t *testing.T) {
+	repo := newTestRepository(t)
+	repo.commit("first", map[string]string{"config/app.yml": "version: 1"})
+	removed := &configuration.Repository{Name: "removed", URL: repo.dir}
+	kept := &configuration.Repository{Name: "kept", URL: repo.dir}
+	mgr, err := NewManager(&configuration.Repositories{CheckoutLocation: t.TempDir(), Configuration: []*configuration.Repository{removed, kept}})
+	assert.NoError(t, err)
+	mgr.Start(context.Background())
+	for _, name := range []string{"removed", "kept"} {
+		assert.Eventually(t, func() bool { return mgr.Repositories[name].Backend.Available() }, 5*time.Second, 10*time.Millisecond)
+	}
+	removedCheckout := mgr.Repositories["removed"].Backend.(*Beholder).checkoutLocation
+	keptCheckout := mgr.Repositories["kept"].Backend.(*Beholder).checkoutLocation
+
+	assert.NoError(t, mgr.Remove("removed"))
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(removedCheckout)
+		return os.IsNotExist(err)
+	}, 5*time.Second, 10*time.Millisecond, "the local copy of removed repositories is deleted")
+	assert.DirExists(t, keptCheckout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, mgr.Shutdown(ctx))
+	assert.DirExists(t, keptCheckout, "local copies are kept on shutdown")
+}
+
+func TestManagerReadIsNotCounted(t *testing.T) {
+	repo := localRepository(t, "repo", "client")
+	assert.NoError(t, os.WriteFile(filepath.Join(repo.Path, "app.yml"), []byte("version: 1"), 0600))
+	mgr, err := NewManager(&configuration.Repositories{Configuration: []*configuration.Repository{repo}})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mgr.Start(ctx)
+	assert.Eventually(t, func() bool { return mgr.Repositories["repo"].Backend.Available() }, 5*time.Second, 10*time.Millisecond)
+
+	file, err := mgr.Read(ctx, "repo", "", "app.yml", "client")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 1", string(file.Content))
+	_, err = mgr.Read(ctx, "repo", "", "app.yml", "other")
+	assert.ErrorIs(t, err, ErrClientNotAllowed)
+
+	statistics := mgr.Statistics()["repo"]
+	assert.Zero(t, statistics.HitCount)
+	assert.Empty(t, statistics.LastServedCommit)
+	assert.Empty(t, mgr.ClientActivity("client"))
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/metrics"
+)
+
+const (
+	operationClone      = "clone"      // operationClone designates the creation of the local copy of a git repository
+	operationPull       = "pull"       // operationPull designates the update of the local copy of a git repository
+	operationSubmodules = "submodules" // operationSubmodules designates the update of the submodules of a git repository
+	operationScan       = "scan"       // operationScan designates the scan of a local directory
+)
+
+// updateDurationBuckets are the upper bounds, in seconds, of the repository update duration buckets
+var updateDurationBuckets = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+var updateDuration = metrics.NewHistogramVec("configserver_repository_update_duration_seconds", "Duration of the repository clone, pull, submodules and scan operations", updateDurationBuckets, "repository", "operation")
+
+var updateFailures = metrics.NewCounterVec("configserver_repository_update_failures_total", "Number of failed repository clone, pull, submodules and scan operations", "repository", "operation")
+
+func init() {
+	metrics.Default.Register(updateDuration, updateFailures)
+}
+
+// observeUpdate records the duration and the outcome of a repository operation, operations aborted because the
+// repository is no longer watched are not counted as failures
+func observeUpdate(ctx context.Context, repository string, operation string, start time.Time, err *error) {
+	updateDuration.ObserveDuration(start, repository, operation)
+	if *err != nil && ctx.Err() == nil {
+		updateFailures.Inc(repository, operation)
+	}
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// statisticsSaveInterval is the interval at which the statistics are saved once the manager is started
+const statisticsSaveInterval = time.Minute
+
+// savedStatistics is the content of the statistics file
+type savedStatistics struct {
+	Repositories map[string]*StatisticsSnapshot `json:"repositories"`
+	Clients      []*ClientActivity              `json:"clients"`
+}
+
+// PersistStatistics restores the statistics and the clients activity saved in the provided file, a missing file is
+// ignored. Once the manager is started, they are saved to the file every minute if they changed and when the manager
+// is shut down.
+// Must be called before Start
+func (mgr *Manager) PersistStatistics(path string) error {
+	mgr.statisticsFile = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("'%s' statistics cannot be loaded : %w", path, err)
+	}
+
+	saved := &savedStatistics{}
+	if err := json.Unmarshal(data, saved); err != nil {
+		return fmt.Errorf("'%s' cannot unmarshal statistics : %w", path, err)
+	}
+	mgr.mutex.RLock()
+	for name, statistics := range saved.Repositories {
+		if repo, ok := mgr.Repositories[name]; ok {
+			repo.Statistics.restore(statistics)
+		}
+	}
+	mgr.mutex.RUnlock()
+	for _, activity := range saved.Clients {
+		mgr.activity.seen(activity)
+	}
+	return nil
+}
+
+// saveStatistics writes the statistics and the clients activity to the statistics file if one is configured. The file
+// is replaced atomically so that a crash never leaves truncated statistics behind
+func (mgr *Manager) saveStatistics() error {
+	if len(mgr.statisticsFile) == 0 {
+		return nil
+	}
+	mgr.saving.Lock()
+	defer mgr.saving.Unlock()
+
+	data, err := json.Marshal(&savedStatistics{Repositories: mgr.Statistics(), Clients: mgr.activity.of("")})
+	if err != nil {
+		return fmt.Errorf("'%s' cannot marshal statistics : %w", mgr.statisticsFile, err)
+	}
+
+	if err := writeFileAtomically(mgr.statisticsFile, data); err != nil {
+		return fmt.Errorf("'%s' statistics cannot be saved : %w", mgr.statisticsFile, err)
+	}
+	return nil
+}
+
+// writeFileAtomically replaces the content of a file, readers never observe a partially written file
+func writeFileAtomically(name string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// persist saves the statistics periodically, when they changed, until the context is cancelled. The expired clients
+// activity is forgotten meanwhile
+func (mgr *Manager) persist(ctx context.Context) {
+	ticker := time.NewTicker(statisticsSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			pruned := mgr.activity.prune()
+			if !mgr.modified.Swap(false) && !pruned {
+				continue
+			}
+			if err := mgr.saveStatistics(); err != nil {
+				mgr.modified.Store(true)
+				slog.Error("statistics cannot be saved", "error", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
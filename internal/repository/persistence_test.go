@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatisticsArePersisted(t *testing.T) {
+	repo := localRepository(t, "repo", "client")
+	assert.NoError(t, os.WriteFile(filepath.Join(repo.Path, "app.yml"), []byte("version: 1"), 0600))
+	path := filepath.Join(t.TempDir(), "home", "statistics.json")
+
+	mgr, err := NewManager(&configuration.Repositories{Configuration: []*configuration.Repository{repo}})
+	assert.NoError(t, err)
+	assert.NoError(t, mgr.PersistStatistics(path), "missing statistics files are ignored")
+	mgr.Start(context.Background())
+	assert.Eventually(t, func() bool { return mgr.Repositories["repo"].Backend.Available() }, 5*time.Second, 10*time.Millisecond)
+	file, err := mgr.Get(context.Background(), "repo", "", "app.yml", "client")
+	assert.NoError(t, err)
+	_, err = mgr.Get(context.Background(), "repo", "", "./config/../app.yml", "client")
+	assert.NoError(t, err)
+	mgr.RecordStatus("repo", 404)
+	assert.NoError(t, mgr.Shutdown(context.Background()))
+
+	restored, err := NewManager(&configuration.Repositories{Configuration: []*configuration.Repository{repo}})
+	assert.NoError(t, err)
+	assert.NoError(t, restored.PersistStatistics(path))
+	statistics := restored.Statistics()["repo"]
+	assert.Equal(t, int64(2), statistics.HitCount)
+	assert.Equal(t, map[string]int64{"client": 2}, statistics.ClientHits)
+	assert.Equal(t, map[string]int64{"app.yml": 2}, statistics.PathHits, "hits are counted per cleaned path")
+	assert.Equal(t, file.Revision, statistics.LastServedCommit)
+	assert.Equal(t, int64(1), statistics.NotFound)
+	assert.Equal(t, StatePending, statistics.State, "the update status is not restored")
+
+	activity := restored.ClientActivity("client")
+	assert.Len(t, activity, 1)
+	assert.Equal(t, "repo", activity[0].Repository)
+	assert.Equal(t, "app.yml", activity[0].Path)
+	assert.Equal(t, file.Revision, activity[0].Commit)
+	assert.Empty(t, restored.ClientActivity("unknown"))
+}
+
+func TestCorruptedStatistics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "statistics.json")
+	assert.NoError(t, os.WriteFile(path, []byte("{"), 0600))
+	mgr, err := NewManager(&configuration.Repositories{})
+	assert.NoError(t, err)
+	assert.Error(t, mgr.PersistStatistics(path))
+}
+
+func TestClientActivityKeepsLatestAccess(t *testing.T) {
+	now := time.Now()
+	tracker := newActivityTracker()
+	tracker.seen(&ClientActivity{ClientID: "client", Repository: "repo", Path: "app.yml", Commit: "new", SeenAt: now})
+	tracker.seen(&ClientActivity{ClientID: "client", Repository: "repo", Path: "app.yml", Commit: "old", SeenAt: now.Add(-time.Hour)})
+	tracker.seen(&ClientActivity{ClientID: "client", Repository: "repo", Path: "db.yml", Commit: "old", SeenAt: now.Add(-time.Hour)})
+	tracker.seen(&ClientActivity{ClientID: "other", Repository: "repo", Path: "app.yml", Commit: "new", SeenAt: now})
+
+	activity := tracker.of("client")
+	assert.Len(t, activity, 2)
+	assert.Equal(t, "new", activity[0].Commit)
+	assert.Equal(t, "db.yml", activity[1].Path)
+	assert.Len(t, tracker.of(""), 3)
+}
+
+func TestClientActivityIsBounded(t *testing.T) {
+	now := time.Now()
+	tracker := newActivityTracker()
+	tracker.capacity = 10
+	tracker.seen(&ClientActivity{ClientID: "client", Repository: "repo", Path: "expired.yml", SeenAt: now.Add(-activityRetention - time.Hour)})
+	assert.Empty(t, tracker.of(""), "expired accesses are not recorded")
+
+	for i := 0; i <= 10; i++ {
+		tracker.seen(&ClientActivity{ClientID: "client", Repository: "repo", Path: fmt.Sprintf("%02d.yml", i), SeenAt: now.Add(time.Duration(i-10) * time.Second)})
+	}
+	activity := tracker.of("")
+	assert.Len(t, activity, 9, "the oldest accesses are forgotten once the capacity is exceeded")
+	assert.Equal(t, "02.yml", activity[len(activity)-1].Path)
+
+	tracker.retention = 5500 * time.Millisecond
+	assert.True(t, tracker.prune())
+	assert.Len(t, tracker.of(""), 6)
+	assert.False(t, tracker.prune())
+}
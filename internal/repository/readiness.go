@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultStaleAfter is the delay past its scheduled update after which a repository is considered stale when the
+// configuration does not provide one
+const defaultStaleAfter = 5 * time.Minute
+
+// Readiness describes whether a repository can be relied upon to serve up to date content
+type Readiness struct {
+	Name     string `json:"name"`
+	Ready    bool   `json:"ready"`
+	Optional bool   `json:"optional"`         // optional repositories do not affect the server readiness
+	Reason   string `json:"reason,omitempty"` // why the repository is not ready
+}
+
+// Readiness reports the readiness of each repository, the server is ready when all the repositories which are not
+// optional are. A repository is ready once its initial checkout completed, as long as its backend is still running
+// and its updates are not overdue by more than the configured staleness delay
+func (mgr *Manager) Readiness() (ready bool, repositories []*Readiness) {
+	mgr.mutex.RLock()
+	defer mgr.mutex.RUnlock()
+
+	staleAfter := time.Duration(mgr.Configuration.StaleAfterSeconds) * time.Second
+	if staleAfter <= 0 {
+		staleAfter = defaultStaleAfter
+	}
+
+	ready, repositories = true, make([]*Readiness, 0, len(mgr.Configuration.Configuration))
+	for _, configuration := range mgr.Configuration.Configuration {
+		repo := mgr.Repositories[configuration.Name]
+		readiness := &Readiness{Name: configuration.Name, Optional: configuration.Optional, Reason: repo.unreadiness(staleAfter)}
+		readiness.Ready = len(readiness.Reason) == 0
+		ready = ready && (readiness.Ready || readiness.Optional)
+		repositories = append(repositories, readiness)
+	}
+	return ready, repositories
+}
+
+// unreadiness returns why the repository is not ready or an empty string if it is
+func (repo *Repository) unreadiness(staleAfter time.Duration) string {
+	select {
+	case <-repo.Backend.Done():
+		return "the repository is no longer watched"
+	default:
+	}
+
+	update := repo.Statistics.lastUpdate()
+	switch update.State {
+	case StatePending:
+		return "the repository was not checked out yet"
+	case StateUnavailable:
+		return "the repository could not be checked out"
+	}
+
+	if overdue := time.Since(update.NextUpdate); overdue > staleAfter {
+		return fmt.Sprintf("the repository update is overdue by %s", overdue.Truncate(time.Second))
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManagerReadiness(t *testing.T) {
+	critical := localRepository(t, "critical")
+	optional := &configuration.Repository{Name: "optional", URL: "file://" + filepath.Join(t.TempDir(), "missing"), Optional: true, Retry: &configuration.RetryPolicy{InitialDelaySeconds: 3600}}
+	mgr, err := NewManager(&configuration.Repositories{CheckoutLocation: t.TempDir(), Configuration: []*configuration.Repository{critical, optional}})
+	assert.NoError(t, err)
+
+	ready, repositories := mgr.Readiness()
+	assert.False(t, ready)
+	assert.Equal(t, &Readiness{Name: "critical", Reason: "the repository was not checked out yet"}, repositories[0])
+
+	mgr.Start(context.Background())
+	assert.Eventually(t, func() bool {
+		ready, repositories = mgr.Readiness()
+		return ready && len(repositories[1].Reason) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, &Readiness{Name: "critical", Ready: true}, repositories[0])
+	assert.Equal(t, &Readiness{Name: "optional", Optional: true, Reason: "the repository could not be checked out"}, repositories[1])
+
+	assert.NoError(t, mgr.Shutdown(context.Background()))
+	ready, repositories = mgr.Readiness()
+	assert.False(t, ready)
+	assert.Equal(t, "the repository is no longer watched", repositories[0].Reason)
+}
+
+func TestStaleRepositoriesAreNotReady(t *testing.T) {
+	backend, err := NewLocalBackend(localRepository(t, "stale"), make(chan UpdateEvent))
+	assert.NoError(t, err)
+	repo := &Repository{Backend: backend, Statistics: newStatistics()}
+	repo.Statistics.updated(UpdateEvent{State: StateDegraded, NextUpdate: time.Now().Add(-time.Minute)})
+	assert.Empty(t, repo.unreadiness(2*time.Minute))
+	assert.Equal(t, "the repository update is overdue by 1m0s", repo.unreadiness(30*time.Second))
+}
@@ -3,6 +3,9 @@
 package repository
 
 import (
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/fredjeck/configserver/internal/configuration"
@@ -12,28 +15,46 @@ const logKeyRepositoryName = "repository.name"
 const logKeyCheckoutLocation = "repository.checkout_location"
 const logKeyRepositoryURL = "repository.url"
 
+// BranchRefPrefix is the prefix of the fully qualified branch references
+const BranchRefPrefix = "refs/heads/"
+const tagRefPrefix = "refs/tags/"
+
 // Repository is a handle on a git repository
 type Repository struct {
 	Configuration *configuration.Repository // repository configuration
 	Statistics    *Statistics               // repository access statistics
-	Beholder      *Beholder                 // beholder process managing the repository
+	Backend       Backend                   // storage holding the repository files
+	usage         *backendUsage             // shared by the successive definitions served by the same backend
 }
 
-// Statistics allows to maintain some stats about repository access
-type Statistics struct {
-	HitCount   int64     `json:"hitCount"`
-	LastUpdate time.Time `json:"lastUpdate"`
-	NextUpdate time.Time `json:"nextUpdate"`
-	LastError  error     `json:"lastError"`
+// backendUsage tracks whether a backend was started and the requests reading it, so that its local copy is only
+// deleted once it is no longer used
+type backendUsage struct {
+	watched bool           // true once the backend was started with Watch, guarded by the manager updating lock
+	readers sync.WaitGroup // requests reading the backend, see Manager.acquire
 }
 
+// State describes the health of a repository's local copy
+type State string
+
+const (
+	StatePending     State = "pending"     // StatePending is the state of repositories which were not checked out yet
+	StateHealthy     State = "healthy"     // StateHealthy is the state of repositories whose latest update succeeded
+	StateDegraded    State = "degraded"    // StateDegraded is the state of repositories whose latest update failed, the last good copy is served
+	StateUnavailable State = "unavailable" // StateUnavailable is the state of repositories which could never be checked out
+)
+
 // UpdateEvent as generated by beholders
 type UpdateEvent struct {
-	RepositoryName string
-	LastUpdate     time.Time
-	NextUpdate     time.Time
-	LastError      error
-	Active         bool
+	RepositoryName      string
+	LastUpdate          time.Time
+	NextUpdate          time.Time
+	LastError           error
+	State               State
+	ConsecutiveFailures int
+	Revision            string  // commit served once the update completed, empty if the repository is not available
+	RejectedRevision    string  // revision the update refused to serve as it is not signed by a trusted key
+	backend             Backend // backend which issued the event
 }
 
 // IsClientAllowed verifies if the provided ClientID is allowed to access the repository based on its configuration
@@ -45,3 +66,48 @@ func (repo *Repository) IsClientAllowed(ClientID string) bool {
 	}
 	return false
 }
+
+// TracksBranch verifies if the provided branch is the one monitored by the repository.
+// Repositories without an explicit branch configuration track the remote's default branch which, when unknown, is assumed
+// to be main. Repositories tracking a tag or a commit do not track any branch
+func (repo *Repository) TracksBranch(branch string, defaultBranch string) bool {
+	if !repo.Configuration.FollowsBranch() {
+		return false
+	}
+	tracked := repo.Configuration.Branch
+	if len(tracked) == 0 {
+		tracked = defaultBranch
+	}
+	if len(tracked) == 0 {
+		tracked = "main"
+	}
+	return tracked == branch
+}
+
+// TracksRef verifies if the provided reference i.e refs/heads/main or refs/tags/v2.1.0 may change the revision served by
+// the repository : either the tracked branch or a tag matching the tracked tag pattern
+func (repo *Repository) TracksRef(ref string, defaultBranch string) bool {
+	if branch, ok := strings.CutPrefix(ref, BranchRefPrefix); ok {
+		return repo.TracksBranch(branch, defaultBranch)
+	}
+	if tag, ok := strings.CutPrefix(ref, tagRefPrefix); ok && len(repo.Configuration.Tag) > 0 {
+		return newTagSelector(repo.Configuration.Tag).matches(tag)
+	}
+	return false
+}
+
+// NormalizeURL reduces a git remote url to a host/path form so that the different flavors used to designate the same
+// remote (https, ssh, scp-like syntax, with or without the .git suffix) can be compared
+func NormalizeURL(remote string) string {
+	remote = strings.TrimSpace(remote)
+	if u, err := url.Parse(remote); err == nil && len(u.Host) > 0 {
+		remote = u.Hostname() + "/" + u.Path
+	} else if at := strings.Index(remote, "@"); at >= 0 && strings.Contains(remote[at:], ":") {
+		// scp-like syntax i.e git@github.com:owner/repository.git
+		remote = strings.Replace(remote[at+1:], ":", "/", 1)
+	}
+
+	remote = strings.ToLower(remote)
+	remote = strings.TrimSuffix(strings.Trim(remote, "/"), ".git")
+	return strings.ReplaceAll(remote, "//", "/")
+}
@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// version is a semantic version, see https://semver.org. Build metadata is ignored as it does not affect precedence
+type version struct {
+	major, minor, patch int
+	prerelease          []string
+}
+
+// wildcard designates the version components which are omitted or replaced by x or * in a version range
+const wildcard = -1
+
+// parseVersion parses a semantic version optionally prefixed by v i.e v2.1.0 or 2.1.0-rc.1, missing minor and patch
+// components default to 0
+func parseVersion(s string) (*version, bool) {
+	v, err := parsePartialVersion(s)
+	if err != nil || v.major == wildcard {
+		return nil, false
+	}
+	v.minor, v.patch = max(v.minor, 0), max(v.patch, 0)
+	return v, true
+}
+
+// parsePartialVersion parses a version whose components may be omitted or replaced by x or *, such components are
+// set to wildcard
+func parsePartialVersion(s string) (*version, error) {
+	s = strings.TrimPrefix(s, "v")
+	s, _, _ = strings.Cut(s, "+")
+	s, prerelease, hasPrerelease := strings.Cut(s, "-")
+
+	v := &version{major: wildcard, minor: wildcard, patch: wildcard}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("'%s' has too many components", s)
+	}
+	components := []*int{&v.major, &v.minor, &v.patch}
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || (len(part) > 1 && part[0] == '0') {
+			return nil, fmt.Errorf("'%s' is not a valid version component", part)
+		}
+		*components[i] = n
+	}
+
+	if hasPrerelease {
+		if v.patch == wildcard {
+			return nil, fmt.Errorf("'%s' pre-release designates a partial version", prerelease)
+		}
+		v.prerelease = strings.Split(prerelease, ".")
+		for _, identifier := range v.prerelease {
+			if len(identifier) == 0 {
+				return nil, fmt.Errorf("'%s' is not a valid pre-release", prerelease)
+			}
+		}
+	}
+	return v, nil
+}
+
+// compare returns -1, 0 or +1 depending on whether v precedes, equals or follows o
+func (v *version) compare(o *version) int {
+	if c := cmp.Or(cmp.Compare(v.major, o.major), cmp.Compare(v.minor, o.minor), cmp.Compare(v.patch, o.patch)); c != 0 {
+		return c
+	}
+	// A pre-release version precedes the associated normal version
+	switch {
+	case len(v.prerelease) == 0 && len(o.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(o.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.prerelease) && i < len(o.prerelease); i++ {
+		a, aErr := strconv.Atoi(v.prerelease[i])
+		b, bErr := strconv.Atoi(o.prerelease[i])
+		var c int
+		switch {
+		case aErr == nil && bErr == nil:
+			c = cmp.Compare(a, b)
+		case aErr == nil:
+			c = -1 // numeric identifiers have a lower precedence than alphanumeric ones
+		case bErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(v.prerelease[i], o.prerelease[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(v.prerelease), len(o.prerelease))
+}
+
+// sameRelease returns true if both versions designate the same major, minor and patch
+func (v *version) sameRelease(o *version) bool {
+	return v.major == o.major && v.minor == o.minor && v.patch == o.patch
+}
+
+// comparator is a single version constraint
+type comparator struct {
+	operator string // one of =, !=, >, >=, < or <=
+	version  *version
+}
+
+// matches returns true if the version satisfies the constraint
+func (c *comparator) matches(v *version) bool {
+	r := v.compare(c.version)
+	switch c.operator {
+	case "!=":
+		return r != 0
+	case ">":
+		return r > 0
+	case ">=":
+		return r >= 0
+	case "<":
+		return r < 0
+	case "<=":
+		return r <= 0
+	default:
+		return r == 0
+	}
+}
+
+// versionRange is a semantic version range as understood by npm i.e ^2.1.0, ~2.1, 2.x, >=2.0.0 <3.0.0 || >=4.0.0.
+// A version is in the range if it satisfies all the comparators of one of the alternatives
+type versionRange [][]*comparator
+
+// errNotARange is returned when parsing an expression which is not a version range
+var errNotARange = errors.New("not a version range")
+
+// parseVersionRange parses a version range, alternatives are separated by || and comparators by spaces or commas
+func parseVersionRange(s string) (versionRange, error) {
+	var r versionRange
+	for _, alternative := range strings.Split(s, "||") {
+		tokens := strings.FieldsFunc(alternative, func(c rune) bool { return c == ' ' || c == ',' })
+		comparators := []*comparator{}
+		for i := 0; i < len(tokens); i++ {
+			token := tokens[i]
+			// Operators may be separated from their version i.e >= 2.0.0
+			if strings.Trim(token, "<>=!~^") == "" && i+1 < len(tokens) {
+				i++
+				token += tokens[i]
+			}
+			parsed, err := parseComparator(token)
+			if err != nil {
+				return nil, err
+			}
+			comparators = append(comparators, parsed...)
+		}
+		if len(comparators) == 0 {
+			return nil, errNotARange
+		}
+		r = append(r, comparators)
+	}
+	return r, nil
+}
+
+// parseComparator converts a constraint to its equivalent comparators, partial versions, tilde and caret ranges
+// being expanded to lower and upper bounds
+func parseComparator(token string) ([]*comparator, error) {
+	operator := ""
+	for _, candidate := range []string{"!=", ">=", "<=", ">", "<", "=", "~", "^"} {
+		if strings.HasPrefix(token, candidate) {
+			operator = candidate
+			break
+		}
+	}
+	v, err := parsePartialVersion(strings.TrimPrefix(token, operator))
+	if err != nil {
+		return nil, fmt.Errorf("'%s' : %w", token, errNotARange)
+	}
+
+	floor := &version{major: max(v.major, 0), minor: max(v.minor, 0), patch: max(v.patch, 0), prerelease: v.prerelease}
+	// ceiling returns the first version following the range designated by the partial version
+	ceiling := func() *version {
+		switch {
+		case v.major == wildcard:
+			return nil
+		case v.minor == wildcard:
+			return &version{major: v.major + 1}
+		default:
+			return &version{major: v.major, minor: v.minor + 1}
+		}
+	}
+	between := func(low *version, high *version) []*comparator {
+		comparators := []*comparator{{">=", low}}
+		if high != nil {
+			comparators = append(comparators, &comparator{"<", high})
+		}
+		return comparators
+	}
+
+	switch {
+	case operator == "~":
+		if v.minor == wildcard {
+			return between(floor, ceiling()), nil
+		}
+		return between(floor, &version{major: floor.major, minor: floor.minor + 1}), nil
+	case operator == "^":
+		switch {
+		case v.major == wildcard:
+			return between(floor, nil), nil
+		case v.major > 0 || v.minor == wildcard:
+			return between(floor, &version{major: v.major + 1}), nil
+		case v.minor > 0 || v.patch == wildcard:
+			return between(floor, &version{minor: v.minor + 1}), nil
+		default:
+			return between(floor, &version{patch: v.patch + 1}), nil
+		}
+	case v.patch != wildcard:
+		if operator == "" {
+			operator = "="
+		}
+		return []*comparator{{operator, floor}}, nil
+	}
+
+	// Partial versions designate all the versions they are a prefix of
+	switch operator {
+	case "", "=":
+		return between(floor, ceiling()), nil
+	case "!=":
+		return nil, fmt.Errorf("'%s' : partial versions cannot be excluded : %w", token, errNotARange)
+	case ">":
+		if next := ceiling(); next != nil {
+			return []*comparator{{">=", next}}, nil
+		}
+		return []*comparator{{"<", floor}}, nil
+	case "<=":
+		if next := ceiling(); next != nil {
+			return []*comparator{{"<", next}}, nil
+		}
+		return between(floor, nil), nil
+	default:
+		return []*comparator{{operator, floor}}, nil
+	}
+}
+
+// contains returns true if the version is in the range. Pre-release versions are only considered by the alternatives
+// having a comparator designating the same major, minor and patch with a pre-release
+func (r versionRange) contains(v *version) bool {
+	for _, comparators := range r {
+		matches, prerelease := true, len(v.prerelease) == 0
+		for _, c := range comparators {
+			matches = matches && c.matches(v)
+			prerelease = prerelease || (len(c.version.prerelease) > 0 && c.version.sameRelease(v))
+		}
+		if matches && prerelease {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVersion(t *testing.T) {
+	v, ok := parseVersion("v2.1.3-rc.1+build.5")
+	assert.True(t, ok)
+	assert.Equal(t, &version{major: 2, minor: 1, patch: 3, prerelease: []string{"rc", "1"}}, v)
+
+	v, ok = parseVersion("2.1")
+	assert.True(t, ok)
+	assert.Equal(t, &version{major: 2, minor: 1}, v)
+
+	for _, invalid := range []string{"", "latest", "release-2.1", "2.1.0.4", "02.1.0", "2.1.0-", "*"} {
+		_, ok := parseVersion(invalid)
+		assert.False(t, ok, invalid)
+	}
+}
+
+func TestVersionPrecedence(t *testing.T) {
+	ordered := []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.1.0", "2.0.0"}
+	for i := 1; i < len(ordered); i++ {
+		previous, _ := parseVersion(ordered[i-1])
+		current, _ := parseVersion(ordered[i])
+		assert.Equal(t, -1, previous.compare(current), "%s < %s", ordered[i-1], ordered[i])
+		assert.Equal(t, 1, current.compare(previous), "%s > %s", ordered[i], ordered[i-1])
+	}
+	v, _ := parseVersion("v1.0.0+build")
+	o, _ := parseVersion("1.0.0")
+	assert.Equal(t, 0, v.compare(o), "build metadata is ignored")
+}
+
+func TestVersionRange(t *testing.T) {
+	tests := []struct {
+		versionRange string
+		matching     []string
+		notMatching  []string
+	}{
+		{"v2.*", []string{"2.0.0", "v2.9.1"}, []string{"1.9.9", "3.0.0", "2.1.0-rc.1"}},
+		{"2.1.x", []string{"2.1.0", "2.1.9"}, []string{"2.0.9", "2.2.0"}},
+		{"^2.1.3", []string{"2.1.3", "2.9.0"}, []string{"2.1.2", "3.0.0"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"~2.1", []string{"2.1.0", "2.1.7"}, []string{"2.2.0", "2.0.9"}},
+		{"~2", []string{"2.0.0", "2.5.0"}, []string{"3.0.0"}},
+		{">=2.0.0 <3.0.0", []string{"2.0.0", "2.9.9"}, []string{"1.9.9", "3.0.0", "3.0.0-rc.1"}},
+		{">= 2.0.0, < 3", []string{"2.0.0", "2.9.9"}, []string{"3.0.0"}},
+		{">2", []string{"3.0.0"}, []string{"2.9.9"}},
+		{"<=2.1", []string{"2.1.9"}, []string{"2.2.0"}},
+		{"<2.1.0 || >=3.0.0", []string{"2.0.0", "3.1.0"}, []string{"2.1.0", "2.9.0"}},
+		{"!=2.1.0", []string{"2.1.1"}, []string{"2.1.0"}},
+		{"2.1.0", []string{"2.1.0", "v2.1.0"}, []string{"2.1.1"}},
+		{">=2.1.0-rc.1", []string{"2.1.0-rc.2", "2.1.0", "3.0.0"}, []string{"2.1.0-beta", "3.0.0-rc.1"}},
+	}
+	for _, test := range tests {
+		r, err := parseVersionRange(test.versionRange)
+		assert.NoError(t, err, test.versionRange)
+		for _, matching := range test.matching {
+			v, _ := parseVersion(matching)
+			assert.True(t, r.contains(v), "%s should be in %s", matching, test.versionRange)
+		}
+		for _, notMatching := range test.notMatching {
+			v, _ := parseVersion(notMatching)
+			assert.False(t, r.contains(v), "%s should not be in %s", notMatching, test.versionRange)
+		}
+	}
+
+	for _, notARange := range []string{"release-*", "latest", "", ">=", "2.1.0 - 3.0.0"} {
+		_, err := parseVersionRange(notARange)
+		assert.ErrorIs(t, err, errNotARange, notARange)
+	}
+}
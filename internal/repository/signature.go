@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshSignatureMagic is the preamble of the ssh signatures and of the data they sign
+const sshSignatureMagic = "SSHSIG"
+
+// sshSignatureType is the PEM type of the armored ssh signatures
+const sshSignatureType = "SSH SIGNATURE"
+
+// sshSignatureNamespace is the namespace git signs the commits in, signatures issued for other purposes are rejected
+const sshSignatureNamespace = "git"
+
+// untrustedRevisionError is returned by the updates which refused to serve a revision which is not signed by a trusted
+// key
+type untrustedRevisionError struct {
+	revision string
+	reason   error
+}
+
+func (e *untrustedRevisionError) Error() string {
+	return fmt.Sprintf("revision %s is not signed by a trusted key : %s", e.revision, e.reason)
+}
+
+// Is makes the error match ErrUntrustedRevision
+func (e *untrustedRevisionError) Is(target error) bool {
+	return target == ErrUntrustedRevision
+}
+
+// rejectedRevision returns the revision refused by an update or an empty string if the error is not a rejection
+func rejectedRevision(err error) string {
+	var untrusted *untrustedRevisionError
+	if errors.As(err, &untrusted) {
+		return untrusted.revision
+	}
+	return ""
+}
+
+// verifySignature checks that the commit is signed by one of the trusted keys and returns the signer.
+// Keys are resolved on every call so that keys mounted from files can be rotated
+func verifySignature(commit *object.Commit, keys *configuration.TrustedKeys) (signer string, err error) {
+	defer func() {
+		if err != nil {
+			err = &untrustedRevisionError{revision: commit.Hash.String(), reason: err}
+		}
+	}()
+	if len(commit.PGPSignature) == 0 {
+		return "", errors.New("the commit is not signed")
+	}
+
+	if block, _ := pem.Decode([]byte(commit.PGPSignature)); block != nil && block.Type == sshSignatureType {
+		trusted, err := sshKeys(keys.SSH)
+		if err != nil {
+			return "", err
+		}
+		message, err := signedContent(commit)
+		if err != nil {
+			return "", err
+		}
+		key, err := verifySSHSignature(block.Bytes, message, trusted)
+		if err != nil {
+			return "", err
+		}
+		return ssh.FingerprintSHA256(key), nil
+	}
+
+	for _, value := range keys.OpenPGP {
+		keyring, err := configuration.ResolveSecret(value)
+		if err != nil {
+			return "", fmt.Errorf("cannot read OpenPGP key : %w", err)
+		}
+		if entity, err := commit.Verify(keyring); err == nil {
+			return fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint), nil
+		}
+	}
+	return "", errors.New("the OpenPGP signature does not match any trusted key")
+}
+
+// sshKeys parses the trusted ssh public keys
+func sshKeys(values []string) ([]ssh.PublicKey, error) {
+	var keys []ssh.PublicKey
+	for _, value := range values {
+		content, err := configuration.ResolveSecret(value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read ssh key : %w", err)
+		}
+		rest := []byte(content)
+		for len(bytes.TrimSpace(rest)) > 0 {
+			key, _, _, remaining, err := ssh.ParseAuthorizedKey(rest)
+			if err != nil {
+				// Only comments and invalid lines are left
+				break
+			}
+			keys = append(keys, key)
+			rest = remaining
+		}
+	}
+	return keys, nil
+}
+
+// signedContent returns the commit content covered by its signature
+func signedContent(commit *object.Commit) ([]byte, error) {
+	encoded := &plumbing.MemoryObject{}
+	if err := commit.EncodeWithoutSignature(encoded); err != nil {
+		return nil, err
+	}
+	reader, err := encoded.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// verifySSHSignature checks an ssh signature, as produced by ssh-keygen -Y sign, of the message and returns the key
+// which issued it if it is trusted
+func verifySSHSignature(blob []byte, message []byte, trusted []ssh.PublicKey) (ssh.PublicKey, error) {
+	if !bytes.HasPrefix(blob, []byte(sshSignatureMagic)) {
+		return nil, errors.New("malformed ssh signature")
+	}
+	var signature struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}
+	if err := ssh.Unmarshal(blob[len(sshSignatureMagic):], &signature); err != nil {
+		return nil, fmt.Errorf("malformed ssh signature : %w", err)
+	}
+	if signature.Version != 1 || signature.Namespace != sshSignatureNamespace {
+		return nil, fmt.Errorf("unsupported ssh signature version %d in namespace '%s'", signature.Version, signature.Namespace)
+	}
+
+	key, err := ssh.ParsePublicKey(signature.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("malformed ssh signature key : %w", err)
+	}
+	if !containsKey(trusted, key) {
+		return nil, fmt.Errorf("the ssh key %s is not trusted", ssh.FingerprintSHA256(key))
+	}
+
+	var digest hash.Hash
+	switch signature.HashAlgorithm {
+	case "sha256":
+		digest = sha256.New()
+	case "sha512":
+		digest = sha512.New()
+	default:
+		return nil, fmt.Errorf("unsupported ssh signature hash algorithm '%s'", signature.HashAlgorithm)
+	}
+	digest.Write(message)
+	signed := append([]byte(sshSignatureMagic), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{signature.Namespace, signature.Reserved, signature.HashAlgorithm, digest.Sum(nil)})...)
+
+	sig := &ssh.Signature{}
+	if err := ssh.Unmarshal(signature.Signature, sig); err != nil {
+		return nil, fmt.Errorf("malformed ssh signature : %w", err)
+	}
+	if err := key.Verify(signed, sig); err != nil {
+		return nil, fmt.Errorf("invalid ssh signature : %w", err)
+	}
+	return key, nil
+}
+
+// containsKey returns true if the key is one of the provided keys
+func containsKey(keys []ssh.PublicKey, key ssh.PublicKey) bool {
+	for _, candidate := range keys {
+		if bytes.Equal(candidate.Marshal(), key.Marshal()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/pem"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+// sign replaces the head commit of the repository by a copy signed using the provided function
+func (r *testRepository) sign(sign func(message []byte) string) plumbing.Hash {
+	head, err := r.workspace.Head()
+	assert.NoError(r.t, err)
+	commit, err := r.workspace.CommitObject(head.Hash())
+	assert.NoError(r.t, err)
+	message, err := signedContent(commit)
+	assert.NoError(r.t, err)
+
+	commit.PGPSignature = sign(message)
+	encoded := r.workspace.Storer.NewEncodedObject()
+	assert.NoError(r.t, commit.Encode(encoded))
+	hash, err := r.workspace.Storer.SetEncodedObject(encoded)
+	assert.NoError(r.t, err)
+	assert.NoError(r.t, r.workspace.Storer.SetReference(plumbing.NewHashReference(head.Name(), hash)))
+	return hash
+}
+
+// sshSigner generates an ssh key and returns its authorized key along with a function signing messages as git does
+func sshSigner(t *testing.T) (string, func(message []byte) string) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(private)
+	assert.NoError(t, err)
+
+	return string(ssh.MarshalAuthorizedKey(signer.PublicKey())), func(message []byte) string {
+		digest := sha512.Sum512(message)
+		signed := append([]byte(sshSignatureMagic), ssh.Marshal(struct {
+			Namespace     string
+			Reserved      string
+			HashAlgorithm string
+			Hash          []byte
+		}{sshSignatureNamespace, "", "sha512", digest[:]})...)
+		signature, err := signer.Sign(rand.Reader, signed)
+		assert.NoError(t, err)
+
+		blob := append([]byte(sshSignatureMagic), ssh.Marshal(struct {
+			Version       uint32
+			PublicKey     []byte
+			Namespace     string
+			Reserved      string
+			HashAlgorithm string
+			Signature     []byte
+		}{1, signer.PublicKey().Marshal(), sshSignatureNamespace, "", "sha512", ssh.Marshal(signature)})...)
+		return string(pem.EncodeToMemory(&pem.Block{Type: sshSignatureType, Bytes: blob}))
+	}
+}
+
+// openPGPSigner generates an OpenPGP key and returns its armored public key along with a function signing messages
+func openPGPSigner(t *testing.T) (string, func(message []byte) string) {
+	entity, err := openpgp.NewEntity("configserver", "", "configserver@localhost", nil)
+	assert.NoError(t, err)
+	public := &bytes.Buffer{}
+	writer, err := armor.Encode(public, openpgp.PublicKeyType, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, entity.Serialize(writer))
+	assert.NoError(t, writer.Close())
+
+	return public.String(), func(message []byte) string {
+		signature := &bytes.Buffer{}
+		assert.NoError(t, openpgp.ArmoredDetachSign(signature, entity, bytes.NewReader(message), nil))
+		return signature.String()
+	}
+}
+
+// signedCommit commits a file and signs the commit using the provided function
+func signedCommit(repo *testRepository, content string, sign func(message []byte) string) *object.Commit {
+	repo.commit(content, map[string]string{"config/app.yml": content})
+	commit, err := repo.workspace.CommitObject(repo.sign(sign))
+	assert.NoError(repo.t, err)
+	return commit
+}
+
+func TestVerifySSHSignature(t *testing.T) {
+	trusted, sign := sshSigner(t)
+	untrusted, _ := sshSigner(t)
+	repo := newTestRepository(t)
+	commit := signedCommit(repo, "version: 1", sign)
+
+	signer, err := verifySignature(commit, &configuration.TrustedKeys{SSH: []string{untrusted + "# release key\n" + trusted}})
+	assert.NoError(t, err)
+	assert.Contains(t, signer, "SHA256:")
+
+	_, err = verifySignature(commit, &configuration.TrustedKeys{SSH: []string{untrusted}})
+	assert.ErrorIs(t, err, ErrUntrustedRevision)
+	assert.Equal(t, commit.Hash.String(), rejectedRevision(err))
+
+	commit.Message = "tampered"
+	_, err = verifySignature(commit, &configuration.TrustedKeys{SSH: []string{trusted}})
+	assert.ErrorContains(t, err, "invalid ssh signature")
+
+	unsigned, err := repo.workspace.CommitObject(repo.commit("unsigned", map[string]string{"config/app.yml": "version: 2"}))
+	assert.NoError(t, err)
+	_, err = verifySignature(unsigned, &configuration.TrustedKeys{SSH: []string{trusted}})
+	assert.ErrorContains(t, err, "the commit is not signed")
+}
+
+func TestVerifyOpenPGPSignature(t *testing.T) {
+	trusted, sign := openPGPSigner(t)
+	untrusted, _ := openPGPSigner(t)
+	repo := newTestRepository(t)
+	commit := signedCommit(repo, "version: 1", sign)
+
+	_, err := verifySignature(commit, &configuration.TrustedKeys{OpenPGP: []string{untrusted, trusted}})
+	assert.NoError(t, err)
+
+	_, err = verifySignature(commit, &configuration.TrustedKeys{OpenPGP: []string{untrusted}})
+	assert.ErrorIs(t, err, ErrUntrustedRevision)
+
+	sshKey, _ := sshSigner(t)
+	_, err = verifySignature(commit, &configuration.TrustedKeys{SSH: []string{sshKey}})
+	assert.ErrorIs(t, err, ErrUntrustedRevision, "OpenPGP signatures are only verified against OpenPGP keys")
+}
+
+func TestUntrustedRevisionsAreNotServed(t *testing.T) {
+	trusted, sign := sshSigner(t)
+	repo := newTestRepository(t)
+	first := signedCommit(repo, "version: 1", sign)
+
+	b := repo.clonedBeholder(&configuration.Repository{TrustedKeys: &configuration.TrustedKeys{SSH: []string{trusted}}})
+	assert.Equal(t, first.Hash.String(), b.Revision())
+
+	second := repo.commit("unsigned", map[string]string{"config/app.yml": "version: 2"})
+	err := b.update(context.Background())
+	assert.ErrorIs(t, err, ErrUntrustedRevision)
+	assert.Equal(t, second.String(), rejectedRevision(err))
+	assert.Equal(t, first.Hash.String(), b.Revision(), "the last verified revision keeps being served")
+	file, err := b.File("config/app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 1", string(file.Content))
+
+	_, err = b.FileAt(second.String(), "config/app.yml")
+	assert.ErrorIs(t, err, ErrUntrustedRevision, "unsigned revisions cannot be requested either")
+
+	third := signedCommit(repo, "version: 3", sign)
+	assert.NoError(t, b.update(context.Background()))
+	assert.Equal(t, third.Hash.String(), b.Revision())
+	file, err = b.File("config/app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 3", string(file.Content))
+}
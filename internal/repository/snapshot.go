@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// snapshot is an immutable view of a repository at a given revision.
+// Git objects are never modified once written, therefore readers holding a snapshot are not affected by the updates
+// occurring while they read
+type snapshot struct {
+	commit       plumbing.Hash // revision served by the snapshot
+	lastModified sync.Map      // path -> time.Time, caches the history walks as they cannot change for a given revision
+}
+
+// File is a file read from a repository at a given revision
+type File struct {
+	Path         string    // path relative to the repository root
+	Content      []byte    // raw file content
+	Hash         string    // git blob hash, changes whenever the content changes
+	Revision     string    // commit the file was read from
+	LastModified time.Time // commit date of the last commit which modified the file
+}
+
+// cleanPath converts a requested path to a path relative to the repository root, preventing any attempt to escape it
+func cleanPath(filepath string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath), "/")
+}
+
+// readFile reads the content of the requested file as it exists in the provided commit
+func readFile(commit *object.Commit, filepath string) (*File, error) {
+	name := cleanPath(filepath)
+	file, err := commit.File(name)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is not accessible at %s or is a directory : %w", filepath, commit.Hash, ErrFileNotFound)
+	}
+
+	content, err := file.Contents()
+	if err != nil {
+		return nil, fmt.Errorf("an unexpected error occured while reading '%s' at %s : %w", filepath, commit.Hash, err)
+	}
+
+	return &File{
+		Path:     name,
+		Content:  []byte(content),
+		Hash:     file.Hash.String(),
+		Revision: commit.Hash.String(),
+	}, nil
+}
+
+// lastModified returns the date of the last commit which modified the provided path, the result is cached in the
+// snapshot when one is provided
+func lastModified(current *snapshot, commit *object.Commit, filepath string, boundary []plumbing.Hash) (time.Time, error) {
+	if current != nil {
+		if date, ok := current.lastModified.Load(filepath); ok {
+			return date.(time.Time), nil
+		}
+	}
+
+	commits, err := lastCommits(commit, []string{filepath}, boundary)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var date time.Time
+	if last, ok := commits[filepath]; ok {
+		date = last.Committer.When
+	}
+	if current != nil {
+		current.lastModified.Store(filepath, date)
+	}
+	return date, nil
+}
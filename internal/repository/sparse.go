@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sparseSet holds the directories served by a sparsely checked out repository, an empty set serves the whole
+// repository
+type sparseSet []string
+
+// newSparseSet creates the set of the provided sparse checkout directories
+func newSparseSet(dirs []string) sparseSet {
+	set := make(sparseSet, 0, len(dirs))
+	for _, dir := range dirs {
+		set = append(set, cleanPath(dir))
+	}
+	return set
+}
+
+// contains returns true if the path is within one of the sparse directories
+func (s sparseSet) contains(path string) bool {
+	if len(s) == 0 {
+		return true
+	}
+	for _, dir := range s {
+		if path == dir || strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// reaches returns true if the directory is within one of the sparse directories or leads to one of them
+func (s sparseSet) reaches(dir string) bool {
+	if len(dir) == 0 || s.contains(dir) {
+		return true
+	}
+	for _, sparse := range s {
+		if strings.HasPrefix(sparse, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// errOutsideSparseCheckout is returned when a path outside the sparse checkout directories of a repository is requested
+func errOutsideSparseCheckout(repository string, path string) error {
+	return fmt.Errorf("'%s' is outside the sparse checkout of '%s' : %w", path, repository, ErrFileNotFound)
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statistics maintains the access statistics and the update status of a repository, it is safe for concurrent use.
+// Use Snapshot to read the statistics
+type Statistics struct {
+	hits           atomic.Int64
+	unauthorized   atomic.Int64
+	notFound       atomic.Int64
+	internalErrors atomic.Int64
+	clientHits     sync.Map                    // hits per client id, values are *atomic.Int64
+	pathHits       sync.Map                    // hits per file path, values are *atomic.Int64
+	lastServed     atomic.Pointer[string]      // commit of the latest file served
+	update         atomic.Pointer[UpdateEvent] // outcome of the latest update, replaced as a whole on each heartbeat
+}
+
+// StatisticsSnapshot is a copy of a repository statistics at a given time
+type StatisticsSnapshot struct {
+	HitCount            int64            `json:"hitCount"`
+	ClientHits          map[string]int64 `json:"clientHits,omitempty"`
+	PathHits            map[string]int64 `json:"pathHits"`
+	LastServedCommit    string           `json:"lastServedCommit,omitempty"`
+	Unauthorized        int64            `json:"unauthorized"`   // requests answered with a 401 status
+	NotFound            int64            `json:"notFound"`       // requests answered with a 404 status
+	InternalErrors      int64            `json:"internalErrors"` // requests answered with a 500 status
+	LastUpdate          time.Time        `json:"lastUpdate"`
+	NextUpdate          time.Time        `json:"nextUpdate"`
+	LastError           string           `json:"lastError,omitempty"`
+	State               State            `json:"state"`
+	ConsecutiveFailures int              `json:"consecutiveFailures"`
+	Revision            string           `json:"revision,omitempty"`         // commit checked out by the latest update
+	RejectedRevision    string           `json:"rejectedRevision,omitempty"` // revision refused by the latest update as it is not signed by a trusted key
+}
+
+// newStatistics creates the statistics of a repository which was not checked out yet
+func newStatistics() *Statistics {
+	s := &Statistics{}
+	s.update.Store(&UpdateEvent{State: StatePending})
+	return s
+}
+
+// hit records a file served to a client
+func (s *Statistics) hit(clientID string, path string, revision string) {
+	s.hits.Add(1)
+	add(&s.clientHits, clientID, 1)
+	add(&s.pathHits, path, 1)
+	s.lastServed.Store(&revision)
+}
+
+// add increments the counter stored under key
+func add(counters *sync.Map, key string, delta int64) {
+	counter, ok := counters.Load(key)
+	if !ok {
+		counter, _ = counters.LoadOrStore(key, &atomic.Int64{})
+	}
+	counter.(*atomic.Int64).Add(delta)
+}
+
+// copyCounters copies the counters stored in a map
+func copyCounters(counters *sync.Map) map[string]int64 {
+	copied := make(map[string]int64)
+	counters.Range(func(key, counter any) bool {
+		copied[key.(string)] = counter.(*atomic.Int64).Load()
+		return true
+	})
+	return copied
+}
+
+// recordStatus counts the unauthorized, not found and internal error responses
+func (s *Statistics) recordStatus(status int) {
+	switch status {
+	case http.StatusUnauthorized:
+		s.unauthorized.Add(1)
+	case http.StatusNotFound:
+		s.notFound.Add(1)
+	case http.StatusInternalServerError:
+		s.internalErrors.Add(1)
+	}
+}
+
+// updated records the outcome of an update
+func (s *Statistics) updated(event UpdateEvent) {
+	s.update.Store(&event)
+}
+
+// lastUpdate returns the outcome of the latest update
+func (s *Statistics) lastUpdate() *UpdateEvent {
+	return s.update.Load()
+}
+
+// Snapshot returns a copy of the statistics
+func (s *Statistics) Snapshot() *StatisticsSnapshot {
+	update := s.lastUpdate()
+	snapshot := &StatisticsSnapshot{
+		HitCount:            s.hits.Load(),
+		ClientHits:          copyCounters(&s.clientHits),
+		PathHits:            copyCounters(&s.pathHits),
+		Unauthorized:        s.unauthorized.Load(),
+		NotFound:            s.notFound.Load(),
+		InternalErrors:      s.internalErrors.Load(),
+		LastUpdate:          update.LastUpdate,
+		NextUpdate:          update.NextUpdate,
+		State:               update.State,
+		ConsecutiveFailures: update.ConsecutiveFailures,
+		Revision:            update.Revision,
+		RejectedRevision:    update.RejectedRevision,
+	}
+	if update.LastError != nil {
+		snapshot.LastError = update.LastError.Error()
+	}
+	if served := s.lastServed.Load(); served != nil {
+		snapshot.LastServedCommit = *served
+	}
+	return snapshot
+}
+
+// restore adds the counters of previously saved statistics
+func (s *Statistics) restore(saved *StatisticsSnapshot) {
+	s.hits.Add(saved.HitCount)
+	s.unauthorized.Add(saved.Unauthorized)
+	s.notFound.Add(saved.NotFound)
+	s.internalErrors.Add(saved.InternalErrors)
+	for clientID, hits := range saved.ClientHits {
+		add(&s.clientHits, clientID, hits)
+	}
+	for path, hits := range saved.PathHits {
+		add(&s.pathHits, path, hits)
+	}
+	if len(saved.LastServedCommit) > 0 {
+		s.lastServed.CompareAndSwap(nil, &saved.LastServedCommit)
+	}
+}
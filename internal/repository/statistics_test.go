@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatisticsAreSafeForConcurrentUse(t *testing.T) {
+	statistics := newStatistics()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				statistics.hit("client", "app.yml", "abc123")
+				statistics.recordStatus(http.StatusNotFound)
+				statistics.updated(UpdateEvent{State: StateHealthy})
+				_ = statistics.Snapshot()
+			}
+		}()
+	}
+	wg.Wait()
+
+	snapshot := statistics.Snapshot()
+	assert.Equal(t, int64(800), snapshot.HitCount)
+	assert.Equal(t, map[string]int64{"client": 800}, snapshot.ClientHits)
+	assert.Equal(t, map[string]int64{"app.yml": 800}, snapshot.PathHits)
+	assert.Equal(t, int64(800), snapshot.NotFound)
+}
+
+func TestStatisticsSnapshot(t *testing.T) {
+	statistics := newStatistics()
+	assert.Equal(t, StatePending, statistics.Snapshot().State)
+
+	statistics.hit("client", "app.yml", "abc123")
+	statistics.hit("other", "app.yml", "def456")
+	statistics.recordStatus(http.StatusUnauthorized)
+	statistics.recordStatus(http.StatusInternalServerError)
+	statistics.recordStatus(http.StatusOK)
+	statistics.updated(UpdateEvent{State: StateDegraded, LastError: errors.New("remote unreachable"), ConsecutiveFailures: 2, Revision: "def456"})
+
+	snapshot := statistics.Snapshot()
+	assert.Equal(t, map[string]int64{"client": 1, "other": 1}, snapshot.ClientHits)
+	assert.Equal(t, "def456", snapshot.LastServedCommit)
+	assert.Equal(t, int64(1), snapshot.Unauthorized)
+	assert.Equal(t, int64(0), snapshot.NotFound)
+	assert.Equal(t, int64(1), snapshot.InternalErrors)
+	assert.Equal(t, StateDegraded, snapshot.State)
+	assert.Equal(t, 2, snapshot.ConsecutiveFailures)
+
+	jsn, err := json.Marshal(snapshot)
+	assert.NoError(t, err)
+	assert.Contains(t, string(jsn), `"lastError":"remote unreachable"`)
+}
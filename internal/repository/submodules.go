@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/tracing"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// gitModulesFile is the file registering the submodules of a repository
+const gitModulesFile = ".gitmodules"
+
+// updateSubmodules recursively initializes the submodules of the local copy and checks them out at the revision
+// recorded by the repository, the operation is recorded in the repository update metrics.
+// Submodules are fetched using the repository credentials
+func updateSubmodules(ctx context.Context, workspace *git.Repository, cfg *configuration.Repository, auth transport.AuthMethod) (err error) {
+	defer observeUpdate(ctx, cfg.Name, operationSubmodules, time.Now(), &err)
+	ctx, span := tracing.StartClient(ctx, "git.submodule.update", tracing.String("repository.url", cfg.URL))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	tree, err := workspace.Worktree()
+	if err != nil {
+		return fmt.Errorf("'%s' : unable to open local copy : %w", cfg.Name, err)
+	}
+	submodules, err := tree.Submodules()
+	if err != nil {
+		return fmt.Errorf("'%s' : unable to read submodules : %w", cfg.Name, err)
+	}
+	err = submodules.UpdateContext(ctx, &git.SubmoduleUpdateOptions{Init: true, RecurseSubmodules: git.DefaultSubmoduleRecursionDepth, Auth: auth})
+	if err != nil {
+		return fmt.Errorf("'%s' : unable to update submodules : %w", cfg.Name, err)
+	}
+	return nil
+}
+
+// location is a path resolved to the repository holding it once the submodules it crosses are followed
+type location struct {
+	workspace *git.Repository
+	commit    *object.Commit
+	path      string // path relative to the repository holding it
+	submodule string // path of the submodule holding the path, empty when the path is not in a submodule
+}
+
+// locate follows the submodules crossed by the provided path. Submodules are read from their local copy at the
+// revision recorded by the commit of their parent repository
+func locate(workspace *git.Repository, commit *object.Commit, name string) (*location, error) {
+	loc := &location{workspace: workspace, commit: commit, path: name}
+	for {
+		entry, prefix, err := gitlink(loc.commit, loc.path)
+		if err != nil || entry == nil {
+			return loc, err
+		}
+
+		submodule := path.Join(loc.submodule, prefix)
+		module, err := openSubmodule(loc.workspace, loc.commit, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' : %w", submodule, err)
+		}
+		revision, err := module.CommitObject(entry.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' submodule revision %s is not checked out : %w", submodule, entry.Hash, ErrFileNotFound)
+		}
+		loc = &location{workspace: module, commit: revision, path: strings.TrimPrefix(loc.path[len(prefix):], "/"), submodule: submodule}
+	}
+}
+
+// gitlink returns the first submodule crossed by the path along with its path, or a nil entry if the path does not
+// cross any submodule
+func gitlink(commit *object.Commit, name string) (*object.TreeEntry, string, error) {
+	if len(name) == 0 {
+		return nil, "", nil
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to read tree of %s : %w", commit.Hash, err)
+	}
+
+	parts := strings.Split(name, "/")
+	for i := range parts {
+		prefix := strings.Join(parts[:i+1], "/")
+		entry, err := tree.FindEntry(prefix)
+		if err != nil {
+			return nil, "", nil
+		}
+		if entry.Mode == filemode.Submodule {
+			return entry, prefix, nil
+		}
+	}
+	return nil, "", nil
+}
+
+// openSubmodule opens the local copy of the submodule registered at the provided path
+func openSubmodule(workspace *git.Repository, commit *object.Commit, dir string) (*git.Repository, error) {
+	file, err := commit.File(gitModulesFile)
+	if err != nil {
+		return nil, fmt.Errorf("no submodule is registered at %s : %w", commit.Hash, ErrFileNotFound)
+	}
+	content, err := file.Contents()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read submodules at %s : %w", commit.Hash, err)
+	}
+	modules := config.NewModules()
+	if err := modules.Unmarshal([]byte(content)); err != nil {
+		return nil, fmt.Errorf("unable to read submodules at %s : %w", commit.Hash, err)
+	}
+
+	for _, submodule := range modules.Submodules {
+		if path.Clean(submodule.Path) != dir {
+			continue
+		}
+		storage, err := workspace.Storer.Module(submodule.Name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open submodule '%s' : %w", submodule.Name, err)
+		}
+		module, err := git.Open(storage, nil)
+		if err != nil {
+			return nil, fmt.Errorf("submodule '%s' is not checked out : %w", submodule.Name, ErrFileNotFound)
+		}
+		return module, nil
+	}
+	return nil, fmt.Errorf("no submodule is registered at %s : %w", commit.Hash, ErrFileNotFound)
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/stretchr/testify/assert"
+)
+
+// submodule registers the provided repository as a submodule checked out at the given revision and commits it
+func (r *testRepository) submodule(dir string, module *testRepository, revision plumbing.Hash) plumbing.Hash {
+	modules := fmt.Sprintf("[submodule \"%s\"]\n\tpath = %s\n\turl = %s\n", dir, dir, module.dir)
+	assert.NoError(r.t, os.WriteFile(filepath.Join(r.dir, gitModulesFile), []byte(modules), 0600))
+	tree, err := r.workspace.Worktree()
+	assert.NoError(r.t, err)
+	_, err = tree.Add(gitModulesFile)
+	assert.NoError(r.t, err)
+
+	idx, err := r.workspace.Storer.Index()
+	assert.NoError(r.t, err)
+	idx.Entries = slices.DeleteFunc(idx.Entries, func(entry *index.Entry) bool { return entry.Name == dir })
+	idx.Entries = append(idx.Entries, &index.Entry{Name: dir, Hash: revision, Mode: filemode.Submodule, ModifiedAt: time.Now()})
+	assert.NoError(r.t, r.workspace.Storer.SetIndex(idx))
+	return r.commit("update "+dir, nil)
+}
+
+func TestSubmodules(t *testing.T) {
+	shared := newTestRepository(t)
+	first := shared.commit("first", map[string]string{"defaults/app.yml": "timeout: 1"})
+	repo := newTestRepository(t)
+	repo.commit("first", map[string]string{"config/app.yml": "version: 1"})
+	repo.submodule("shared", shared, first)
+
+	b := repo.clonedBeholder(&configuration.Repository{Submodules: true})
+	file, err := b.File("shared/defaults/app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "timeout: 1", string(file.Content))
+	assert.Equal(t, "shared/defaults/app.yml", file.Path)
+	assert.Equal(t, b.Revision(), file.Revision, "files are served from the repository revision")
+	assert.False(t, file.LastModified.IsZero())
+
+	entries, err := b.List("", "shared")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "shared/defaults", entries[0].Path)
+	assert.Equal(t, EntryDirectory, entries[0].Type)
+
+	history, err := b.History("", "shared/defaults/app.yml", 10)
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+	assert.Equal(t, first.String(), history[0].Hash)
+
+	served := b.Revision()
+	second := shared.commit("second", map[string]string{"defaults/app.yml": "timeout: 2"})
+	assert.NoError(t, b.update(context.Background()))
+	file, err = b.File("shared/defaults/app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "timeout: 1", string(file.Content), "submodules are served at the revision recorded by the repository")
+
+	repo.submodule("shared", shared, second)
+	assert.NoError(t, b.update(context.Background()))
+	file, err = b.File("shared/defaults/app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "timeout: 2", string(file.Content))
+	assert.NotEqual(t, b.Hash(served, "shared/defaults/app.yml"), b.Hash(b.Revision(), "shared/defaults/app.yml"))
+
+	file, err = b.FileAt(served, "shared/defaults/app.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, "timeout: 1", string(file.Content))
+}
+
+func TestSubmodulesDisabled(t *testing.T) {
+	shared := newTestRepository(t)
+	first := shared.commit("first", map[string]string{"defaults/app.yml": "timeout: 1"})
+	repo := newTestRepository(t)
+	repo.submodule("shared", shared, first)
+
+	b := repo.clonedBeholder(&configuration.Repository{})
+	_, err := b.File("shared/defaults/app.yml")
+	assert.ErrorIs(t, err, ErrFileNotFound)
+
+	entries, err := b.List("", "")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "shared", entries[1].Name)
+	assert.Equal(t, EntrySubmodule, entries[1].Type)
+	assert.Equal(t, first.String(), entries[1].Hash)
+}
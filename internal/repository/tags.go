@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"path"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// tagsRefSpec fetches the remote tags, tags moved on the remote are updated
+const tagsRefSpec = "+refs/tags/*:refs/tags/*"
+
+// pinnedRefSpec fetches a pinned commit by its hash, which remotes must allow as most providers do, for when it cannot
+// be fetched along the branches and tags
+func pinnedRefSpec(commit string) config.RefSpec {
+	return config.RefSpec(fmt.Sprintf("+%s:refs/pinned/%s", commit, commit))
+}
+
+// tagSelector designates the tags a repository may serve, either by a semantic version range or by a glob pattern
+type tagSelector struct {
+	pattern  string
+	versions versionRange // nil when the pattern is not a version range
+}
+
+// newTagSelector creates the selector matching the provided pattern, patterns which are not version ranges are glob
+// patterns matched against the tag names
+func newTagSelector(pattern string) *tagSelector {
+	versions, err := parseVersionRange(pattern)
+	if err != nil {
+		versions = nil
+	}
+	return &tagSelector{pattern: pattern, versions: versions}
+}
+
+// matches returns true if the tag is selected
+func (s *tagSelector) matches(tag string) bool {
+	if s.versions != nil {
+		v, ok := parseVersion(tag)
+		return ok && s.versions.contains(v)
+	}
+	matches, err := path.Match(s.pattern, tag)
+	return err == nil && matches
+}
+
+// tagCandidate is a tag selected by a tag selector
+type tagCandidate struct {
+	name    string
+	commit  *object.Commit
+	version *version // nil if the tag is not a semantic version
+}
+
+// follows returns true if the candidate is a later release than the other one : semantic versions are compared by
+// precedence and take precedence over the other tags, which are compared by commit date and then by name
+func (c *tagCandidate) follows(o *tagCandidate) bool {
+	switch {
+	case c.version != nil && o.version != nil:
+		return cmp.Or(c.version.compare(o.version), cmp.Compare(c.name, o.name)) > 0
+	case c.version != nil || o.version != nil:
+		return c.version != nil
+	default:
+		return cmp.Or(c.commit.Committer.When.Compare(o.commit.Committer.When), cmp.Compare(c.name, o.name)) > 0
+	}
+}
+
+// latestTag returns the highest tag matching the selector along with the commit it designates
+func latestTag(workspace *git.Repository, selector *tagSelector) (*tagCandidate, error) {
+	tags, err := workspace.Tags()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list tags : %w", err)
+	}
+
+	var latest *tagCandidate
+	err = tags.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().Short()
+		if !selector.matches(name) {
+			return nil
+		}
+		commit, err := taggedCommit(workspace, ref.Hash())
+		if err != nil {
+			// Tags designating trees or blobs cannot be served
+			return nil
+		}
+		candidate := &tagCandidate{name: name, commit: commit}
+		candidate.version, _ = parseVersion(name)
+		if latest == nil || candidate.follows(latest) {
+			latest = candidate
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list tags : %w", err)
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("no tag matches '%s' : %w", selector.pattern, ErrRefNotFound)
+	}
+	return latest, nil
+}
+
+// taggedCommit returns the commit designated by a lightweight or an annotated tag
+func taggedCommit(workspace *git.Repository, hash plumbing.Hash) (*object.Commit, error) {
+	if tag, err := workspace.TagObject(hash); err == nil {
+		return tag.Commit()
+	}
+	return workspace.CommitObject(hash)
+}
+
+// pinnedRevision returns the revision served by a repository tracking a tag or a commit rather than a branch
+func pinnedRevision(workspace *git.Repository, cfg *configuration.Repository) (plumbing.Hash, error) {
+	if len(cfg.Tag) > 0 {
+		tag, err := latestTag(workspace, newTagSelector(cfg.Tag))
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("'%s' : %w", cfg.Name, err)
+		}
+		slog.Debug(fmt.Sprintf("'%s' latest tag matching '%s' is '%s'", cfg.Name, cfg.Tag, tag.name), logKeyRepositoryName, cfg.Name)
+		return tag.commit.Hash, nil
+	}
+
+	hash, err := workspace.ResolveRevision(plumbing.Revision(cfg.Commit))
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("'%s' : commit '%s' cannot be found : %w", cfg.Name, cfg.Commit, ErrRefNotFound)
+	}
+	commit, err := workspace.CommitObject(*hash)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("'%s' : '%s' does not designate a commit : %w", cfg.Name, cfg.Commit, ErrRefNotFound)
+	}
+	return commit.Hash, nil
+}
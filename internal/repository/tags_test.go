@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+)
+
+// tag commits the provided version of the configuration and tags it, annotated tags are created when annotate is true
+func (r *testRepository) tag(name string, content string, annotate bool) plumbing.Hash {
+	hash := r.commit(name, map[string]string{"config/app.yml": content})
+	var options *git.CreateTagOptions
+	if annotate {
+		options = &git.CreateTagOptions{Message: name, Tagger: &object.Signature{Name: "configserver", Email: "configserver@localhost", When: time.Now()}}
+	}
+	_, err := r.workspace.CreateTag(name, hash, options)
+	assert.NoError(r.t, err)
+	return hash
+}
+
+// served returns the content of the configuration file served by the beholder
+func served(t *testing.T, b *Beholder) string {
+	file, err := b.File("config/app.yml")
+	assert.NoError(t, err)
+	return string(file.Content)
+}
+
+func TestTagRangeCheckout(t *testing.T) {
+	repo := newTestRepository(t)
+	repo.tag("v1.0.0", "version: 1.0.0", false)
+	repo.tag("v2.1.0", "version: 2.1.0", true)
+	repo.tag("v2.0.0", "version: 2.0.0", false)
+	repo.tag("v3.0.0-rc.1", "version: 3.0.0-rc.1", false)
+	repo.commit("unreleased", map[string]string{"config/app.yml": "version: unreleased"})
+
+	b := repo.clonedBeholder(&configuration.Repository{Tag: "^2.0"})
+	assert.Equal(t, "version: 2.1.0", served(t, b), "the highest matching tag is served")
+
+	repo.tag("v2.2.0", "version: 2.2.0", true)
+	assert.NoError(t, b.update(context.Background()))
+	assert.Equal(t, "version: 2.2.0", served(t, b))
+
+	b.Reconfigure(&configuration.Repository{Name: "test", URL: repo.dir, Tag: "v1.*"})
+	assert.NoError(t, b.update(context.Background()))
+	assert.Equal(t, "version: 1.0.0", served(t, b), "the tag pattern can be changed in place")
+
+	b.Reconfigure(&configuration.Repository{Name: "test", URL: repo.dir, Tag: "v4.*"})
+	assert.ErrorIs(t, b.update(context.Background()), ErrRefNotFound)
+	assert.Equal(t, "version: 1.0.0", served(t, b), "the last good revision keeps being served")
+}
+
+func TestTagPatternCheckout(t *testing.T) {
+	repo := newTestRepository(t)
+	repo.tag("release-1", "version: 1", false)
+	repo.tag("release-2", "version: 2", false)
+	repo.tag("other", "version: other", false)
+
+	b := repo.clonedBeholder(&configuration.Repository{Tag: "release-*", SingleBranch: true, Depth: 1})
+	assert.Equal(t, "version: 2", served(t, b), "the most recent matching tag is served")
+
+	repo.tag("release-3", "version: 3", false)
+	assert.NoError(t, b.update(context.Background()))
+	assert.Equal(t, "version: 3", served(t, b))
+}
+
+func TestCommitCheckout(t *testing.T) {
+	repo := newTestRepository(t)
+	pinned := repo.commit("first", map[string]string{"config/app.yml": "version: 1"})
+	repo.commit("second", map[string]string{"config/app.yml": "version: 2"})
+
+	b := repo.clonedBeholder(&configuration.Repository{Commit: pinned.String()[:10]})
+	assert.Equal(t, pinned.String(), b.Revision())
+	assert.Equal(t, "version: 1", served(t, b))
+
+	repo.commit("third", map[string]string{"config/app.yml": "version: 3"})
+	assert.NoError(t, b.update(context.Background()))
+	assert.Equal(t, pinned.String(), b.Revision(), "new commits are not served")
+}
+
+func TestCommitBeyondDepthCheckout(t *testing.T) {
+	repo := newTestRepository(t)
+	pinned := repo.commit("first", map[string]string{"config/app.yml": "version: 1"})
+	repo.commit("second", map[string]string{"config/app.yml": "version: 2"})
+	config, err := repo.workspace.Config()
+	assert.NoError(t, err)
+	config.Raw.Section("uploadpack").SetOption("allowReachableSHA1InWant", "true")
+	assert.NoError(t, repo.workspace.SetConfig(config))
+
+	b := repo.clonedBeholder(&configuration.Repository{Commit: pinned.String(), Depth: 1})
+	assert.Equal(t, pinned.String(), b.Revision(), "commits which are not fetched along the branches are fetched explicitly")
+	assert.Equal(t, "version: 1", served(t, b))
+
+	third := repo.commit("third", map[string]string{"config/app.yml": "version: 3"})
+	repo.commit("fourth", map[string]string{"config/app.yml": "version: 4"})
+	b.Reconfigure(&configuration.Repository{Name: "test", URL: repo.dir, Commit: third.String(), Depth: 1})
+	assert.NoError(t, b.update(context.Background()))
+	assert.Equal(t, third.String(), b.Revision(), "the pinned commit can be changed in place")
+	assert.Equal(t, "version: 3", served(t, b))
+}
+
+func TestTracksRef(t *testing.T) {
+	branch := &Repository{Configuration: &configuration.Repository{Branch: "release"}}
+	tag := &Repository{Configuration: &configuration.Repository{Tag: "v2.*"}}
+	commit := &Repository{Configuration: &configuration.Repository{Commit: "6f1c5b7e"}}
+
+	assert.True(t, branch.TracksRef("refs/heads/release", ""))
+	assert.False(t, branch.TracksRef("refs/heads/main", ""))
+	assert.False(t, branch.TracksRef("refs/tags/v2.1.0", ""))
+	assert.True(t, tag.TracksRef("refs/tags/v2.1.0", ""))
+	assert.False(t, tag.TracksRef("refs/tags/v3.0.0", ""))
+	assert.False(t, tag.TracksRef("refs/heads/main", "main"), "repositories tracking a tag do not track the default branch")
+	assert.False(t, commit.TracksRef("refs/heads/main", "main"))
+	assert.False(t, commit.TracksRef("refs/tags/v2.1.0", ""))
+}
+
+func TestMovedTagCheckout(t *testing.T) {
+	for _, singleBranch := range []bool{false, true} {
+		repo := newTestRepository(t)
+		repo.tag("stable", "version: 1", false)
+
+		b := repo.clonedBeholder(&configuration.Repository{Tag: "stable", SingleBranch: singleBranch})
+		assert.Equal(t, "version: 1", served(t, b))
+
+		assert.NoError(t, repo.workspace.DeleteTag("stable"))
+		repo.tag("stable", "version: 2", false)
+		assert.NoError(t, b.update(context.Background()))
+		assert.Equal(t, "version: 2", served(t, b), "tags moved on the remote are updated")
+	}
+}
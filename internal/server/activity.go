@@ -0,0 +1,34 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/repository"
+)
+
+// ClientActivityReport is the response returned when querying the activity of a client
+type ClientActivityReport struct {
+	ClientID string                       `json:"clientId"`
+	LastSeen *time.Time                   `json:"lastSeen,omitempty"` // latest access of the client, missing if the client was never seen
+	Activity []*repository.ClientActivity `json:"activity"`
+}
+
+// handleClientActivity responds with the files accessed by a client, most recent first. The repository query parameter
+// restricts the activity to a single repository
+func handleClientActivity(mgr *repository.Manager) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := &ClientActivityReport{ClientID: r.PathValue("id"), Activity: mgr.ClientActivity(r.PathValue("id"))}
+		if repo := r.URL.Query().Get("repository"); len(repo) > 0 {
+			report.Activity = slices.DeleteFunc(report.Activity, func(activity *repository.ClientActivity) bool { return activity.Repository != repo })
+		}
+		if len(report.Activity) > 0 {
+			report.LastSeen = &report.Activity[0].SeenAt
+		}
+
+		jsn, _ := json.Marshal(report)
+		Ok(w, jsn, "application/json;charset=utf-8")
+	}
+}
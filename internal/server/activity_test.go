@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientActivity(t *testing.T) {
+	repo := &configuration.Repository{Name: "samples", Backend: configuration.BackendLocal, Path: t.TempDir(), Clients: []string{clientID}}
+	assert.NoError(t, os.WriteFile(filepath.Join(repo.Path, "app.yml"), []byte("version: 1"), 0600))
+	mgr, err := repository.NewManager(&configuration.Repositories{Configuration: []*configuration.Repository{repo}})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mgr.Start(ctx)
+	assert.Eventually(t, func() bool { return mgr.Repositories["samples"].Backend.Available() }, 5*time.Second, 10*time.Millisecond)
+	file, err := mgr.Get(ctx, "samples", "", "app.yml", clientID)
+	assert.NoError(t, err)
+
+	query := func(id string, target string) *ClientActivityReport {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		handleClientActivity(mgr)(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		report := &ClientActivityReport{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), report))
+		return report
+	}
+
+	report := query(clientID, "/api/clients/"+clientID+"/activity")
+	assert.Len(t, report.Activity, 1)
+	assert.Equal(t, file.Revision, report.Activity[0].Commit)
+	assert.Equal(t, report.Activity[0].SeenAt, *report.LastSeen)
+
+	assert.Empty(t, query(clientID, "/api/clients/"+clientID+"/activity?repository=other").Activity)
+	report = query("unknown", "/api/clients/unknown/activity")
+	assert.Empty(t, report.Activity)
+	assert.Nil(t, report.LastSeen)
+}
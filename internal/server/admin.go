@@ -0,0 +1,202 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/repository"
+)
+
+// maxRepositoryDefinitionSize is the largest repository definition accepted by the administration endpoints
+const maxRepositoryDefinitionSize = 1 << 20
+
+// redactedSecret replaces the sensitive values of the repository definitions returned by the administration endpoints.
+// Definitions sent back with this value keep their current secret
+const redactedSecret = "***"
+
+// adminOnly is a middleware which ensures the authenticated client is allowed to administrate the repositories, it
+// must be chained after authenticatedOnly
+func adminOnly(runtime *configuration.Runtime) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			clientID := r.Context().Value(ctxClientID{}).(string)
+			if !slices.Contains(runtime.Current().Server.AdminClients, clientID) {
+				HTTPForbidden(w, r, "client '%s' is not allowed to administrate the repositories", clientID)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// redact returns a copy of the repository definition whose sensitive values are hidden
+func redact(repo *configuration.Repository) *configuration.Repository {
+	hide := func(value *string) {
+		if len(*value) > 0 {
+			*value = redactedSecret
+		}
+	}
+
+	redacted := *repo
+	hide(&redacted.Token)
+	hide(&redacted.WebhookSecret)
+	if repo.Auth != nil {
+		auth := *repo.Auth
+		hide(&auth.Password)
+		hide(&auth.BearerToken)
+		hide(&auth.SSHKey)
+		hide(&auth.SSHKeyPassphrase)
+		redacted.Auth = &auth
+	}
+	return &redacted
+}
+
+// unredact restores the sensitive values which were sent back redacted from the current repository definition
+func unredact(repo *configuration.Repository, current *configuration.Repository) {
+	restore := func(value *string, previous string) {
+		if *value == redactedSecret {
+			*value = previous
+		}
+	}
+
+	restore(&repo.Token, current.Token)
+	restore(&repo.WebhookSecret, current.WebhookSecret)
+	if repo.Auth != nil {
+		previous := current.Auth
+		if previous == nil {
+			previous = &configuration.Authentication{}
+		}
+		restore(&repo.Auth.Password, previous.Password)
+		restore(&repo.Auth.BearerToken, previous.BearerToken)
+		restore(&repo.Auth.SSHKey, previous.SSHKey)
+		restore(&repo.Auth.SSHKeyPassphrase, previous.SSHKeyPassphrase)
+	}
+}
+
+// decodeRepository reads the repository definition sent in the request body
+func decodeRepository(w http.ResponseWriter, r *http.Request) (*configuration.Repository, error) {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRepositoryDefinitionSize))
+	decoder.DisallowUnknownFields()
+	repo := &configuration.Repository{}
+	if err := decoder.Decode(repo); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// writeAdminError converts the errors returned by the repository administration to the matching HTTP status
+func writeAdminError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, repository.ErrRepositoryNotFound):
+		HTTPNotFound(w, r, "%s", err)
+	case errors.Is(err, repository.ErrRepositoryExists):
+		HTTPConflict(w, r, "%s", err)
+	case errors.Is(err, repository.ErrInvalidRepository):
+		HTTPBadRequest(w, r, "%s", err)
+	default:
+		HTTPInternalServerError(w, r, "%s", err)
+	}
+}
+
+// handleRepositoryList returns the definition of the served repositories
+func handleRepositoryList(mgr *repository.Manager) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		definitions := []*configuration.Repository{}
+		for _, repo := range mgr.Definitions() {
+			definitions = append(definitions, redact(repo))
+		}
+		jsn, _ := json.Marshal(definitions)
+		Ok(w, jsn, "application/json;charset=utf-8")
+	}
+}
+
+// handleRepositoryGet returns the definition of a repository
+func handleRepositoryGet(mgr *repository.Manager) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		repo, ok := mgr.Definition(name)
+		if !ok {
+			HTTPNotFound(w, r, "repository '%s' does not exist", name)
+			return
+		}
+		jsn, _ := json.Marshal(redact(repo))
+		Ok(w, jsn, "application/json;charset=utf-8")
+	}
+}
+
+// handleRepositoryCreate starts serving the repository defined in the request body
+func handleRepositoryCreate(mgr *repository.Manager) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo, err := decodeRepository(w, r)
+		if err != nil {
+			HTTPBadRequest(w, r, "invalid repository definition : %s", err)
+			return
+		}
+
+		if err := mgr.Add(repo); err != nil {
+			writeAdminError(w, r, err)
+			return
+		}
+		jsn, _ := json.Marshal(redact(repo))
+		Created(w, "/api/repositories/"+url.PathEscape(repo.Name), jsn, "application/json;charset=utf-8")
+	}
+}
+
+// handleRepositoryUpdate replaces the definition of a repository by the one provided in the request body.
+// Sensitive values sent back redacted keep their current value
+func handleRepositoryUpdate(mgr *repository.Manager) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		repo, err := decodeRepository(w, r)
+		if err != nil {
+			HTTPBadRequest(w, r, "invalid repository definition : %s", err)
+			return
+		}
+		if len(repo.Name) > 0 && repo.Name != name {
+			HTTPBadRequest(w, r, "repositories cannot be renamed, '%s' does not match '%s'", repo.Name, name)
+			return
+		}
+		repo.Name = name
+
+		current, ok := mgr.Definition(name)
+		if !ok {
+			HTTPNotFound(w, r, "repository '%s' does not exist", name)
+			return
+		}
+		unredact(repo, current)
+
+		if err := mgr.Update(repo); err != nil {
+			writeAdminError(w, r, err)
+			return
+		}
+		jsn, _ := json.Marshal(redact(repo))
+		Ok(w, jsn, "application/json;charset=utf-8")
+	}
+}
+
+// handleRepositoryDelete stops serving a repository
+func handleRepositoryDelete(mgr *repository.Manager) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := mgr.Remove(r.PathValue("name")); err != nil {
+			writeAdminError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleRepositoryRefresh requests a repository to be updated without waiting for its next scheduled update
+func handleRepositoryRefresh(mgr *repository.Manager) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := mgr.Refresh(r.PathValue("name")); err != nil {
+			writeAdminError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
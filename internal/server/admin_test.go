@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func adminRequest(method string, target string, body string, name string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), ctxClientID{}, "admin"))
+	if len(name) > 0 {
+		req.SetPathValue("name", name)
+	}
+	return req
+}
+
+func TestAdminOnly(t *testing.T) {
+	c := *configuration.DefaultConfiguration
+	server := *c.Server
+	server.AdminClients = []string{"admin"}
+	c.Server = &server
+	mdw := adminOnly(configuration.NewRuntime(&c))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	mdw.ServeHTTP(w, adminRequest(http.MethodGet, "/api/repositories", "", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/repositories", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ctxClientID{}, "client"))
+	w = httptest.NewRecorder()
+	mdw.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestSelfRegisteredAdminIsRejected(t *testing.T) {
+	c := *configuration.DefaultConfiguration
+	server := *c.Server
+	server.AdminClients = []string{"admin"}
+	c.Server = &server
+	runtime := configuration.NewRuntime(&c)
+	mdw := adminAuthenticatedOnly(runtime)(adminOnly(runtime)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	authenticated := func(id string, secret string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/repositories", nil)
+		req.SetBasicAuth(id, secret)
+		return req
+	}
+
+	w := httptest.NewRecorder()
+	handleClientRegistration(runtime)(w, httptest.NewRequest(http.MethodGet, "/api/register?client_id=admin", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	secret, _ := generateClientSecret("admin", 1, server.PassPhrase)
+	w = httptest.NewRecorder()
+	mdw.ServeHTTP(w, authenticated("admin", secret))
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "client secrets must not grant administration rights")
+
+	registration, err := RegisterAdminClient(&c, "admin")
+	assert.NoError(t, err)
+	w = httptest.NewRecorder()
+	mdw.ServeHTTP(w, authenticated("admin", registration.ClientSecret))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	_, err = RegisterAdminClient(&c, "client")
+	assert.Error(t, err)
+}
+
+func TestRedact(t *testing.T) {
+	repo := &configuration.Repository{Name: "repo", Token: "token", Auth: &configuration.Authentication{Username: "git", SSHKey: "key"}}
+	redacted := redact(repo)
+	assert.Equal(t, redactedSecret, redacted.Token)
+	assert.Equal(t, redactedSecret, redacted.Auth.SSHKey)
+	assert.Equal(t, "git", redacted.Auth.Username)
+	assert.Empty(t, redacted.Auth.Password)
+	assert.Equal(t, "key", repo.Auth.SSHKey, "the definition must not be altered")
+
+	unredact(redacted, repo)
+	assert.Equal(t, repo, redacted)
+}
+
+func TestRepositoryAdministration(t *testing.T) {
+	mgr, err := repository.NewManager(&configuration.Repositories{})
+	assert.NoError(t, err)
+	definition := `{"name":"local","backend":"local","path":"` + t.TempDir() + `","token":"secret"}`
+
+	w := httptest.NewRecorder()
+	handleRepositoryCreate(mgr)(w, adminRequest(http.MethodPost, "/api/repositories", definition, ""))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/api/repositories/local", w.Header().Get("Location"))
+	assert.Contains(t, w.Body.String(), `"token":"***"`)
+
+	w = httptest.NewRecorder()
+	handleRepositoryCreate(mgr)(w, adminRequest(http.MethodPost, "/api/repositories", definition, ""))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = httptest.NewRecorder()
+	handleRepositoryCreate(mgr)(w, adminRequest(http.MethodPost, "/api/repositories", `{"name":"remote"}`, ""))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	updated := strings.Replace(definition, `"secret"`, `"***","clients":["client"]`, 1)
+	w = httptest.NewRecorder()
+	handleRepositoryUpdate(mgr)(w, adminRequest(http.MethodPut, "/api/repositories/local", updated, "local"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	current, _ := mgr.Definition("local")
+	assert.Equal(t, "secret", current.Token)
+	assert.Equal(t, []string{"client"}, current.Clients)
+
+	w = httptest.NewRecorder()
+	handleRepositoryUpdate(mgr)(w, adminRequest(http.MethodPut, "/api/repositories/other", updated, "other"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	handleRepositoryRefresh(mgr)(w, adminRequest(http.MethodPost, "/api/repositories/local/refresh", "", "local"))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	w = httptest.NewRecorder()
+	handleRepositoryList(mgr)(w, adminRequest(http.MethodGet, "/api/repositories", "", ""))
+	assert.Contains(t, w.Body.String(), `"name":"local"`)
+	assert.NotContains(t, w.Body.String(), "secret")
+
+	w = httptest.NewRecorder()
+	handleRepositoryDelete(mgr)(w, adminRequest(http.MethodDelete, "/api/repositories/local", "", "local"))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	handleRepositoryGet(mgr)(w, adminRequest(http.MethodGet, "/api/repositories/local", "", "local"))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -3,50 +3,46 @@ package server
 import (
 	"context"
 	b64 "encoding/base64"
+	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/tracing"
 )
 
 const msgInvalidAuthHeader = "Invalid authorization header"
 
 // AuthenticatedOnly is a middleware which ensures the requests contains a valid Basic authentication.
 // If the authentication succeeds the request context is augmented with the clientId key.
-func authenticatedOnly(c *configuration.Configuration) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		fn := func(w http.ResponseWriter, r *http.Request) {
-			authorization := r.Header.Get("Authorization")
-
-			authComponents := strings.Split(authorization, " ")
-			if len(authComponents) != 2 {
-				HTTPUnauthorized(w, r, msgInvalidAuthHeader)
-				return
-			}
+func authenticatedOnly(runtime *configuration.Runtime) func(http.Handler) http.Handler {
+	return requireSecret(runtime, func(s *configuration.Server) string { return s.PassPhrase })
+}
 
-			if strings.ToLower(authComponents[0]) != "basic" {
-				HTTPUnauthorized(w, r, "Unsupported authorization scheme '%s'", authComponents[0])
-				return
-			}
+// adminAuthenticatedOnly is a middleware which ensures the requests contains a valid administrator Basic
+// authentication. Administrator secrets are encrypted using a dedicated key, see adminPassPhrase, so that the secrets
+// issued by /api/register cannot be used to administrate the server
+func adminAuthenticatedOnly(runtime *configuration.Runtime) func(http.Handler) http.Handler {
+	return requireSecret(runtime, func(s *configuration.Server) string { return adminPassPhrase(s.PassPhrase) })
+}
 
-			basicAuth, err := b64.StdEncoding.DecodeString(authComponents[1])
+// requireSecret is a middleware which ensures the requests contains a Basic authentication whose secret was encrypted
+// using the provided pass phrase
+func requireSecret(runtime *configuration.Runtime, passPhrase func(s *configuration.Server) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			_, span := tracing.Start(r.Context(), "authenticate")
+			server := runtime.Current().Server
+			clientID, err := authenticate(r.Header.Get("Authorization"), passPhrase(server), server.ValidateSecretLifeSpan)
+			span.RecordError(err)
+			span.SetAttributes(tracing.String("enduser.id", clientID))
+			span.End()
 			if err != nil {
-				HTTPUnauthorized(w, r, msgInvalidAuthHeader)
-				return
-			}
-
-			loginPwd := strings.Split(string(basicAuth), ":")
-			if len(loginPwd) != 2 {
-				HTTPUnauthorized(w, r, msgInvalidAuthHeader)
+				HTTPUnauthorized(w, r, "%s", err)
 				return
 			}
 
-			if !validateClientSecret(loginPwd[0], loginPwd[1], c.Server.PassPhrase, c.Server.ValidateSecretLifeSpan) {
-				HTTPUnauthorized(w, r, "client '%s' is not allowed to access this repository", loginPwd[0])
-				return
-			}
-
-			ctx := context.WithValue(r.Context(), ctxClientID{}, loginPwd[0])
+			ctx := context.WithValue(r.Context(), ctxClientID{}, clientID)
 			rWithCtx := r.WithContext(ctx)
 
 			next.ServeHTTP(w, rWithCtx)
@@ -54,3 +50,34 @@ func authenticatedOnly(c *configuration.Configuration) func(http.Handler) http.H
 		return http.HandlerFunc(fn)
 	}
 }
+
+// authenticate validates the Basic authorization header and returns the authenticated client id
+func authenticate(authorization string, passPhrase string, enforceValidity bool) (string, error) {
+	authComponents := strings.Split(authorization, " ")
+	if len(authComponents) != 2 {
+		secretValidationFailures.Inc(secretInvalidHeader)
+		return "", fmt.Errorf(msgInvalidAuthHeader)
+	}
+
+	if strings.ToLower(authComponents[0]) != "basic" {
+		secretValidationFailures.Inc(secretUnsupportedScheme)
+		return "", fmt.Errorf("Unsupported authorization scheme '%s'", authComponents[0])
+	}
+
+	basicAuth, err := b64.StdEncoding.DecodeString(authComponents[1])
+	if err != nil {
+		secretValidationFailures.Inc(secretInvalidHeader)
+		return "", fmt.Errorf(msgInvalidAuthHeader)
+	}
+
+	loginPwd := strings.Split(string(basicAuth), ":")
+	if len(loginPwd) != 2 {
+		secretValidationFailures.Inc(secretInvalidHeader)
+		return "", fmt.Errorf(msgInvalidAuthHeader)
+	}
+
+	if !validateClientSecret(loginPwd[0], loginPwd[1], passPhrase, enforceValidity) {
+		return "", fmt.Errorf("client '%s' is not allowed to access this repository", loginPwd[0])
+	}
+	return loginPwd[0], nil
+}
@@ -15,7 +15,7 @@ var AuthTestConfiguration = configuration.DefaultConfiguration
 
 func TestInvalidAuthorizationScheme(t *testing.T) {
 	next := func(w http.ResponseWriter, r *http.Request) {}
-	mdw := authenticatedOnly(AuthTestConfiguration)
+	mdw := authenticatedOnly(configuration.NewRuntime(AuthTestConfiguration))
 
 	req := httptest.NewRequest(http.MethodGet, "https://www.your-domain.com/git", nil)
 	req.Header.Add("Authorization", fmt.Sprintf("Bearer:%s", "token"))
@@ -28,7 +28,7 @@ func TestInvalidAuthorizationScheme(t *testing.T) {
 
 func TestMalformedBasicAuth(t *testing.T) {
 	next := func(w http.ResponseWriter, r *http.Request) {}
-	mdw := authenticatedOnly(AuthTestConfiguration)
+	mdw := authenticatedOnly(configuration.NewRuntime(AuthTestConfiguration))
 
 	req := httptest.NewRequest(http.MethodGet, "https://www.your-domain.com/git", nil)
 	req.Header.Add("Authorization", fmt.Sprintf("Basic %s", "token"))
@@ -41,7 +41,7 @@ func TestMalformedBasicAuth(t *testing.T) {
 
 func TestInvalidClientSecret(t *testing.T) {
 	next := func(w http.ResponseWriter, r *http.Request) {}
-	mdw := authenticatedOnly(AuthTestConfiguration)
+	mdw := authenticatedOnly(configuration.NewRuntime(AuthTestConfiguration))
 
 	token := b64.StdEncoding.EncodeToString([]byte("a:b:c"))
 
@@ -62,7 +62,7 @@ func TestValidClientSecret(t *testing.T) {
 	next := func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, id, r.Context().Value(ctxClientID{}))
 	}
-	mdw := authenticatedOnly(AuthTestConfiguration)
+	mdw := authenticatedOnly(configuration.NewRuntime(AuthTestConfiguration))
 
 	req := httptest.NewRequest(http.MethodGet, "https://www.your-domain.com/git", nil)
 	req.Header.Add("Authorization", fmt.Sprintf("Basic %s", token))
@@ -15,6 +15,11 @@ const (
 	ClientSecretComponents    = 2   // ClientSecretComponents is the number of components used in client secrets
 )
 
+// adminPassPhrase derives the key administrator secrets are encrypted with from the server pass phrase
+func adminPassPhrase(passPhrase string) string {
+	return "admin" + ClientSecretSeparatorChar + passPhrase
+}
+
 // generateClientSecret creates a new client secret which will be valid for the given number of days
 // The generated client secret is bound to the provided client id
 func generateClientSecret(clientID string, expiresInDays int, passPhrase string) (string, time.Time) {
@@ -24,31 +29,54 @@ func generateClientSecret(clientID string, expiresInDays int, passPhrase string)
 	return b64.StdEncoding.EncodeToString(utils.AesEncrypt(idStr, passPhrase)), expires
 }
 
+// Reasons for which client secrets are rejected, see secretValidationFailures
+const (
+	secretInvalidHeader     = "invalid_header"     // the authorization header is missing or malformed
+	secretUnsupportedScheme = "unsupported_scheme" // the authorization header does not use the basic scheme
+	secretMalformed         = "malformed"          // the secret is not a base64 encoded client secret
+	secretUndecryptable     = "undecryptable"      // the secret was not generated using the current passphrase
+	secretExpired           = "expired"            // the secret expired and secret lifespans are enforced
+	secretClientMismatch    = "client_mismatch"    // the secret was generated for another client
+)
+
 // validateClientSecret checks the provided client secret is valid and bound to the provided clientID
-// if enforceValidity is true and if the secret expired validateClientSecret will consider the seccret as invalid
+// if enforceValidity is true and if the secret expired validateClientSecret will consider the seccret as invalid.
+// Rejections are counted by reason for monitoring purposes
 func validateClientSecret(clientID string, clientSecret string, passPhrase string, enforceValidity bool) bool {
+	if reason := checkClientSecret(clientID, clientSecret, passPhrase, enforceValidity); len(reason) > 0 {
+		secretValidationFailures.Inc(reason)
+		return false
+	}
+	return true
+}
+
+// checkClientSecret returns the reason why the provided client secret is rejected, or an empty string if it is valid
+func checkClientSecret(clientID string, clientSecret string, passPhrase string, enforceValidity bool) string {
 	bytes, err := b64.StdEncoding.DecodeString(clientSecret)
 	if err != nil {
-		return false
+		return secretMalformed
 	}
 
 	secret, err := utils.AesDecrypt(bytes, passPhrase)
 	if err != nil {
-		return false
+		return secretUndecryptable
 	}
 
 	elements := strings.Split(secret, ClientSecretSeparatorChar)
 	if len(elements) != ClientSecretComponents {
-		return false
+		return secretMalformed
 	}
 
 	expiresAt, err := time.Parse(time.RFC3339, elements[0])
 	if err == nil && time.Now().After(expiresAt) {
 		if enforceValidity {
-			return false
+			return secretExpired
 		}
 		slog.Warn("client secret is expired, consider regenerating it", "client_id", clientID, "time_generated", expiresAt)
 	}
 
-	return elements[1] == clientID
+	if elements[1] != clientID {
+		return secretClientMismatch
+	}
+	return ""
 }
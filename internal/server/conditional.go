@@ -0,0 +1,77 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/repository"
+	"github.com/fredjeck/configserver/internal/utils"
+)
+
+// validators identify a representation of one or more repository files so that clients can revalidate their cached copies
+type validators struct {
+	etag         string
+	lastModified time.Time
+}
+
+// newValidators computes the validators of a representation built from the provided files.
+// The ETag is derived from the files blob hashes and from the version of the key used to decrypt their sensitive values
+// so that rotating the passphrase invalidates the cached copies. The variant distinguishes the representations
+// rendered from the same files i.e after a format conversion
+func newValidators(passPhrase string, variant string, files ...*repository.File) *validators {
+	v := &validators{}
+	hash := sha256.New()
+	for _, file := range files {
+		hash.Write([]byte(file.Hash))
+		if file.LastModified.After(v.lastModified) {
+			v.lastModified = file.LastModified
+		}
+	}
+
+	tag := ""
+	if len(files) == 1 && len(variant) == 0 {
+		tag = files[0].Hash
+	} else {
+		hash.Write([]byte(variant))
+		tag = hex.EncodeToString(hash.Sum(nil)[:20])
+	}
+	v.etag = `"` + tag + "-" + utils.KeyVersion(passPhrase) + `"`
+	return v
+}
+
+// write adds the validators to the response headers
+func (v *validators) write(w http.ResponseWriter) {
+	w.Header().Set("ETag", v.etag)
+	if !v.lastModified.IsZero() {
+		w.Header().Set("Last-Modified", v.lastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// fresh returns true if the client's cached copy, described by the request preconditions, is still valid.
+// As mandated by RFC9110 If-Modified-Since is ignored whenever If-None-Match is provided
+func (v *validators) fresh(r *http.Request) bool {
+	if inm := r.Header.Get("If-None-Match"); len(inm) > 0 {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == v.etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); len(ims) > 0 && !v.lastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		return err == nil && !v.lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// NotModified returns an HTTP 304 response carrying the representation validators
+func NotModified(w http.ResponseWriter, v *validators) {
+	v.write(w)
+	w.WriteHeader(http.StatusNotModified)
+}
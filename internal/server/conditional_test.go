@@ -0,0 +1,73 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/repository"
+	"github.com/fredjeck/configserver/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var testFile = &repository.File{
+	Path:         "app.yml",
+	Hash:         "8ab686eafeb1f44702738c8b0f24f2567c36da6d",
+	LastModified: time.Date(2024, 3, 14, 9, 26, 53, 500, time.UTC),
+}
+
+func TestValidatorsETag(t *testing.T) {
+	v := newValidators("passphrase", "", testFile)
+	assert.Equal(t, `"`+testFile.Hash+"-"+utils.KeyVersion("passphrase")+`"`, v.etag)
+	assert.Equal(t, testFile.LastModified, v.lastModified)
+
+	assert.NotEqual(t, v.etag, newValidators("rotated passphrase", "", testFile).etag, "key rotations must invalidate cached copies")
+	assert.NotEqual(t, v.etag, newValidators("passphrase", "json", testFile).etag, "conversions must be distinguished")
+
+	older := &repository.File{Hash: "d670460b4b4aece5915caf5c68d12f560a9fe3e4", LastModified: testFile.LastModified.Add(-time.Hour)}
+	merged := newValidators("passphrase", "", older, testFile)
+	assert.Equal(t, testFile.LastModified, merged.lastModified, "merged documents are as recent as their most recent file")
+	assert.NotEqual(t, merged.etag, newValidators("passphrase", "", testFile, older).etag)
+}
+
+func TestValidatorsIfNoneMatch(t *testing.T) {
+	v := newValidators("passphrase", "", testFile)
+	req := httptest.NewRequest(http.MethodGet, "/git/repo/app.yml", nil)
+	assert.False(t, v.fresh(req))
+
+	req.Header.Set("If-None-Match", `"stale", W/`+v.etag)
+	assert.True(t, v.fresh(req))
+
+	req.Header.Set("If-None-Match", `"stale"`)
+	req.Header.Set("If-Modified-Since", time.Now().UTC().Format(http.TimeFormat))
+	assert.False(t, v.fresh(req), "If-Modified-Since must be ignored when If-None-Match is provided")
+
+	req.Header.Set("If-None-Match", "*")
+	assert.True(t, v.fresh(req))
+}
+
+func TestValidatorsIfModifiedSince(t *testing.T) {
+	v := newValidators("passphrase", "", testFile)
+	req := httptest.NewRequest(http.MethodGet, "/git/repo/app.yml", nil)
+
+	req.Header.Set("If-Modified-Since", testFile.LastModified.Format(http.TimeFormat))
+	assert.True(t, v.fresh(req))
+
+	req.Header.Set("If-Modified-Since", testFile.LastModified.Add(-time.Second).Format(http.TimeFormat))
+	assert.False(t, v.fresh(req))
+
+	req.Header.Set("If-Modified-Since", "yesterday")
+	assert.False(t, v.fresh(req))
+}
+
+func TestNotModified(t *testing.T) {
+	v := newValidators("passphrase", "", testFile)
+	w := httptest.NewRecorder()
+	NotModified(w, v)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, v.etag, w.Header().Get("ETag"))
+	assert.Equal(t, "Thu, 14 Mar 2024 09:26:53 GMT", w.Header().Get("Last-Modified"))
+	assert.Empty(t, w.Body.Bytes())
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"context"
+
+	"github.com/fredjeck/configserver/internal/document"
+	"github.com/fredjeck/configserver/internal/tracing"
+	"github.com/fredjeck/configserver/internal/utils"
+)
+
+// detokenize replaces the tokens of the provided text by their clear text value, failures are counted
+func detokenize(ctx context.Context, text string, passPhrase string) (string, error) {
+	_, span := tracing.Start(ctx, "detokenize")
+	defer span.End()
+
+	clear, err := utils.Detokenize(text, passPhrase)
+	if err != nil {
+		detokenizationFailures.Inc()
+		span.RecordError(err)
+	}
+	return clear, err
+}
+
+// detokenizeTree replaces the tokens of the string values of a document by their clear text value, failures are counted
+func detokenizeTree(ctx context.Context, tree map[string]any, passPhrase string) error {
+	_, span := tracing.Start(ctx, "detokenize")
+	defer span.End()
+
+	err := document.MapStrings(tree, func(value string) (string, error) {
+		clear, err := utils.Detokenize(value, passPhrase)
+		if err != nil {
+			detokenizationFailures.Inc()
+		}
+		return clear, err
+	})
+	span.RecordError(err)
+	return err
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fredjeck/configserver/internal/document"
+)
+
+// formatMimeTypes are the content types used to respond with documents in a given format
+var formatMimeTypes = map[document.Format]string{
+	document.YAML:       "application/yaml;charset=utf-8",
+	document.JSON:       "application/json;charset=utf-8",
+	document.Properties: "text/x-java-properties;charset=utf-8",
+	document.Dotenv:     "text/plain;charset=utf-8",
+	document.TOML:       "application/toml;charset=utf-8",
+}
+
+// acceptedMimeTypes maps the media types clients can list in their Accept header to documents formats
+var acceptedMimeTypes = map[string]document.Format{
+	"application/yaml":       document.YAML,
+	"application/x-yaml":     document.YAML,
+	"text/yaml":              document.YAML,
+	"text/x-yaml":            document.YAML,
+	"application/json":       document.JSON,
+	"text/x-java-properties": document.Properties,
+	"text/x-properties":      document.Properties,
+	"text/x-dotenv":          document.Dotenv,
+	"application/toml":       document.TOML,
+}
+
+// negotiateFormat determines the format in which a document must be rendered using the format query parameter or,
+// when not provided, the Accept header. An empty format is returned if the client has no supported preference.
+// explicit is true when the format was requested via the query parameter, an error is returned if it is not supported
+func negotiateFormat(r *http.Request) (format document.Format, explicit bool, err error) {
+	if requested := r.URL.Query().Get("format"); len(requested) > 0 {
+		format, ok := document.ParseFormat(requested)
+		if !ok {
+			return "", true, fmt.Errorf("'%s' : %w", requested, document.ErrUnsupportedFormat)
+		}
+		return format, true, nil
+	}
+
+	type preference struct {
+		format document.Format
+		q      float64
+	}
+	var preferences []preference
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		format, ok := acceptedMimeTypes[mediaType]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			preferences = append(preferences, preference{format, q})
+		}
+	}
+
+	if len(preferences) == 0 {
+		return "", false, nil
+	}
+	sort.SliceStable(preferences, func(i, j int) bool { return preferences[i].q > preferences[j].q })
+	return preferences[0].format, false, nil
+}
+
+// formatOptions reads the nested keys flattening options from the separator and uppercase query parameters
+func formatOptions(r *http.Request, format document.Format) *document.Options {
+	options := document.DefaultOptions(format)
+	query := r.URL.Query()
+	if query.Has("separator") {
+		options.Separator = query.Get("separator")
+	}
+	if uppercase, err := strconv.ParseBool(query.Get("uppercase")); err == nil {
+		options.Uppercase = uppercase
+	}
+	return options
+}
+
+// formatVariant identifies the rendering of a document in the provided format with the provided options
+func formatVariant(format document.Format, options *document.Options) string {
+	return fmt.Sprintf("%s;separator=%s;uppercase=%t", format, options.Separator, options.Uppercase)
+}
+
+// convert renders a document in the target format. Sensitive values are decrypted before the document is rendered
+// so that no token is ever altered by the conversion
+func convert(ctx context.Context, content []byte, source document.Format, target document.Format, options *document.Options, passPhrase string) ([]byte, error) {
+	tree, err := document.Parse(content, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := detokenizeTree(ctx, tree, passPhrase); err != nil {
+		return nil, err
+	}
+
+	return document.Marshal(tree, target, options)
+}
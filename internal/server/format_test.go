@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fredjeck/configserver/internal/document"
+	"github.com/fredjeck/configserver/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateFormatFromQuery(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/git/repo/file.yml?format=toml", nil)
+	req.Header.Set("Accept", "application/json")
+
+	format, explicit, err := negotiateFormat(req)
+	assert.NoError(t, err)
+	assert.True(t, explicit)
+	assert.Equal(t, document.TOML, format)
+}
+
+func TestNegotiateUnsupportedFormat(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/git/repo/file.yml?format=xml", nil)
+	_, _, err := negotiateFormat(req)
+	assert.ErrorIs(t, err, document.ErrUnsupportedFormat)
+}
+
+func TestNegotiateFormatFromAcceptHeader(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/git/repo/file.yml", nil)
+	req.Header.Set("Accept", "text/html, application/json;q=0.5, application/yaml;q=0.9, */*;q=0.1")
+
+	format, explicit, err := negotiateFormat(req)
+	assert.NoError(t, err)
+	assert.False(t, explicit)
+	assert.Equal(t, document.YAML, format)
+
+	req.Header.Set("Accept", "*/*")
+	format, _, _ = negotiateFormat(req)
+	assert.Empty(t, format)
+}
+
+func TestFormatOptions(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/git/repo/file.yml?format=dotenv&separator=__&uppercase=false", nil)
+	assert.Equal(t, &document.Options{Separator: "__", Uppercase: false}, formatOptions(req, document.Dotenv))
+}
+
+func TestConvertDecryptsBeforeRendering(t *testing.T) {
+	passPhrase := "conversion passphrase"
+	source := "database:\n  password: '" + utils.CreateToken("it's a secret", passPhrase) + "'\n"
+
+	converted, err := convert(context.Background(), []byte(source), document.YAML, document.Dotenv, nil, passPhrase)
+	assert.NoError(t, err)
+	assert.Equal(t, "DATABASE_PASSWORD=\"it's a secret\"\n", string(converted))
+}
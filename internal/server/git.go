@@ -1,41 +1,247 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
 
 	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/document"
 	"github.com/fredjeck/configserver/internal/repository"
-	"github.com/fredjeck/configserver/internal/utils"
 )
 
-// handleGitRepositoryAccess matches requests with git repositories and returns the request files
-func handleGitRepositoryAccess(mgr *repository.Manager, c *configuration.Configuration) func(w http.ResponseWriter, r *http.Request) {
+// handleGitRepositoryAccess matches requests with git repositories and returns the request files.
+// The repository path segment can be suffixed by @{ref} to read the files at a given branch, tag or commit.
+// When the profiles query parameter is provided, the requested file is merged with its profile specific overlays.
+// Paths ending with a slash or requested with the list query parameter are listed as directories
+func handleGitRepositoryAccess(mgr *repository.Manager, runtime *configuration.Runtime) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		c := runtime.Current()
 		clientID := r.Context().Value(ctxClientID{}).(string)
 		requestID := r.Context().Value(ctxRequestID{}).(string)
-		repo := r.PathValue("repository")
+		repo, ref, _ := strings.Cut(r.PathValue("repository"), "@")
 		path := r.PathValue("path")
 
-		content, err := mgr.Get(repo, path, clientID)
+		if list, _ := strconv.ParseBool(r.URL.Query().Get("list")); list || len(path) == 0 || strings.HasSuffix(path, "/") {
+			serveListing(w, r, mgr, repo, ref, path)
+			return
+		}
+
+		if profiles := r.URL.Query().Get("profiles"); len(profiles) > 0 {
+			serveProfiles(w, r, mgr, c, repo, ref, path, strings.Split(profiles, ","))
+			return
+		}
+
+		target, explicit, err := negotiateFormat(r)
+		if err != nil {
+			HTTPBadRequest(w, r, "%s", err)
+			return
+		}
+
+		file, err := mgr.Get(r.Context(), repo, ref, path, clientID)
 		if err != nil {
-			if errors.Is(err, repository.ErrRepositoryNotFound) {
-				HTTPNotFound(w, r, "repository '%s' was not found on this server", repo)
-			} else if errors.Is(err, repository.ErrClientNotAllowed) {
-				HTTPUnauthorized(w, r, "client '%s' is not allowed to access this repository", clientID)
-			} else {
-				HTTPInternalServerError(w, r, "%w", []interface{}{err}...)
+			writeRepositoryError(w, r, err, repo, ref, path)
+			return
+		}
+
+		// Conversions are only attempted for known formats, Accept headers are otherwise ignored
+		source, known := document.FormatOf(path)
+		if explicit && !known {
+			HTTPNotAcceptable(w, r, "'%s' cannot be converted to %s", path, target)
+			return
+		}
+		w.Header().Set("Vary", "Accept")
+
+		if known && len(target) > 0 && target != source {
+			options := formatOptions(r, target)
+			cache := newValidators(c.Server.PassPhrase, formatVariant(target, options), file)
+			if cache.fresh(r) {
+				NotModified(w, cache)
+				return
 			}
+
+			converted, err := convert(r.Context(), file.Content, source, target, options, c.Server.PassPhrase)
+			if err != nil {
+				slog.Error("An error occured while converting the requested file", "error", err, HTTPRequestID, requestID)
+				HTTPInternalServerError(w, r, "'%s' cannot be converted to %s", path, target)
+				return
+			}
+			cache.write(w)
+			Ok(w, converted, formatMimeTypes[target])
+			return
+		}
+
+		cache := newValidators(c.Server.PassPhrase, "", file)
+		if cache.fresh(r) {
+			NotModified(w, cache)
 			return
 		}
 
-		clear, err := utils.Detokenize(string(content[:]), c.Server.PassPhrase)
+		clear, err := detokenize(r.Context(), string(file.Content), c.Server.PassPhrase)
 		if err != nil {
 			slog.Error("An error occured while detokenizing the requested file", "error", err, HTTPRequestID, requestID)
 			HTTPInternalServerError(w, r, "An error occured while detokenizing the requested file")
+			return
+		}
+
+		mimetype := "text/plain"
+		if known && target == source {
+			mimetype = formatMimeTypes[source]
+		}
+		cache.write(w)
+		Ok(w, []byte(clear), mimetype)
+	}
+}
+
+// writeRepositoryError converts the errors returned by the repository manager to their HTTP counterpart
+func writeRepositoryError(w http.ResponseWriter, r *http.Request, err error, repo string, ref string, path string) {
+	clientID, _ := r.Context().Value(ctxClientID{}).(string)
+	if errors.Is(err, repository.ErrRepositoryNotFound) {
+		HTTPNotFound(w, r, "repository '%s' was not found on this server", repo)
+	} else if errors.Is(err, repository.ErrRepositoryUnavailable) {
+		HTTPServiceUnavailable(w, r, "repository '%s' is not available yet", repo)
+	} else if errors.Is(err, repository.ErrRefNotFound) {
+		HTTPNotFound(w, r, "reference '%s' was not found in repository '%s'", ref, repo)
+	} else if errors.Is(err, repository.ErrFileNotFound) {
+		HTTPNotFound(w, r, "'%s' was not found in repository '%s'", path, repo)
+	} else if errors.Is(err, repository.ErrUntrustedRevision) {
+		HTTPForbidden(w, r, "reference '%s' of repository '%s' is not signed by a trusted key", ref, repo)
+	} else if errors.Is(err, repository.ErrNotADirectory) {
+		HTTPBadRequest(w, r, "'%s' is not a directory", path)
+	} else if errors.Is(err, repository.ErrNotSupported) {
+		HTTPNotImplemented(w, r, "%s", err)
+	} else if errors.Is(err, repository.ErrClientNotAllowed) {
+		HTTPUnauthorized(w, r, "client '%s' is not allowed to access this repository", clientID)
+	} else {
+		HTTPInternalServerError(w, r, "%s", err)
+	}
+}
+
+// DirectoryListing is the response returned when listing a repository directory
+type DirectoryListing struct {
+	Repository string              `json:"repository"`
+	Ref        string              `json:"ref,omitempty"`
+	Path       string              `json:"path"`
+	Entries    []*repository.Entry `json:"entries"`
+}
+
+// serveListing responds with the files and sub directories found under the requested path
+func serveListing(w http.ResponseWriter, r *http.Request, mgr *repository.Manager, repo string, ref string, path string) {
+	clientID := r.Context().Value(ctxClientID{}).(string)
+	entries, err := mgr.List(repo, ref, path, clientID)
+	if err != nil {
+		writeRepositoryError(w, r, err, repo, ref, path)
+		return
+	}
+
+	jsn, _ := json.Marshal(&DirectoryListing{
+		Repository: repo,
+		Ref:        ref,
+		Path:       strings.Trim(path, "/"),
+		Entries:    entries,
+	})
+	Ok(w, jsn, "application/json;charset=utf-8")
+}
+
+// profileOverlays returns the overlays of the provided base file for each profile, in the order they must be applied
+// i.e config/application.yml with the prod and prod-eu profiles gives config/application-prod.yml and config/application-prod-eu.yml
+func profileOverlays(base string, profiles []string) []string {
+	extension := path.Ext(base)
+	name := strings.TrimSuffix(base, extension)
+
+	var overlays []string
+	for _, profile := range profiles {
+		profile = strings.TrimSpace(profile)
+		if len(profile) > 0 {
+			overlays = append(overlays, fmt.Sprintf("%s-%s%s", name, profile, extension))
+		}
+	}
+	return overlays
+}
+
+// mergeProfiles reads the base file and deep merges the profile overlays found in the repository, missing overlays are skipped.
+// The overlays are read at the revision the base file was read from so that the merged files belong to the same snapshot.
+// Sensitive values are decrypted once the documents are merged. The files which were merged are returned along the document
+func mergeProfiles(ctx context.Context, mgr *repository.Manager, repo string, ref string, base string, profiles []string, clientID string, passPhrase string) (map[string]any, []*repository.File, error) {
+	format, ok := document.FormatOf(base)
+	if !ok {
+		return nil, nil, fmt.Errorf("'%s' cannot be merged : %w", base, document.ErrUnsupportedFormat)
+	}
+
+	var merged map[string]any
+	var files []*repository.File
+	for i, name := range append([]string{base}, profileOverlays(base, profiles)...) {
+		file, err := mgr.Get(ctx, repo, ref, name, clientID)
+		if i > 0 && errors.Is(err, repository.ErrFileNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tree, err := document.Parse(file.Content, format)
+		if err != nil {
+			return nil, nil, fmt.Errorf("'%s' cannot be parsed : %w", name, err)
+		}
+		merged = document.Merge(merged, tree)
+		files = append(files, file)
+		if i == 0 && len(file.Revision) > 0 {
+			ref = file.Revision
+		}
+	}
+
+	err := detokenizeTree(ctx, merged, passPhrase)
+	return merged, files, err
+}
+
+// serveProfiles responds with the requested file merged with its profile overlays.
+// The document is rendered in the base file format unless another one is negotiated, see negotiateFormat
+func serveProfiles(w http.ResponseWriter, r *http.Request, mgr *repository.Manager, c *configuration.Configuration, repo string, ref string, path string, profiles []string) {
+	clientID := r.Context().Value(ctxClientID{}).(string)
+
+	format, ok := document.FormatOf(path)
+	if !ok {
+		HTTPBadRequest(w, r, "'%s' cannot be merged, only yaml, json, properties, dotenv and toml documents are supported", path)
+		return
+	}
+	target, _, err := negotiateFormat(r)
+	if err != nil {
+		HTTPBadRequest(w, r, "%s", err)
+		return
+	}
+	if len(target) > 0 {
+		format = target
+	}
+
+	merged, files, err := mergeProfiles(r.Context(), mgr, repo, ref, path, profiles, clientID, c.Server.PassPhrase)
+	if err != nil {
+		if errors.Is(err, document.ErrUnsupportedFormat) {
+			HTTPBadRequest(w, r, "%s", err)
+			return
 		}
+		writeRepositoryError(w, r, err, repo, ref, path)
+		return
+	}
+
+	options := formatOptions(r, format)
+	cache := newValidators(c.Server.PassPhrase, formatVariant(format, options), files...)
+	w.Header().Set("Vary", "Accept")
+	if cache.fresh(r) {
+		NotModified(w, cache)
+		return
+	}
 
-		Ok(w, []byte(clear), "text/plain")
+	content, err := document.Marshal(merged, format, options)
+	if err != nil {
+		HTTPInternalServerError(w, r, "%s", err)
+		return
 	}
+	cache.write(w)
+	Ok(w, content, formatMimeTypes[format])
 }
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileOverlays(t *testing.T) {
+	assert.Equal(t,
+		[]string{"config/application-prod.yml", "config/application-prod-eu.yml"},
+		profileOverlays("config/application.yml", []string{"prod", " prod-eu", ""}),
+	)
+	assert.Empty(t, profileOverlays("application.yml", nil))
+}
+
+func TestMergeProfilesReadsOneRevision(t *testing.T) {
+	repo := &configuration.Repository{Name: "samples", Backend: configuration.BackendLocal, Path: t.TempDir(), Clients: []string{clientID}}
+	assert.NoError(t, os.WriteFile(filepath.Join(repo.Path, "app.yml"), []byte("name: app\nlevel: info\n"), 0600))
+	assert.NoError(t, os.WriteFile(filepath.Join(repo.Path, "app-prod.yml"), []byte("level: warn\n"), 0600))
+	mgr, err := repository.NewManager(&configuration.Repositories{Configuration: []*configuration.Repository{repo}})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mgr.Start(ctx)
+	assert.Eventually(t, func() bool { return mgr.Repositories["samples"].Backend.Available() }, 5*time.Second, 10*time.Millisecond)
+
+	merged, files, err := mergeProfiles(ctx, mgr, "samples", "", "app.yml", []string{"prod", "eu"}, clientID, passPhrase)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "app", "level": "warn"}, merged)
+	assert.Len(t, files, 2)
+	assert.Equal(t, files[0].Revision, files[1].Revision, "overlays are read at the revision of the base file")
+}
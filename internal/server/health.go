@@ -0,0 +1,37 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/fredjeck/configserver/internal/repository"
+)
+
+// ReadinessReport is the response returned by the readiness probe
+type ReadinessReport struct {
+	Ready        bool                    `json:"ready"`
+	Repositories []*repository.Readiness `json:"repositories"`
+}
+
+// handleLiveness responds as long as the server is able to serve requests
+func handleLiveness() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		Ok(w, []byte("ok"), "text/plain;charset=utf-8")
+	}
+}
+
+// handleReadiness reports the repositories readiness, responding with a 503 status unless all the repositories which
+// are not optional are ready, see repository.Manager.Readiness
+func handleReadiness(mgr *repository.Manager) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ready, repositories := mgr.Readiness()
+		jsn, _ := json.Marshal(&ReadinessReport{Ready: ready, Repositories: repositories})
+		if ready {
+			Ok(w, jsn, "application/json;charset=utf-8")
+			return
+		}
+		w.Header().Add("Content-Type", "application/json;charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write(jsn)
+	}
+}
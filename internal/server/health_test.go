@@ -0,0 +1,37 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLiveness(t *testing.T) {
+	w := httptest.NewRecorder()
+	handleLiveness()(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestReadiness(t *testing.T) {
+	repo := &configuration.Repository{Name: "samples", Backend: configuration.BackendLocal, Path: t.TempDir()}
+	mgr, err := repository.NewManager(&configuration.Repositories{Configuration: []*configuration.Repository{repo}})
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	handleReadiness(mgr)(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	report := &ReadinessReport{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), report))
+	assert.False(t, report.Ready)
+	assert.Equal(t, "samples", report.Repositories[0].Name)
+
+	repo.Optional = true
+	w = httptest.NewRecorder()
+	handleReadiness(mgr)(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
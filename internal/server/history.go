@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fredjeck/configserver/internal/document"
+	"github.com/fredjeck/configserver/internal/repository"
+	"github.com/fredjeck/configserver/internal/utils"
+)
+
+// defaultHistoryLimit is the number of commits returned when no limit is requested
+const defaultHistoryLimit = 50
+
+// maxHistoryLimit is the largest number of commits which can be requested at once
+const maxHistoryLimit = 1000
+
+// FileHistory is the response returned when requesting the history of a file
+type FileHistory struct {
+	Repository string                  `json:"repository"`
+	Ref        string                  `json:"ref,omitempty"`
+	Path       string                  `json:"path"`
+	Commits    []*repository.CommitRef `json:"commits"`
+}
+
+// FileDiff is the response returned when requesting the key level differences of a file between two commits
+type FileDiff struct {
+	Repository string               `json:"repository"`
+	Path       string               `json:"path"`
+	From       string               `json:"from"`
+	To         string               `json:"to"`
+	Changes    []document.KeyChange `json:"changes"`
+}
+
+// handleFileHistory returns the commits which modified a file, most recent first.
+// The repository path segment can be suffixed by @{ref} to walk the history from a given branch, tag or commit
+func handleFileHistory(mgr *repository.Manager) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		clientID := r.Context().Value(ctxClientID{}).(string)
+		repo, ref, _ := strings.Cut(r.PathValue("repository"), "@")
+		path := r.PathValue("path")
+
+		limit := defaultHistoryLimit
+		if requested := r.URL.Query().Get("limit"); len(requested) > 0 {
+			parsed, err := strconv.Atoi(requested)
+			if err != nil || parsed <= 0 {
+				HTTPBadRequest(w, r, "'%s' is not a valid limit", requested)
+				return
+			}
+			limit = min(parsed, maxHistoryLimit)
+		}
+
+		commits, err := mgr.History(repo, ref, path, limit, clientID)
+		if err != nil {
+			writeRepositoryError(w, r, err, repo, ref, path)
+			return
+		}
+
+		jsn, _ := json.Marshal(&FileHistory{
+			Repository: repo,
+			Ref:        ref,
+			Path:       strings.Trim(path, "/"),
+			Commits:    commits,
+		})
+		Ok(w, jsn, "application/json;charset=utf-8")
+	}
+}
+
+// diffSide reads a file at the provided ref, a missing file is considered empty so that creations and deletions can
+// be diffed. The revision the file was read from, or the ref if the file does not exist, is returned along its content.
+// Diffs are not counted as hits as the file content is not served
+func diffSide(ctx context.Context, mgr *repository.Manager, repo string, ref string, path string, clientID string) (string, []byte, bool, error) {
+	file, err := mgr.Read(ctx, repo, ref, path, clientID)
+	if errors.Is(err, repository.ErrFileNotFound) {
+		return ref, nil, false, nil
+	}
+	if err != nil {
+		return "", nil, false, err
+	}
+	return file.Revision, file.Content, true, nil
+}
+
+// maskValue hides the substitution tokens of string values
+func maskValue(value any) any {
+	if text, ok := value.(string); ok {
+		return utils.MaskTokens(text)
+	}
+	return value
+}
+
+// handleFileDiff returns the differences of a file between the commits designated by the from and to query
+// parameters, to defaulting to the tracked branch. Differences are rendered as a unified diff unless the key level
+// differences are requested using mode=keys.
+// Sensitive values are never decrypted, substitution tokens are masked so that only their modification is disclosed
+func handleFileDiff(mgr *repository.Manager) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		clientID := r.Context().Value(ctxClientID{}).(string)
+		repo := r.PathValue("repository")
+		path := r.PathValue("path")
+		query := r.URL.Query()
+
+		from, to := query.Get("from"), query.Get("to")
+		if len(from) == 0 {
+			HTTPBadRequest(w, r, "the from query parameter is mandatory")
+			return
+		}
+		mode := query.Get("mode")
+		if len(mode) > 0 && mode != "unified" && mode != "keys" {
+			HTTPBadRequest(w, r, "'%s' is not a supported diff mode, use either unified or keys", mode)
+			return
+		}
+		format, known := document.FormatOf(path)
+		if mode == "keys" && !known {
+			HTTPBadRequest(w, r, "'%s' cannot be diffed by key, only yaml, json, properties, dotenv and toml documents are supported", path)
+			return
+		}
+
+		fromRevision, before, fromExists, err := diffSide(r.Context(), mgr, repo, from, path, clientID)
+		if err != nil {
+			writeRepositoryError(w, r, err, repo, from, path)
+			return
+		}
+		toRevision, after, toExists, err := diffSide(r.Context(), mgr, repo, to, path, clientID)
+		if err != nil {
+			writeRepositoryError(w, r, err, repo, to, path)
+			return
+		}
+		if !fromExists && !toExists {
+			HTTPNotFound(w, r, "'%s' was not found in repository '%s'", path, repo)
+			return
+		}
+
+		if mode != "keys" {
+			name := strings.Trim(path, "/")
+			diff, err := document.UnifiedDiff(fmt.Sprintf("a/%s@%s", name, fromRevision), fmt.Sprintf("b/%s@%s", name, toRevision), string(before), string(after), utils.MaskTokens)
+			if err != nil {
+				HTTPUnprocessableEntity(w, r, "'%s' cannot be diffed : %s", path, err)
+				return
+			}
+			Ok(w, []byte(diff), "text/x-diff;charset=utf-8")
+			return
+		}
+
+		trees := []map[string]any{{}, {}}
+		for i, content := range [][]byte{before, after} {
+			if len(content) == 0 {
+				continue
+			}
+			if trees[i], err = document.Parse(content, format); err != nil {
+				HTTPInternalServerError(w, r, "'%s' cannot be parsed : %s", path, err)
+				return
+			}
+		}
+
+		changes := document.DiffKeys(trees[0], trees[1])
+		for i := range changes {
+			changes[i].From, changes[i].To = maskValue(changes[i].From), maskValue(changes[i].To)
+		}
+
+		jsn, _ := json.Marshal(&FileDiff{
+			Repository: repo,
+			Path:       strings.Trim(path, "/"),
+			From:       fromRevision,
+			To:         toRevision,
+			Changes:    changes,
+		})
+		Ok(w, jsn, "application/json;charset=utf-8")
+	}
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fredjeck/configserver/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskValue(t *testing.T) {
+	assert.Equal(t, "jdbc:postgres://admin:{enc:***}@localhost", maskValue("jdbc:postgres://admin:"+utils.CreateToken("secret", "passphrase")+"@localhost"))
+	assert.Equal(t, 8080, maskValue(8080))
+}
+
+func TestFileDiffParameters(t *testing.T) {
+	for url, expected := range map[string]int{
+		"/diff/repo/app.yml":                           http.StatusBadRequest,
+		"/diff/repo/app.yml?from=v1&mode=side-by-side": http.StatusBadRequest,
+		"/diff/repo/README.md?from=v1&mode=keys":       http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req = req.WithContext(context.WithValue(req.Context(), ctxClientID{}, "client"))
+		req.SetPathValue("repository", "repo")
+		req.SetPathValue("path", req.URL.Path[len("/diff/repo/"):])
+		w := httptest.NewRecorder()
+		handleFileDiff(nil)(w, req)
+		assert.Equal(t, expected, w.Code, url)
+	}
+}
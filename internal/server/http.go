@@ -10,6 +10,9 @@ import (
 type ctxRequestID struct{}
 type ctxClientID struct{}
 
+// ctxShutdown holds a <-chan struct{} closed when the server starts shutting down
+type ctxShutdown struct{}
+
 // ProblemDetail is a RFC9457 compliant error detail used by the server to return errors.
 type ProblemDetail struct {
 	ProblemType string `json:"type"`
@@ -29,6 +32,16 @@ func HTTPUnauthorized(w http.ResponseWriter, r *http.Request, detail string, par
 	writeStatus(w, r, http.StatusUnauthorized, "Forbidden", detail, params...)
 }
 
+// HTTPForbidden returns an HTTP 403 error along a RFC9457 compliant error detail
+func HTTPForbidden(w http.ResponseWriter, r *http.Request, detail string, params ...interface{}) {
+	writeStatus(w, r, http.StatusForbidden, "Forbidden", detail, params...)
+}
+
+// HTTPConflict returns an HTTP 409 error along a RFC9457 compliant error detail
+func HTTPConflict(w http.ResponseWriter, r *http.Request, detail string, params ...interface{}) {
+	writeStatus(w, r, http.StatusConflict, "Conflict", detail, params...)
+}
+
 // HTTPUnsupportedMediaType returns an HTTP 415 error along a RFC9457 compliant error detail
 func HTTPUnsupportedMediaType(w http.ResponseWriter, r *http.Request, detail string, params ...interface{}) {
 	writeStatus(w, r, http.StatusUnsupportedMediaType, "Unsupported content type", detail, params...)
@@ -36,14 +49,39 @@ func HTTPUnsupportedMediaType(w http.ResponseWriter, r *http.Request, detail str
 
 // HTTPNotFound returns an HTTP 404 error along a RFC9457 compliant error detail
 func HTTPNotFound(w http.ResponseWriter, r *http.Request, detail string, params ...interface{}) {
-	writeStatus(w, r, http.StatusUnsupportedMediaType, "Not found", detail, params...)
+	writeStatus(w, r, http.StatusNotFound, "Not found", detail, params...)
+}
+
+// HTTPServiceUnavailable returns an HTTP 503 error along a RFC9457 compliant error detail
+func HTTPServiceUnavailable(w http.ResponseWriter, r *http.Request, detail string, params ...interface{}) {
+	writeStatus(w, r, http.StatusServiceUnavailable, "Service unavailable", detail, params...)
+}
+
+// HTTPNotAcceptable returns an HTTP 406 error along a RFC9457 compliant error detail
+func HTTPNotAcceptable(w http.ResponseWriter, r *http.Request, detail string, params ...interface{}) {
+	writeStatus(w, r, http.StatusNotAcceptable, "Not acceptable", detail, params...)
+}
+
+// HTTPBadRequest returns an HTTP 400 error along a RFC9457 compliant error detail
+func HTTPBadRequest(w http.ResponseWriter, r *http.Request, detail string, params ...interface{}) {
+	writeStatus(w, r, http.StatusBadRequest, "Bad request", detail, params...)
+}
+
+// HTTPUnprocessableEntity returns an HTTP 422 error along a RFC9457 compliant error detail
+func HTTPUnprocessableEntity(w http.ResponseWriter, r *http.Request, detail string, params ...interface{}) {
+	writeStatus(w, r, http.StatusUnprocessableEntity, "Unprocessable entity", detail, params...)
+}
+
+// HTTPNotImplemented returns an HTTP 501 error along a RFC9457 compliant error detail
+func HTTPNotImplemented(w http.ResponseWriter, r *http.Request, detail string, params ...interface{}) {
+	writeStatus(w, r, http.StatusNotImplemented, "Not implemented", detail, params...)
 }
 
 func writeStatus(w http.ResponseWriter, r *http.Request, code int, title string, detail string, params ...interface{}) {
 
 	strDetail := fmt.Sprintf(detail, params...)
 	if code > 300 {
-		requestID := r.Context().Value(ctxRequestID{}).(string)
+		requestID, _ := r.Context().Value(ctxRequestID{}).(string)
 		slog.Warn(strDetail, HTTPRequestStatus, code, HTTPRequestID, requestID)
 	}
 	problem := &ProblemDetail{
@@ -65,3 +103,11 @@ func Ok(w http.ResponseWriter, content []byte, mimetype string) {
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write(content[0:len(content):len(content)])
 }
+
+// Created returns an HTTP 201 response along the provided content and the location of the created resource
+func Created(w http.ResponseWriter, location string, content []byte, mimetype string) {
+	w.Header().Set("Location", location)
+	w.Header().Add("Content-Type", mimetype)
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write(content[0:len(content):len(content)])
+}
@@ -8,6 +8,7 @@ import (
 	"runtime/debug"
 	"time"
 
+	"github.com/fredjeck/configserver/internal/tracing"
 	"github.com/google/uuid"
 )
 
@@ -28,6 +29,9 @@ const HTTPRequestPath = "http.request.path"
 // HTTPRequestDuration represents the logging key for the http request Duration
 const HTTPRequestDuration = "http.request.duration"
 
+// TraceID represents the logging key for the id of the trace the request belongs to
+const TraceID = "trace.id"
+
 // responseWriter is a minimal wrapper for http.ResponseWriter that allows the
 // written HTTP status code to be captured for logging.
 type responseWriter struct {
@@ -44,6 +48,11 @@ func (rw *responseWriter) Status() int {
 	return rw.status
 }
 
+// Unwrap gives access to the wrapped writer so that http.ResponseController can flush streamed responses
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 func (rw *responseWriter) WriteHeader(code int) {
 	if rw.wroteHeader {
 		return
@@ -75,6 +84,9 @@ func requestLogger() func(http.Handler) http.Handler {
 
 			r = r.WithContext(ctx)
 
+			span := tracing.FromContext(ctx)
+			span.SetAttributes(tracing.String(HTTPRequestID, id.String()))
+
 			wrapped := wrapResponseWriter(w)
 			next.ServeHTTP(wrapped, r)
 			elapsed := time.Since(start)
@@ -85,6 +97,7 @@ func requestLogger() func(http.Handler) http.Handler {
 				slog.String(HTTPRequestPath, r.URL.EscapedPath()),
 				slog.Duration(HTTPRequestDuration, elapsed),
 				slog.String(HTTPRequestID, id.String()),
+				slog.String(TraceID, span.Context().TraceID.String()),
 			)
 		}
 		return http.HandlerFunc(fn)
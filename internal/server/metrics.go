@@ -0,0 +1,106 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/metrics"
+	"github.com/fredjeck/configserver/internal/repository"
+)
+
+var httpRequests = metrics.NewCounterVec("configserver_http_requests_total", "Number of HTTP requests served", "route", "status", "repository")
+
+var httpRequestDuration = metrics.NewHistogramVec("configserver_http_request_duration_seconds", "Duration of the HTTP requests", metrics.DefaultDurationBuckets, "route", "status", "repository")
+
+var detokenizationFailures = metrics.NewCounterVec("configserver_detokenization_failures_total", "Number of files and values whose tokens could not be decrypted")
+
+var secretValidationFailures = metrics.NewCounterVec("configserver_client_secret_validation_failures_total", "Number of rejected client authentications by reason", "reason")
+
+func init() {
+	metrics.Default.Register(httpRequests, httpRequestDuration, detokenizationFailures, secretValidationFailures)
+}
+
+// repositoryStates are the states reported by the repository state gauge
+var repositoryStates = []repository.State{repository.StatePending, repository.StateHealthy, repository.StateDegraded, repository.StateUnavailable}
+
+// handleMetrics exposes the application metrics along the state of the repositories in the Prometheus text format
+func handleMetrics(mgr *repository.Manager) func(w http.ResponseWriter, r *http.Request) {
+	registry := metrics.NewRegistry()
+	registry.Register(
+		metrics.NewGaugeFunc("configserver_repository_state", "Current state of each repository, 1 for the active state", []string{"repository", "state"}, func(emit func(float64, ...string)) {
+			for name, statistics := range mgr.Statistics() {
+				for _, state := range repositoryStates {
+					value := 0.0
+					if statistics.State == state {
+						value = 1
+					}
+					emit(value, name, string(state))
+				}
+			}
+		}),
+		metrics.NewGaugeFunc("configserver_repository_consecutive_failures", "Number of consecutive failed updates of each repository", []string{"repository"}, func(emit func(float64, ...string)) {
+			for name, statistics := range mgr.Statistics() {
+				emit(float64(statistics.ConsecutiveFailures), name)
+			}
+		}),
+	)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", metrics.ContentType)
+		w.WriteHeader(http.StatusOK)
+		metrics.Default.Write(w)
+		registry.Write(w)
+	}
+}
+
+// requestMetrics counts the HTTP requests and measures their duration by route, status and repository.
+// Repositories are only reported when they are known so that requests cannot inflate the number of series.
+// The responses statuses are also recorded in the repositories statistics
+func requestMetrics(mux *http.ServeMux, mgr *repository.Manager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			wrapped := wrapResponseWriter(w)
+			next.ServeHTTP(wrapped, r)
+
+			status := wrapped.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			_, route := mux.Handler(r)
+			if len(route) == 0 {
+				route = "unmatched"
+			}
+			repo := routeRepository(route, r.URL.Path)
+			if _, known := mgr.Definition(repo); known {
+				mgr.RecordStatus(repo, status)
+			} else {
+				repo = ""
+			}
+
+			labels := []string{route, strconv.Itoa(status), repo}
+			httpRequests.Inc(labels...)
+			httpRequestDuration.ObserveDuration(start, labels...)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// routeRepository extracts the repository designated by the {repository} or {name} wildcard of a route pattern from
+// the request path, references suffixed by @ are removed
+func routeRepository(route string, path string) string {
+	_, pattern, found := strings.Cut(route, " ")
+	if !found {
+		pattern = route
+	}
+	segments, requested := strings.Split(pattern, "/"), strings.Split(path, "/")
+	for i, segment := range segments {
+		if (segment == "{repository}" || segment == "{name}") && i < len(requested) {
+			name, _, _ := strings.Cut(requested[i], "@")
+			return name
+		}
+	}
+	return ""
+}
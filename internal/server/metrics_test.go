@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteRepository(t *testing.T) {
+	assert.Equal(t, "samples", routeRepository("GET /git/{repository}/{path...}", "/git/samples/app.yml"))
+	assert.Equal(t, "samples", routeRepository("GET /git/{repository}/{path...}", "/git/samples@v1.0/app.yml"))
+	assert.Equal(t, "samples", routeRepository("POST /api/repositories/{name}/refresh", "/api/repositories/samples/refresh"))
+	assert.Equal(t, "", routeRepository("GET /stats", "/stats"))
+	assert.Equal(t, "", routeRepository("GET /", "/application/default"))
+}
+
+func TestSecretValidationFailures(t *testing.T) {
+	before := secretValidationFailures.Value(secretClientMismatch)
+	secret, _ := generateClientSecret(clientID, 360, passPhrase)
+	assert.False(t, validateClientSecret("wrong-client", secret, passPhrase, false))
+	assert.Equal(t, before+1, secretValidationFailures.Value(secretClientMismatch))
+
+	before = secretValidationFailures.Value(secretExpired)
+	secret, _ = generateClientSecret(clientID, -2, passPhrase)
+	assert.False(t, validateClientSecret(clientID, secret, passPhrase, true))
+	assert.Equal(t, before+1, secretValidationFailures.Value(secretExpired))
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	repo := &configuration.Repository{Name: "samples", Backend: configuration.BackendLocal, Path: t.TempDir()}
+	mgr, err := repository.NewManager(&configuration.Repositories{Configuration: []*configuration.Repository{repo}})
+	assert.NoError(t, err)
+
+	mux := http.NewServeMux()
+	requireAuth := authenticatedOnly(configuration.NewRuntime(AuthTestConfiguration))
+	mux.Handle("GET /git/{repository}/{path...}", requireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) })))
+	mux.HandleFunc("GET /healthz", handleLiveness())
+	mux.HandleFunc("GET /metrics", handleMetrics(mgr))
+	handler := requestMetrics(mux, mgr)(mux)
+
+	secret, _ := generateClientSecret("metrics-client", 360, AuthTestConfiguration.Server.PassPhrase)
+	for _, target := range []string{"/git/samples/app.yml", "/git/unknown/app.yml"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.SetBasicAuth("metrics-client", secret)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	assert.Equal(t, float64(1), httpRequests.Value("GET /git/{repository}/{path...}", "404", "samples"))
+	assert.Equal(t, float64(1), httpRequests.Value("GET /git/{repository}/{path...}", "404", ""))
+	assert.Equal(t, int64(1), mgr.Statistics()["samples"].NotFound)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	assert.Contains(t, body, `configserver_http_request_duration_seconds_count{route="GET /git/{repository}/{path...}",status="404",repository="samples"} 1`)
+	assert.Contains(t, body, `configserver_repository_state{repository="samples",state="pending"} 1`)
+	assert.Contains(t, body, `configserver_repository_state{repository="samples",state="healthy"} 0`)
+	assert.Contains(t, body, "# TYPE configserver_client_secret_validation_failures_total counter")
+	assert.NotContains(t, body, "metrics-client", "client ids must not be exposed")
+}
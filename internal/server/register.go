@@ -2,7 +2,9 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/fredjeck/configserver/internal/configuration"
@@ -16,14 +18,29 @@ type RegisterClientResponse struct {
 	ExpiresAt    time.Time `json:"expires_at"`
 }
 
-// handleClientRegistration responds to client registration requests
-func handleClientRegistration(c *configuration.Configuration) func(w http.ResponseWriter, r *http.Request) {
+// RegisterAdminClient generates the secret of a client listed in adminClients, administrator secrets cannot be
+// obtained from /api/register
+func RegisterAdminClient(c *configuration.Configuration, clientID string) (*RegisterClientResponse, error) {
+	if !slices.Contains(c.Server.AdminClients, clientID) {
+		return nil, fmt.Errorf("'%s' is not listed in adminClients", clientID)
+	}
+	clientSecret, expires := generateClientSecret(clientID, c.Server.SecretExpiryDays, adminPassPhrase(c.Server.PassPhrase))
+	return &RegisterClientResponse{clientID, clientSecret, expires}, nil
+}
+
+// handleClientRegistration responds to client registration requests, administrators cannot register
+func handleClientRegistration(runtime *configuration.Runtime) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		c := runtime.Current()
 		clientID := r.URL.Query().Get("client_id")
 		if len(clientID) == 0 {
 			uid, _ := uuid.NewV7()
 			clientID = uid.String()
 		}
+		if slices.Contains(c.Server.AdminClients, clientID) {
+			HTTPForbidden(w, r, "'%s' is an administrator, its secret cannot be obtained by registration", clientID)
+			return
+		}
 
 		clientSecret, expires := generateClientSecret(clientID, c.Server.SecretExpiryDays, c.Server.PassPhrase)
 
@@ -20,7 +20,7 @@ const registerURL = "/api/register?client_id=" + registerClientID
 func TestRegisterClientId(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, registerURL, nil)
 	w := httptest.NewRecorder()
-	f := handleClientRegistration(RefactorTestConfiguration)
+	f := handleClientRegistration(configuration.NewRuntime(RefactorTestConfiguration))
 	f(w, req)
 	assert.Equal(t, 200, w.Code)
 }
@@ -28,7 +28,7 @@ func TestRegisterClientId(t *testing.T) {
 func TestRegisterPayload(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, registerURL, nil)
 	w := httptest.NewRecorder()
-	f := handleClientRegistration(RefactorTestConfiguration)
+	f := handleClientRegistration(configuration.NewRuntime(RefactorTestConfiguration))
 	f(w, req)
 
 	res := w.Result()
@@ -44,7 +44,7 @@ func TestRegisterPayload(t *testing.T) {
 func TestGenerateClientId(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/api/register", nil)
 	w := httptest.NewRecorder()
-	f := handleClientRegistration(RefactorTestConfiguration)
+	f := handleClientRegistration(configuration.NewRuntime(RefactorTestConfiguration))
 	f(w, req)
 
 	res := w.Result()
@@ -60,7 +60,7 @@ func TestGenerateClientId(t *testing.T) {
 func TestRegistrationExpiry(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, registerURL, nil)
 	w := httptest.NewRecorder()
-	f := handleClientRegistration(RefactorTestConfiguration)
+	f := handleClientRegistration(configuration.NewRuntime(RefactorTestConfiguration))
 	f(w, req)
 
 	res := w.Result()
@@ -72,5 +72,5 @@ func TestRegistrationExpiry(t *testing.T) {
 	shouldExpire := time.Now().Add(time.Hour * 24 * time.Duration(RefactorTestConfiguration.Server.SecretExpiryDays))
 
 	assert.True(t, time.Now().Before(m.ExpiresAt))
-	assert.Equal(t, shouldExpire.Truncate(24*time.Hour), m.ExpiresAt.Truncate(24*time.Hour))
+	assert.Equal(t, shouldExpire.Truncate(24*time.Hour).UTC(), m.ExpiresAt.Truncate(24*time.Hour).UTC())
 }
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/repository"
+)
+
+// configurationPollInterval is the interval at which the configuration file is checked for changes
+const configurationPollInterval = 5 * time.Second
+
+// watchConfiguration reloads the configuration whenever its file content changes or a SIGHUP signal is received,
+// until the context is cancelled
+func watchConfiguration(ctx context.Context, runtime *configuration.Runtime, mgr *repository.Manager) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	source := runtime.Current().Source
+	fingerprint, _ := fileFingerprint(source)
+	ticker := time.NewTicker(configurationPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			slog.Info("SIGHUP received, reloading configuration", "configuration.source", source)
+		case <-ticker.C:
+			current, err := fileFingerprint(source)
+			if err != nil || current == fingerprint {
+				continue
+			}
+			slog.Info("configuration file changed, reloading configuration", "configuration.source", source)
+		}
+
+		fingerprint, _ = fileFingerprint(source)
+		if err := reloadConfiguration(runtime, mgr); err != nil {
+			slog.Error("configuration cannot be reloaded, the current configuration is kept", "error", err, "configuration.source", source)
+		}
+	}
+}
+
+// fileFingerprint returns the hash of the provided file's content
+func fileFingerprint(path string) ([32]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256(content), nil
+}
+
+// reloadConfiguration loads the configuration file again and applies it : repositories are added, removed or updated
+// and the server settings are swapped. Invalid configurations are rejected and the current one is kept
+func reloadConfiguration(runtime *configuration.Runtime, mgr *repository.Manager) error {
+	current := runtime.Current()
+	updated, err := configuration.LoadFrom(current.Source)
+	if err != nil {
+		return err
+	}
+
+	summary, err := mgr.Reload(updated.Repositories)
+	if err != nil {
+		return fmt.Errorf("repositories cannot be reloaded : %w", err)
+	}
+	runtime.Swap(updated)
+
+	if updated.ListenOn != current.ListenOn {
+		slog.Warn(fmt.Sprintf("listenOn changed to '%s', the server keeps listening on '%s' until it is restarted", updated.ListenOn, current.ListenOn))
+	}
+	slog.Info("configuration reloaded", "repositories.added", summary.Added, "repositories.removed", summary.Removed, "repositories.updated", summary.Updated, "repositories.restarted", summary.Restarted)
+	return nil
+}
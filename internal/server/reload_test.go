@@ -0,0 +1,61 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+const reloadTestConfiguration = `
+server:
+  passPhrase: %s
+  listenOn: 127.0.0.1:4200
+repositories:
+  checkoutLocation: %s
+  configuration:
+    - name: %s
+      backend: %s
+      path: %s
+`
+
+func writeReloadTestConfiguration(t *testing.T, path string, passPhrase string, repo string, backend string) {
+	content := fmt.Sprintf(reloadTestConfiguration, passPhrase, t.TempDir(), repo, backend, t.TempDir())
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestReloadConfiguration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "configserver.yml")
+	writeReloadTestConfiguration(t, path, "before", "initial", configuration.BackendLocal)
+	c, err := configuration.LoadFrom(path)
+	assert.NoError(t, err)
+	mgr, err := repository.NewManager(c.Repositories)
+	assert.NoError(t, err)
+	runtime := configuration.NewRuntime(c)
+
+	writeReloadTestConfiguration(t, path, "after", "replacement", configuration.BackendLocal)
+	assert.NoError(t, reloadConfiguration(runtime, mgr))
+	assert.Equal(t, "after", runtime.Current().Server.PassPhrase)
+	assert.Contains(t, mgr.Repositories, "replacement")
+	assert.NotContains(t, mgr.Repositories, "initial")
+}
+
+func TestReloadConfigurationKeepsCurrentWhenInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "configserver.yml")
+	writeReloadTestConfiguration(t, path, "before", "initial", configuration.BackendLocal)
+	c, err := configuration.LoadFrom(path)
+	assert.NoError(t, err)
+	mgr, err := repository.NewManager(c.Repositories)
+	assert.NoError(t, err)
+	runtime := configuration.NewRuntime(c)
+
+	writeReloadTestConfiguration(t, path, "after", "replacement", "svn")
+	assert.Error(t, reloadConfiguration(runtime, mgr))
+	assert.Same(t, c, runtime.Current())
+	assert.Contains(t, mgr.Repositories, "initial")
+	assert.NotContains(t, mgr.Repositories, "replacement")
+}
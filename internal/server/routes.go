@@ -7,10 +7,27 @@ import (
 	"github.com/fredjeck/configserver/internal/repository"
 )
 
-func addRoutes(mux *http.ServeMux, c *configuration.Configuration, m *repository.Manager) {
+func addRoutes(mux *http.ServeMux, c *configuration.Runtime, m *repository.Manager) {
 	mux.HandleFunc("GET /api/register", handleClientRegistration(c))
 	mux.HandleFunc("POST /api/tokenize", handleFileTokenization(c))
 	mux.HandleFunc("GET /stats", handleStatistics(m))
+	mux.HandleFunc("GET /metrics", handleMetrics(m))
+	mux.HandleFunc("GET /healthz", handleLiveness())
+	mux.HandleFunc("GET /readyz", handleReadiness(m))
+	mux.HandleFunc("POST /hooks/{provider}", handleWebhook(m))
 	requireAuth := authenticatedOnly(c)
 	mux.Handle("GET /git/{repository}/{path...}", requireAuth(http.HandlerFunc(handleGitRepositoryAccess(m, c))))
+	mux.Handle("GET /history/{repository}/{path...}", requireAuth(http.HandlerFunc(handleFileHistory(m))))
+	mux.Handle("GET /diff/{repository}/{path...}", requireAuth(http.HandlerFunc(handleFileDiff(m))))
+	mux.Handle("GET /watch/{repository}/{path...}", requireAuth(http.HandlerFunc(handleWatch(m, c))))
+	requireAdmin := func(handler http.HandlerFunc) http.Handler { return adminAuthenticatedOnly(c)(adminOnly(c)(handler)) }
+	mux.Handle("GET /api/repositories", requireAdmin(handleRepositoryList(m)))
+	mux.Handle("POST /api/repositories", requireAdmin(handleRepositoryCreate(m)))
+	mux.Handle("GET /api/repositories/{name}", requireAdmin(handleRepositoryGet(m)))
+	mux.Handle("PUT /api/repositories/{name}", requireAdmin(handleRepositoryUpdate(m)))
+	mux.Handle("DELETE /api/repositories/{name}", requireAdmin(handleRepositoryDelete(m)))
+	mux.Handle("POST /api/repositories/{name}/refresh", requireAdmin(handleRepositoryRefresh(m)))
+	mux.Handle("GET /api/clients/{id}/activity", requireAdmin(handleClientActivity(m)))
+	// Spring Cloud Config endpoints use the root path, any route not matched above is considered as a Spring request
+	mux.Handle("GET /", springRequestsOnly(requireAuth(http.HandlerFunc(handleSpringCloudConfig(m, c)))))
 }
@@ -2,15 +2,27 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/fredjeck/configserver/internal/configuration"
 	"github.com/fredjeck/configserver/internal/repository"
+	"github.com/fredjeck/configserver/internal/tracing"
 )
 
+// statisticsFileName is the name of the file, stored in the home directory, persisting the repositories statistics and
+// the clients activity across restarts
+const statisticsFileName = "statistics.json"
+
 // ConfigServer is a standalone server which aims to securely serve git repositories via http
 type ConfigServer struct {
 	Configuration *configuration.Configuration
@@ -21,23 +33,88 @@ func NewConfigServer(c *configuration.Configuration) *ConfigServer {
 	return &ConfigServer{c}
 }
 
-// Start is where all the magic happens
-func (c *ConfigServer) Start() {
+// Start is where all the magic happens.
+// The server runs until a SIGTERM or an interrupt signal is received : in-flight requests are then given
+// shutdownTimeoutSeconds to complete before the repositories are stopped
+func (c *ConfigServer) Start() error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	provider, release, err := newTracingProvider(c.Configuration.Tracing)
+	if err != nil {
+		return fmt.Errorf("error starting the tracing : %w", err)
+	}
+	if provider != nil {
+		tracing.SetProvider(provider)
+		defer func() {
+			if err := provider.Shutdown(context.Background()); err != nil {
+				slog.Error("traces cannot be exported", "error", err)
+			}
+			release()
+		}()
+	}
 
-	manager, mgrErr := repository.NewManager(c.Configuration.Repositories)
-	if mgrErr != nil {
-		slog.Error("error starting the repository manager, aborting:", "error", mgrErr)
-		os.Exit(1)
+	manager, err := repository.NewManager(c.Configuration.Repositories)
+	if err != nil {
+		return fmt.Errorf("error starting the repository manager : %w", err)
 	}
-	manager.Start()
+	statisticsFile := filepath.Join(c.Configuration.Environment.Home, statisticsFileName)
+	if err := manager.PersistStatistics(statisticsFile); err != nil {
+		slog.Error("previous statistics cannot be restored", "error", err)
+	}
+	// The repositories keep being served while the in-flight requests are drained
+	managerCtx, stopManager := context.WithCancel(context.Background())
+	defer stopManager()
+	manager.Start(managerCtx)
+
+	runtime := configuration.NewRuntime(c.Configuration)
+	go watchConfiguration(ctx, runtime, manager)
 
 	mux := http.NewServeMux()
-	addRoutes(mux, c.Configuration, manager)
+	addRoutes(mux, runtime, manager)
 	logger := requestLogger()
-	slog.Info(fmt.Sprintf("ConfigServer started and listening on %s", c.Configuration.ListenOn))
-	err := http.ListenAndServe(c.Configuration.ListenOn, logger(mux))
-	if err != nil {
-		slog.Error("error starting configserver:", "error", err)
-		os.Exit(1)
+	measure := requestMetrics(mux, manager)
+	trace := requestTracing(mux)
+	stopping := make(chan struct{})
+	srv := &http.Server{
+		Addr:    c.Configuration.ListenOn,
+		Handler: trace(logger(measure(mux))),
+		// Long-polling and event streams end as soon as the shutdown starts, other requests are drained
+		BaseContext: func(net.Listener) context.Context {
+			return context.WithValue(context.Background(), ctxShutdown{}, (<-chan struct{})(stopping))
+		},
+	}
+	srv.RegisterOnShutdown(func() { close(stopping) })
+
+	failed := make(chan error, 1)
+	go func() {
+		slog.Info(fmt.Sprintf("ConfigServer started and listening on %s", c.Configuration.ListenOn))
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			failed <- err
+		}
+	}()
+
+	select {
+	case err := <-failed:
+		stop()
+		stopManager()
+		_ = manager.Shutdown(context.Background())
+		return fmt.Errorf("error starting configserver : %w", err)
+	case <-ctx.Done():
+	}
+
+	timeout := time.Duration(runtime.Current().Server.ShutdownTimeoutSeconds) * time.Second
+	slog.Info(fmt.Sprintf("shutting down, in-flight requests have %s to complete", timeout))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("in-flight requests did not complete in time", "error", err)
+	}
+	stopManager()
+	if err := manager.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("repositories cannot be stopped : %w", err)
 	}
+	slog.Info("ConfigServer stopped")
+	return nil
 }
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/document"
+	"github.com/fredjeck/configserver/internal/repository"
+)
+
+// springDefaultLabel is the label used by Spring Cloud Config clients which do not provide one
+const springDefaultLabel = "main"
+
+// springDefaultApplication is the name of the configuration files shared by all the applications
+const springDefaultApplication = "application"
+
+// springSourceExtensions lists the files extensions searched for property sources by decreasing order of precedence
+var springSourceExtensions = []string{".properties", ".yml", ".yaml", ".json"}
+
+// SpringEnvironment is the Spring Cloud Config environment document returned by the /{application}/{profile}[/{label}] endpoint
+type SpringEnvironment struct {
+	Name            string                 `json:"name"`
+	Profiles        []string               `json:"profiles"`
+	Label           string                 `json:"label"`
+	Version         string                 `json:"version"`
+	State           *string                `json:"state"`
+	PropertySources []SpringPropertySource `json:"propertySources"`
+}
+
+// SpringPropertySource is a single configuration file flattened to dotted keys
+type SpringPropertySource struct {
+	Name   string         `json:"name"`
+	Source map[string]any `json:"source"`
+}
+
+// springRequest is the decoded form of a Spring Cloud Config request
+type springRequest struct {
+	application string
+	profiles    []string
+	label       string
+	format      document.Format // requested document format, empty when the environment document is requested
+}
+
+// parseSpringRequest decodes the Spring Cloud Config url patterns :
+//   - /{application}/{profile}[/{label}]
+//   - /[{label}/]{application}-{profile}.{yml|yaml|properties|json}
+func parseSpringRequest(urlPath string) (*springRequest, bool) {
+	segments := strings.Split(strings.Trim(urlPath, "/"), "/")
+	for _, segment := range segments {
+		if len(segment) == 0 {
+			return nil, false
+		}
+	}
+
+	request := &springRequest{label: springDefaultLabel}
+	ok := true
+	switch {
+	case len(segments) == 1:
+		ok = request.parseDocument(segments[0])
+	case len(segments) == 2:
+		if _, isDocument := document.FormatOf(segments[1]); isDocument {
+			request.label = segments[0]
+			ok = request.parseDocument(segments[1])
+		} else {
+			request.application, request.profiles = segments[0], strings.Split(segments[1], ",")
+		}
+	case len(segments) == 3:
+		request.application, request.profiles, request.label = segments[0], strings.Split(segments[1], ","), segments[2]
+	default:
+		ok = false
+	}
+
+	// Spring uses (_) to designate slashes in labels
+	request.label = strings.ReplaceAll(request.label, "(_)", "/")
+	return request, ok
+}
+
+// parseDocument decodes the {application}-{profile}.{extension} document name
+func (request *springRequest) parseDocument(name string) bool {
+	format, ok := document.FormatOf(name)
+	if !ok {
+		return false
+	}
+
+	base := name[:strings.LastIndex(name, ".")]
+	separator := strings.LastIndex(base, "-")
+	if separator <= 0 || separator == len(base)-1 {
+		return false
+	}
+
+	request.application, request.profiles, request.format = base[:separator], strings.Split(base[separator+1:], ","), format
+	return true
+}
+
+// springPropertySources collects the property sources matching the requested application and profiles, ordered by
+// decreasing precedence : the last profile wins over the first ones and application specific files win over the
+// shared application files. All the sources are read at the provided revision
+func springPropertySources(ctx context.Context, mgr *repository.Manager, repo *repository.Repository, revision string, request *springRequest, clientID string, passPhrase string) ([]SpringPropertySource, error) {
+	names := []string{request.application}
+	if request.application != springDefaultApplication {
+		names = append(names, springDefaultApplication)
+	}
+
+	var candidates []string
+	profiles := slices.Clone(request.profiles)
+	slices.Reverse(profiles)
+	for _, profile := range profiles {
+		for _, name := range names {
+			candidates = append(candidates, name+"-"+profile)
+		}
+	}
+	candidates = append(candidates, names...)
+
+	location := strings.TrimSuffix(springSourceLocation(repo.Configuration.URL), "/")
+	sources := []SpringPropertySource{}
+	for _, candidate := range candidates {
+		for _, extension := range springSourceExtensions {
+			file := candidate + extension
+			found, err := mgr.Get(ctx, repo.Configuration.Name, revision, file, clientID)
+			if errors.Is(err, repository.ErrFileNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			// Tokens are replaced once the document is parsed so that secrets cannot alter its structure
+			format, _ := document.FormatOf(file)
+			tree, err := document.Parse(found.Content, format)
+			if err != nil {
+				return nil, fmt.Errorf("'%s' cannot be parsed : %w", file, err)
+			}
+			if err := detokenizeTree(ctx, tree, passPhrase); err != nil {
+				return nil, fmt.Errorf("'%s' cannot be detokenized : %w", file, err)
+			}
+
+			sources = append(sources, SpringPropertySource{
+				Name:   location + "/" + file,
+				Source: document.Flatten(tree),
+			})
+		}
+	}
+	return sources, nil
+}
+
+// springSourceLocation returns the repository url the property sources are named after, stripped from the credentials
+// it may embed
+func springSourceLocation(remote string) string {
+	u, err := url.Parse(remote)
+	if err != nil || u.User == nil {
+		return remote
+	}
+	u.User = nil
+	return u.String()
+}
+
+// springRequestsOnly is a middleware which responds with a not found error to the requests which do not follow the
+// Spring Cloud Config url patterns so that unknown paths are reported as such before requiring an authentication
+func springRequestsOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := parseSpringRequest(r.URL.Path); !ok {
+			HTTPNotFound(w, r, "'%s' was not found on this server", r.URL.Path)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// mergeSpringPropertySources merges the property sources into a single document tree honoring their precedence
+func mergeSpringPropertySources(sources []SpringPropertySource) map[string]any {
+	merged := make(map[string]any)
+	for i := len(sources) - 1; i >= 0; i-- {
+		for key, value := range sources[i].Source {
+			merged[key] = value
+		}
+	}
+	return document.Expand(merged)
+}
+
+// handleSpringCloudConfig implements the Spring Cloud Config server REST contract so that Spring Boot clients can use
+// configserver unchanged. Spring labels designate repositories either by name or by tracked branch
+func handleSpringCloudConfig(mgr *repository.Manager, runtime *configuration.Runtime) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := runtime.Current()
+		clientID := r.Context().Value(ctxClientID{}).(string)
+		request, ok := parseSpringRequest(r.URL.Path)
+		if !ok {
+			HTTPNotFound(w, r, "'%s' was not found on this server", r.URL.Path)
+			return
+		}
+
+		repo, ok := mgr.FindByLabel(request.label)
+		if !ok {
+			HTTPNotFound(w, r, "no repository matches label '%s'", request.label)
+			return
+		}
+
+		// The revision is read once so that all the property sources belong to the same snapshot
+		revision := repo.Backend.Revision()
+		sources, err := springPropertySources(r.Context(), mgr, repo, revision, request, clientID, c.Server.PassPhrase)
+		if err != nil {
+			writeRepositoryError(w, r, err, repo.Configuration.Name, revision, request.application)
+			return
+		}
+
+		if len(request.format) > 0 {
+			content, err := document.Marshal(mergeSpringPropertySources(sources), request.format, nil)
+			if err != nil {
+				HTTPInternalServerError(w, r, "%s", err)
+				return
+			}
+			Ok(w, content, formatMimeTypes[request.format])
+			return
+		}
+
+		jsn, _ := json.Marshal(&SpringEnvironment{
+			Name:            request.application,
+			Profiles:        request.profiles,
+			Label:           request.label,
+			Version:         revision,
+			PropertySources: sources,
+		})
+		Ok(w, jsn, "application/json;charset=utf-8")
+	}
+}
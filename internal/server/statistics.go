@@ -7,10 +7,15 @@ import (
 	"github.com/fredjeck/configserver/internal/repository"
 )
 
-// Handles the clients file tokenization requests
+// Handles the repositories statistics requests. The endpoint is not authenticated, the hits per client are therefore
+// left out : administrators obtain the clients activity from /api/clients/{id}/activity
 func handleStatistics(mgr *repository.Manager) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		jsn, _ := json.Marshal(mgr.Statistics())
+		statistics := mgr.Statistics()
+		for _, snapshot := range statistics {
+			snapshot.ClientHits = nil
+		}
+		jsn, _ := json.Marshal(statistics)
 		Ok(w, jsn, "application/json;charset=utf-8")
 	}
 }
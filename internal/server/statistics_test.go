@@ -0,0 +1,38 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatisticsDoNotDiscloseClients(t *testing.T) {
+	repo := &configuration.Repository{Name: "samples", Backend: configuration.BackendLocal, Path: t.TempDir(), Clients: []string{clientID}}
+	assert.NoError(t, os.WriteFile(filepath.Join(repo.Path, "app.yml"), []byte("version: 1"), 0600))
+	mgr, err := repository.NewManager(&configuration.Repositories{Configuration: []*configuration.Repository{repo}})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mgr.Start(ctx)
+	assert.Eventually(t, func() bool { return mgr.Repositories["samples"].Backend.Available() }, 5*time.Second, 10*time.Millisecond)
+	_, err = mgr.Get(ctx, "samples", "", "app.yml", clientID)
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	handleStatistics(mgr)(w, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), clientID)
+	statistics := map[string]*repository.StatisticsSnapshot{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &statistics))
+	assert.Equal(t, map[string]int64{"app.yml": 1}, statistics["samples"].PathHits)
+	assert.Equal(t, map[string]int64{clientID: 1}, mgr.Statistics()["samples"].ClientHits, "client hits are kept")
+}
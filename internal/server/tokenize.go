@@ -10,8 +10,9 @@ import (
 )
 
 // Handles the clients file tokenization requests
-func handleFileTokenization(c *configuration.Configuration) func(w http.ResponseWriter, r *http.Request) {
+func handleFileTokenization(runtime *configuration.Runtime) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		c := runtime.Current()
 		contentType := r.Header.Get("Content-Type")
 		if len(contentType) == 0 || !strings.HasPrefix(contentType, "text") {
 			HTTPUnsupportedMediaType(w, r, "Unsupported content type '%s' only text/* is supported", contentType)
@@ -18,7 +18,7 @@ const tokenizeURL = "/api/tokenize"
 func TestMissingContentType(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, tokenizeURL, nil)
 	w := httptest.NewRecorder()
-	f := handleFileTokenization(TokenizeTestConfiguration)
+	f := handleFileTokenization(configuration.NewRuntime(TokenizeTestConfiguration))
 	f(w, req)
 	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
 }
@@ -27,7 +27,7 @@ func TestInvalidContentType(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, tokenizeURL, nil)
 	req.Header.Add("Content-Type", "image/png")
 	w := httptest.NewRecorder()
-	f := handleFileTokenization(TokenizeTestConfiguration)
+	f := handleFileTokenization(configuration.NewRuntime(TokenizeTestConfiguration))
 	f(w, req)
 	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
 }
@@ -45,7 +45,7 @@ func TestTokenization(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, tokenizeURL, strings.NewReader(body))
 	req.Header.Add("Content-Type", "text/plain")
 	w := httptest.NewRecorder()
-	f := handleFileTokenization(TokenizeTestConfiguration)
+	f := handleFileTokenization(configuration.NewRuntime(TokenizeTestConfiguration))
 	f(w, req)
 
 	res := w.Result()
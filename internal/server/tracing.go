@@ -0,0 +1,86 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/tracing"
+)
+
+// defaultServiceName is the name the traces are reported under when none is configured
+const defaultServiceName = "configserver"
+
+// newTracingProvider creates the provider exporting the traces as configured, nil is returned when tracing is disabled.
+// The returned function releases the resources held by the exporter once the provider is shut down
+func newTracingProvider(c *configuration.Tracing) (*tracing.Provider, func(), error) {
+	if c == nil || len(c.Exporter) == 0 {
+		return nil, func() {}, nil
+	}
+
+	serviceName := c.ServiceName
+	if len(serviceName) == 0 {
+		serviceName = defaultServiceName
+	}
+
+	switch c.Exporter {
+	case configuration.TracingOTLP:
+		headers := make(map[string]string, len(c.Headers))
+		for name, value := range c.Headers {
+			resolved, err := configuration.ResolveSecret(value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("tracing header '%s' cannot be resolved : %w", name, err)
+			}
+			headers[name] = resolved
+		}
+		return tracing.NewProvider(serviceName, tracing.NewOTLPExporter(c.Endpoint, headers)), func() {}, nil
+	case configuration.TracingFile:
+		file, err := os.OpenFile(c.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+		if err != nil {
+			return nil, nil, fmt.Errorf("'%s' traces file cannot be opened : %w", c.Path, err)
+		}
+		return tracing.NewProvider(serviceName, tracing.NewWriterExporter(file)), func() { _ = file.Close() }, nil
+	case configuration.TracingStdout:
+		return tracing.NewProvider(serviceName, tracing.NewWriterExporter(os.Stdout)), func() {}, nil
+	default:
+		return nil, nil, fmt.Errorf("'%s' is not a supported tracing exporter", c.Exporter)
+	}
+}
+
+// requestTracing creates a server span for each request, named after the matched route. Requests carrying a W3C
+// traceparent header are traced as children of the calling span
+func requestTracing(mux *http.ServeMux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if parent, ok := tracing.ParseTraceparent(r.Header.Get(tracing.TraceparentHeader)); ok {
+				ctx = tracing.ContextWithRemoteParent(ctx, parent)
+			}
+			_, route := mux.Handler(r)
+			if len(route) == 0 {
+				route = "unmatched"
+			}
+
+			ctx, span := tracing.StartServer(ctx, route,
+				tracing.String("http.request.method", r.Method),
+				tracing.String("http.route", route),
+				tracing.String("url.path", r.URL.Path),
+			)
+			defer span.End()
+
+			wrapped := wrapResponseWriter(w)
+			next.ServeHTTP(wrapped, r.WithContext(ctx))
+
+			status := wrapped.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttributes(tracing.Int("http.response.status_code", status))
+			if status >= http.StatusInternalServerError {
+				span.RecordError(fmt.Errorf("%d %s", status, http.StatusText(status)))
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/tracing"
+	"github.com/stretchr/testify/assert"
+)
+
+// exportedSpan is the subset of the OTLP JSON span encoding checked by the tests
+type exportedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Kind         int    `json:"kind"`
+	Status       struct {
+		Code int `json:"code"`
+	} `json:"status"`
+}
+
+// readSpans decodes the spans written to a traces file, indexed by trace id and name
+func readSpans(t *testing.T, path string) map[string]map[string]exportedSpan {
+	file, err := os.Open(path)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	defer file.Close()
+
+	traces := make(map[string]map[string]exportedSpan)
+	decoder := json.NewDecoder(file)
+	for {
+		var request struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []exportedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := decoder.Decode(&request); errors.Is(err, io.EOF) {
+			return traces
+		} else if !assert.NoError(t, err) {
+			return nil
+		}
+		for _, span := range request.ResourceSpans[0].ScopeSpans[0].Spans {
+			if traces[span.TraceID] == nil {
+				traces[span.TraceID] = make(map[string]exportedSpan)
+			}
+			traces[span.TraceID][span.Name] = span
+		}
+	}
+}
+
+func TestRequestTracing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+	provider, release, err := newTracingProvider(&configuration.Tracing{Exporter: configuration.TracingFile, Path: path})
+	assert.NoError(t, err)
+	defer release()
+	tracing.SetProvider(provider)
+	defer tracing.SetProvider(nil)
+
+	c := configuration.DefaultConfiguration
+	c.Server.PassPhrase = passPhrase
+	mux := http.NewServeMux()
+	mux.Handle("GET /git/{repository}/{path...}", authenticatedOnly(configuration.NewRuntime(c))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := detokenize(r.Context(), "plain text", passPhrase)
+		assert.NoError(t, err)
+	})))
+	handler := requestTracing(mux)(mux)
+
+	secret, _ := generateClientSecret(clientID, 360, passPhrase)
+	req := httptest.NewRequest(http.MethodGet, "/git/samples/app.yml", nil)
+	req.SetBasicAuth(clientID, secret)
+	req.Header.Set(tracing.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/git/samples/app.yml", nil)
+	req.SetBasicAuth(clientID, "invalid")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.NoError(t, provider.Shutdown(context.Background()))
+
+	traces := readSpans(t, path)
+	assert.Len(t, traces, 2)
+	spans := traces["4bf92f3577b34da6a3ce929d0e0e4736"]
+	server := spans["GET /git/{repository}/{path...}"]
+	assert.Equal(t, "00f067aa0ba902b7", server.ParentSpanID)
+	assert.Equal(t, int(tracing.KindServer), server.Kind)
+
+	assert.Equal(t, server.SpanID, spans["authenticate"].ParentSpanID)
+	assert.Equal(t, 0, spans["authenticate"].Status.Code)
+	assert.Equal(t, server.SpanID, spans["detokenize"].ParentSpanID)
+
+	// The second request carries no traceparent, starts a new trace and fails to authenticate
+	delete(traces, server.TraceID)
+	for _, spans := range traces {
+		assert.Equal(t, 2, spans["authenticate"].Status.Code)
+		assert.NotContains(t, spans, "detokenize")
+	}
+}
+
+func TestUnsupportedTracingExporter(t *testing.T) {
+	provider, _, err := newTracingProvider(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, provider)
+
+	_, _, err = newTracingProvider(&configuration.Tracing{Exporter: "zipkin"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/repository"
+)
+
+// defaultWatchTimeout is the time a long-polling client waits for a change when no timeout is requested
+const defaultWatchTimeout = 30 * time.Second
+
+// maxWatchTimeout is the longest time a long-polling client can wait for a change
+const maxWatchTimeout = 5 * time.Minute
+
+// watchKeepAlive is the interval at which comments are sent on idle event streams so that proxies keep them open
+const watchKeepAlive = 15 * time.Second
+
+// WatchEvent notifies a client that the content of a watched file changed
+type WatchEvent struct {
+	Repository string `json:"repository"`
+	Path       string `json:"path"`
+	Revision   string `json:"revision"`       // commit now served by the repository
+	ETag       string `json:"etag,omitempty"` // ETag the file is served with on the /git endpoint, empty if the file was deleted
+	Deleted    bool   `json:"deleted"`
+}
+
+// newWatchEvent converts a repository change to the event sent to the client
+func newWatchEvent(change repository.Change, passPhrase string) *WatchEvent {
+	event := &WatchEvent{
+		Repository: change.Repository,
+		Path:       change.Path,
+		Revision:   change.Revision,
+		Deleted:    len(change.Hash) == 0,
+	}
+	if !event.Deleted {
+		event.ETag = newValidators(passPhrase, "", &repository.File{Hash: change.Hash}).etag
+	}
+	return event
+}
+
+// watchTimeout reads the long-polling timeout, in seconds, from the timeout query parameter
+func watchTimeout(r *http.Request) time.Duration {
+	seconds, err := strconv.Atoi(r.URL.Query().Get("timeout"))
+	if err != nil || seconds <= 0 {
+		return defaultWatchTimeout
+	}
+	return min(time.Duration(seconds)*time.Second, maxWatchTimeout)
+}
+
+// acceptsEventStream returns true if the client asked for a server-sent events stream
+func acceptsEventStream(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted)); err == nil && mediaType == "text/event-stream" {
+			return true
+		}
+	}
+	return false
+}
+
+// shuttingDown returns a channel closed when the server starts shutting down, nil if the request is not served by a
+// ConfigServer
+func shuttingDown(r *http.Request) <-chan struct{} {
+	stopping, _ := r.Context().Value(ctxShutdown{}).(<-chan struct{})
+	return stopping
+}
+
+// handleWatch notifies clients when the content of a file changes in the tracked branch.
+// Clients requesting text/event-stream receive a server-sent event for each change, the event id being the file ETag.
+// Other clients are long-polled : the request is held until the file changes or the timeout expires, in which case
+// 304 Not Modified is returned. Clients providing the ETag of their copy via If-None-Match (or Last-Event-ID for
+// event streams) are notified immediately if their copy is outdated
+func handleWatch(mgr *repository.Manager, runtime *configuration.Runtime) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := runtime.Current()
+		clientID := r.Context().Value(ctxClientID{}).(string)
+		repo := r.PathValue("repository")
+		path := r.PathValue("path")
+
+		subscription, current, err := mgr.Watch(repo, path, clientID)
+		if err != nil {
+			writeRepositoryError(w, r, err, repo, "", path)
+			return
+		}
+		defer mgr.Unwatch(subscription)
+
+		event := newWatchEvent(current, c.Server.PassPhrase)
+		if acceptsEventStream(r) {
+			streamWatchEvents(w, r, subscription, event, c.Server.PassPhrase)
+			return
+		}
+
+		known := r.Header.Get("If-None-Match")
+		if len(known) > 0 && strings.TrimPrefix(known, "W/") != event.ETag {
+			jsn, _ := json.Marshal(event)
+			Ok(w, jsn, "application/json;charset=utf-8")
+			return
+		}
+
+		select {
+		case change := <-subscription.Changes():
+			jsn, _ := json.Marshal(newWatchEvent(change, c.Server.PassPhrase))
+			Ok(w, jsn, "application/json;charset=utf-8")
+		case <-time.After(watchTimeout(r)):
+			w.Header().Set("ETag", event.ETag)
+			w.WriteHeader(http.StatusNotModified)
+		case <-r.Context().Done():
+			// The client went away
+			w.Header().Set("ETag", event.ETag)
+			w.WriteHeader(http.StatusNotModified)
+		case <-shuttingDown(r):
+			// The client polls another instance
+			w.Header().Set("ETag", event.ETag)
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}
+}
+
+// streamWatchEvents streams the file changes as server-sent events until the client disconnects or the server shuts down
+func streamWatchEvents(w http.ResponseWriter, r *http.Request, subscription *repository.Subscription, current *WatchEvent, passPhrase string) {
+	controller := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if known := r.Header.Get("Last-Event-ID"); len(known) > 0 && known != current.ETag {
+		writeWatchEvent(w, current)
+	}
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(watchKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case change := <-subscription.Changes():
+			writeWatchEvent(w, newWatchEvent(change, passPhrase))
+		case <-keepAlive.C:
+			_, _ = fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		case <-shuttingDown(r):
+			return
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeWatchEvent writes a change server-sent event
+func writeWatchEvent(w http.ResponseWriter, event *WatchEvent) {
+	jsn, _ := json.Marshal(event)
+	_, _ = fmt.Fprintf(w, "id: %s\nevent: change\ndata: %s\n\n", event.ETag, jsn)
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchEventETag(t *testing.T) {
+	change := repository.Change{Repository: "repo", Path: "app.yml", Revision: "2c8b1e5", Hash: testFile.Hash}
+
+	event := newWatchEvent(change, "passphrase")
+	assert.False(t, event.Deleted)
+	assert.Equal(t, newValidators("passphrase", "", testFile).etag, event.ETag, "events must carry the ETag served by the /git endpoint")
+
+	change.Hash = ""
+	event = newWatchEvent(change, "passphrase")
+	assert.True(t, event.Deleted)
+	assert.Empty(t, event.ETag)
+}
+
+func TestWatchTimeout(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/watch/repo/app.yml", nil)
+	assert.Equal(t, defaultWatchTimeout, watchTimeout(req))
+
+	req = httptest.NewRequest(http.MethodGet, "/watch/repo/app.yml?timeout=5", nil)
+	assert.Equal(t, 5*time.Second, watchTimeout(req))
+
+	req = httptest.NewRequest(http.MethodGet, "/watch/repo/app.yml?timeout=86400", nil)
+	assert.Equal(t, maxWatchTimeout, watchTimeout(req))
+
+	req = httptest.NewRequest(http.MethodGet, "/watch/repo/app.yml?timeout=-1", nil)
+	assert.Equal(t, defaultWatchTimeout, watchTimeout(req))
+}
+
+func TestAcceptsEventStream(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/watch/repo/app.yml", nil)
+	assert.False(t, acceptsEventStream(req))
+
+	req.Header.Set("Accept", "application/json, text/event-stream;q=0.9")
+	assert.True(t, acceptsEventStream(req))
+}
+
+func TestWriteWatchEvent(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeWatchEvent(w, &WatchEvent{Repository: "repo", Path: "app.yml", Revision: "2c8b1e5", ETag: `"abc-1234"`})
+
+	assert.Equal(t, "id: \"abc-1234\"\nevent: change\ndata: {\"repository\":\"repo\",\"path\":\"app.yml\",\"revision\":\"2c8b1e5\",\"etag\":\"\\\"abc-1234\\\"\",\"deleted\":false}\n\n", w.Body.String())
+}
+
+func TestWatchEndsOnShutdown(t *testing.T) {
+	repo := &configuration.Repository{Name: "samples", Backend: configuration.BackendLocal, Path: t.TempDir(), Clients: []string{clientID}}
+	assert.NoError(t, os.WriteFile(filepath.Join(repo.Path, "app.yml"), []byte("version: 1"), 0600))
+	mgr, err := repository.NewManager(&configuration.Repositories{Configuration: []*configuration.Repository{repo}})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mgr.Start(ctx)
+	assert.Eventually(t, func() bool { return mgr.Repositories["samples"].Backend.Available() }, 5*time.Second, 10*time.Millisecond)
+
+	stopping := make(chan struct{})
+	ctx = context.WithValue(context.WithValue(ctx, ctxClientID{}, clientID), ctxShutdown{}, (<-chan struct{})(stopping))
+	req := httptest.NewRequest(http.MethodGet, "/watch/samples/app.yml", nil).WithContext(ctx)
+	req.SetPathValue("repository", "samples")
+	req.SetPathValue("path", "app.yml")
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		handleWatch(mgr, configuration.NewRuntime(configuration.DefaultConfiguration))(w, req)
+		close(done)
+	}()
+
+	close(stopping)
+	select {
+	case <-done:
+		assert.Equal(t, http.StatusNotModified, w.Code)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "long-polling requests must end when the server shuts down")
+	}
+	assert.NoError(t, req.Context().Err(), "in-flight requests are not cancelled")
+}
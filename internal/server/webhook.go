@@ -0,0 +1,218 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/repository"
+)
+
+// maxWebhookPayloadSize is the largest payload accepted from git providers (GitHub caps its payloads at 25MB)
+const maxWebhookPayloadSize = 25 << 20
+
+// WebhookResponse summarizes how a push notification was handled
+type WebhookResponse struct {
+	Refreshed []string `json:"refreshed"` // repositories for which a refresh was triggered
+	Ignored   []string `json:"ignored"`   // repositories matching the pushed url but tracking another branch or tag
+}
+
+// pushEvent is the provider agnostic representation of a push notification
+type pushEvent struct {
+	Ref           string   // pushed reference i.e refs/heads/main
+	DefaultBranch string   // remote's default branch if provided by the git provider
+	URLs          []string // all the urls the pushed repository is known as
+}
+
+// webhookProvider knows how to authenticate and decode the push notifications issued by a git provider
+type webhookProvider struct {
+	isPush func(r *http.Request) bool                                // true if the notification is a push event
+	verify func(r *http.Request, payload []byte, secret string) bool // true if the notification was signed with the provided secret
+	decode func(payload []byte) (*pushEvent, error)                  // extracts the push event from the notification's payload
+}
+
+var webhookProviders = map[string]*webhookProvider{
+	"github": {
+		isPush: func(r *http.Request) bool { return r.Header.Get("X-GitHub-Event") == "push" },
+		verify: func(r *http.Request, payload []byte, secret string) bool {
+			return verifyHMACSignature(r.Header.Get("X-Hub-Signature-256"), "sha256=", payload, secret)
+		},
+		decode: decodeGitHubPushEvent,
+	},
+	"gitea": {
+		isPush: func(r *http.Request) bool { return r.Header.Get("X-Gitea-Event") == "push" },
+		verify: func(r *http.Request, payload []byte, secret string) bool {
+			return verifyHMACSignature(r.Header.Get("X-Gitea-Signature"), "", payload, secret)
+		},
+		decode: decodeGitHubPushEvent, // Gitea payloads are modeled after GitHub's
+	},
+	"gitlab": {
+		isPush: func(r *http.Request) bool { return r.Header.Get("X-Gitlab-Event") == "Push Hook" },
+		verify: func(r *http.Request, _ []byte, secret string) bool {
+			return len(secret) > 0 && subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Gitlab-Token")), []byte(secret)) == 1
+		},
+		decode: decodeGitLabPushEvent,
+	},
+	"generic": {
+		isPush: func(r *http.Request) bool { return true },
+		verify: func(r *http.Request, payload []byte, secret string) bool {
+			return verifyHMACSignature(r.Header.Get("X-Hub-Signature-256"), "sha256=", payload, secret)
+		},
+		decode: decodeGenericPushEvent,
+	},
+}
+
+// verifyHMACSignature checks the hex encoded HMAC-SHA256 signature of the payload against the provided secret
+func verifyHMACSignature(signature string, prefix string, payload []byte, secret string) bool {
+	if len(secret) == 0 || !strings.HasPrefix(signature, prefix) {
+		return false
+	}
+
+	received, err := hex.DecodeString(strings.TrimPrefix(signature, prefix))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hmac.Equal(received, mac.Sum(nil))
+}
+
+func decodeGitHubPushEvent(payload []byte) (*pushEvent, error) {
+	var event struct {
+		Ref        string `json:"ref"`
+		Repository struct {
+			CloneURL      string `json:"clone_url"`
+			SSHURL        string `json:"ssh_url"`
+			HTMLURL       string `json:"html_url"`
+			GitURL        string `json:"git_url"`
+			DefaultBranch string `json:"default_branch"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+
+	return &pushEvent{
+		Ref:           event.Ref,
+		DefaultBranch: event.Repository.DefaultBranch,
+		URLs:          []string{event.Repository.CloneURL, event.Repository.SSHURL, event.Repository.HTMLURL, event.Repository.GitURL},
+	}, nil
+}
+
+func decodeGitLabPushEvent(payload []byte) (*pushEvent, error) {
+	var event struct {
+		Ref     string `json:"ref"`
+		Project struct {
+			GitHTTPURL    string `json:"git_http_url"`
+			GitSSHURL     string `json:"git_ssh_url"`
+			WebURL        string `json:"web_url"`
+			DefaultBranch string `json:"default_branch"`
+		} `json:"project"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+
+	return &pushEvent{
+		Ref:           event.Ref,
+		DefaultBranch: event.Project.DefaultBranch,
+		URLs:          []string{event.Project.GitHTTPURL, event.Project.GitSSHURL, event.Project.WebURL},
+	}, nil
+}
+
+// decodeGenericPushEvent decodes the minimal {"url": "...", "ref": "..."} payload, the ref can either be a fully
+// qualified reference or a plain branch name
+func decodeGenericPushEvent(payload []byte) (*pushEvent, error) {
+	var event struct {
+		URL string `json:"url"`
+		Ref string `json:"ref"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(event.Ref, "refs/") {
+		event.Ref = repository.BranchRefPrefix + event.Ref
+	}
+
+	return &pushEvent{
+		Ref:  event.Ref,
+		URLs: []string{event.URL},
+	}, nil
+}
+
+// handleWebhook wakes up the beholders of the repositories targeted by a git provider push notification
+func handleWebhook(mgr *repository.Manager) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID, _ := r.Context().Value(ctxRequestID{}).(string)
+		name := r.PathValue("provider")
+		provider, ok := webhookProviders[name]
+		if !ok {
+			HTTPNotFound(w, r, "webhook provider '%s' is not supported", name)
+			return
+		}
+
+		payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookPayloadSize))
+		if err != nil {
+			HTTPBadRequest(w, r, "cannot read webhook payload")
+			return
+		}
+
+		// Other events also describe the repository they relate to, which is needed to verify their signature
+		event, err := provider.decode(payload)
+		if err != nil {
+			HTTPBadRequest(w, r, "cannot decode '%s' event: %s", name, err)
+			return
+		}
+
+		// Every notification is authenticated before being looked at, notifications for untracked repositories are
+		// rejected as unauthenticated ones so that anonymous callers cannot probe which repositories are tracked
+		var notified []*repository.Repository
+		for _, repo := range mgr.FindByURL(event.URLs...) {
+			secret, err := configuration.ResolveSecret(repo.Configuration.WebhookSecret)
+			if err != nil {
+				slog.Error("webhook secret cannot be resolved", "error", err, "repository.name", repo.Configuration.Name, HTTPRequestID, requestID)
+				continue
+			}
+			if provider.verify(r, payload, secret) {
+				notified = append(notified, repo)
+			}
+		}
+		if len(notified) == 0 {
+			HTTPUnauthorized(w, r, "invalid '%s' webhook signature", name)
+			return
+		}
+
+		response := &WebhookResponse{Refreshed: []string{}, Ignored: []string{}}
+		if !provider.isPush(r) {
+			slog.Info(fmt.Sprintf("ignoring '%s' notification which is not a push event", name), HTTPRequestID, requestID)
+			jsn, _ := json.Marshal(response)
+			Ok(w, jsn, "application/json;charset=utf-8")
+			return
+		}
+
+		for _, repo := range notified {
+			if !repo.TracksRef(event.Ref, event.DefaultBranch) {
+				slog.Info(fmt.Sprintf("ignoring push to '%s' which is not tracked by '%s'", event.Ref, repo.Configuration.Name), "repository.name", repo.Configuration.Name, HTTPRequestID, requestID)
+				response.Ignored = append(response.Ignored, repo.Configuration.Name)
+				continue
+			}
+
+			slog.Info(fmt.Sprintf("push to '%s' received, refreshing '%s'", event.Ref, repo.Configuration.Name), "repository.name", repo.Configuration.Name, HTTPRequestID, requestID)
+			repo.Backend.Refresh()
+			response.Refreshed = append(response.Refreshed, repo.Configuration.Name)
+		}
+
+		jsn, _ := json.Marshal(response)
+		Ok(w, jsn, "application/json;charset=utf-8")
+	}
+}
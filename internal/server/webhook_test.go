@@ -0,0 +1,149 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fredjeck/configserver/internal/configuration"
+	"github.com/fredjeck/configserver/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+const webhookSecret = "webhook secret"
+
+func webhookTestManager(t *testing.T) *repository.Manager {
+	mgr, err := repository.NewManager(&configuration.Repositories{
+		CheckoutLocation: t.TempDir(),
+		Configuration: []*configuration.Repository{
+			{Name: "samples-main", URL: "https://github.com/fredjeck/configserver-samples.git", WebhookSecret: webhookSecret},
+			{Name: "samples-integration", URL: "git@github.com:fredjeck/configserver-samples.git", Branch: "integration", WebhookSecret: webhookSecret},
+		},
+	})
+	assert.NoError(t, err)
+	return mgr
+}
+
+func sign(payload string, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func sendWebhook(mgr *repository.Manager, provider string, payload string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/hooks/"+provider, strings.NewReader(payload))
+	req.SetPathValue("provider", provider)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	handleWebhook(mgr)(w, req)
+	return w
+}
+
+func decodeWebhookResponse(w *httptest.ResponseRecorder) *WebhookResponse {
+	data, _ := io.ReadAll(w.Result().Body)
+	response := &WebhookResponse{}
+	_ = json.Unmarshal(data, response)
+	return response
+}
+
+func TestNormalizeURL(t *testing.T) {
+	expected := "github.com/fredjeck/configserver-samples"
+	assert.Equal(t, expected, repository.NormalizeURL("https://github.com/fredjeck/configserver-samples.git"))
+	assert.Equal(t, expected, repository.NormalizeURL("https://GitHub.com/fredjeck/configserver-samples/"))
+	assert.Equal(t, expected, repository.NormalizeURL("git@github.com:fredjeck/configserver-samples.git"))
+	assert.Equal(t, expected, repository.NormalizeURL("ssh://git@github.com/fredjeck/configserver-samples.git"))
+}
+
+func TestGitHubWebhook(t *testing.T) {
+	payload := `{"ref":"refs/heads/integration","repository":{"clone_url":"https://github.com/fredjeck/configserver-samples.git","default_branch":"main"}}`
+	w := sendWebhook(webhookTestManager(t), "github", payload, map[string]string{
+		"X-GitHub-Event":      "push",
+		"X-Hub-Signature-256": "sha256=" + sign(payload, webhookSecret),
+	})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	response := decodeWebhookResponse(w)
+	assert.Equal(t, []string{"samples-integration"}, response.Refreshed)
+	assert.Equal(t, []string{"samples-main"}, response.Ignored)
+}
+
+func TestGitHubWebhookInvalidSignature(t *testing.T) {
+	payload := `{"ref":"refs/heads/main","repository":{"clone_url":"https://github.com/fredjeck/configserver-samples.git"}}`
+	w := sendWebhook(webhookTestManager(t), "github", payload, map[string]string{
+		"X-GitHub-Event":      "push",
+		"X-Hub-Signature-256": "sha256=" + sign(payload, "not the secret"),
+	})
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestGitHubWebhookOtherEvents(t *testing.T) {
+	payload := `{"zen":"Keep it logically awesome.","repository":{"clone_url":"https://github.com/fredjeck/configserver-samples.git"}}`
+	w := sendWebhook(webhookTestManager(t), "github", payload, map[string]string{"X-GitHub-Event": "ping"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "unsigned events are rejected whatever their type")
+
+	w = sendWebhook(webhookTestManager(t), "github", payload, map[string]string{
+		"X-GitHub-Event":      "ping",
+		"X-Hub-Signature-256": "sha256=" + sign(payload, webhookSecret),
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, decodeWebhookResponse(w).Refreshed, "events other than pushes are ignored")
+}
+
+func TestGitLabWebhook(t *testing.T) {
+	payload := `{"ref":"refs/heads/main","project":{"git_ssh_url":"git@github.com:fredjeck/configserver-samples.git","default_branch":"main"}}`
+	w := sendWebhook(webhookTestManager(t), "gitlab", payload, map[string]string{
+		"X-Gitlab-Event": "Push Hook",
+		"X-Gitlab-Token": webhookSecret,
+	})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"samples-main"}, decodeWebhookResponse(w).Refreshed)
+}
+
+func TestGiteaWebhook(t *testing.T) {
+	payload := `{"ref":"refs/heads/integration","repository":{"ssh_url":"git@github.com:fredjeck/configserver-samples.git"}}`
+	w := sendWebhook(webhookTestManager(t), "gitea", payload, map[string]string{
+		"X-Gitea-Event":     "push",
+		"X-Gitea-Signature": sign(payload, webhookSecret),
+	})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"samples-integration"}, decodeWebhookResponse(w).Refreshed)
+}
+
+func TestGenericWebhookUnknownRepository(t *testing.T) {
+	payload := `{"url":"https://example.com/unknown.git","ref":"main"}`
+	w := sendWebhook(webhookTestManager(t), "generic", payload, map[string]string{
+		"X-Hub-Signature-256": "sha256=" + sign(payload, webhookSecret),
+	})
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "untracked repositories are not disclosed")
+}
+
+func TestGitLabWebhookWithoutSecret(t *testing.T) {
+	mgr, err := repository.NewManager(&configuration.Repositories{
+		CheckoutLocation: t.TempDir(),
+		Configuration: []*configuration.Repository{
+			{Name: "samples-main", URL: "https://github.com/fredjeck/configserver-samples.git"},
+		},
+	})
+	assert.NoError(t, err)
+
+	payload := `{"ref":"refs/heads/main","project":{"git_http_url":"https://github.com/fredjeck/configserver-samples.git"}}`
+	w := sendWebhook(mgr, "gitlab", payload, map[string]string{"X-Gitlab-Event": "Push Hook"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "repositories without a webhook secret cannot be refreshed")
+}
+
+func TestUnsupportedWebhookProvider(t *testing.T) {
+	w := sendWebhook(webhookTestManager(t), "bitbucket", "{}", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,281 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxQueuedSpans is the number of ended spans waiting for export above which new spans are dropped
+const maxQueuedSpans = 2048
+
+// maxBatchSize is the largest number of spans exported at once
+const maxBatchSize = 512
+
+// exportInterval is the interval at which the ended spans are exported
+const exportInterval = 5 * time.Second
+
+// Exporter sends batches of spans, encoded as OTLP JSON ExportTraceServiceRequest documents, to their destination
+type Exporter interface {
+	Export(ctx context.Context, payload []byte) error
+}
+
+// Provider records the ended spans and exports them in batches in background
+type Provider struct {
+	serviceName string
+	exporter    Exporter
+	queue       chan *Span
+	flush       chan chan struct{}
+	stop        chan struct{}
+	stopped     chan struct{}
+	stopOnce    sync.Once
+}
+
+// NewProvider starts a provider exporting the spans using the provided exporter, spans are tagged with the service name
+func NewProvider(serviceName string, exporter Exporter) *Provider {
+	provider := &Provider{
+		serviceName: serviceName,
+		exporter:    exporter,
+		queue:       make(chan *Span, maxQueuedSpans),
+		flush:       make(chan chan struct{}),
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	go provider.run()
+	return provider
+}
+
+// enqueue hands an ended span for export, spans are dropped if the export falls behind
+func (p *Provider) enqueue(span *Span) {
+	select {
+	case p.queue <- span:
+	default:
+		slog.Warn("trace export queue is full, dropping span", "span.name", span.name)
+	}
+}
+
+// ForceFlush exports the spans ended so far
+func (p *Provider) ForceFlush(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case p.flush <- done:
+	case <-p.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown exports the spans ended so far and stops the provider
+func (p *Provider) Shutdown(ctx context.Context) error {
+	p.stopOnce.Do(func() { close(p.stop) })
+	select {
+	case <-p.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run exports the queued spans every exportInterval, when a batch is full or when a flush is requested
+func (p *Provider) run() {
+	defer close(p.stopped)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	export := func() {
+		for len(p.queue) > 0 && len(batch) < maxQueuedSpans {
+			batch = append(batch, <-p.queue)
+		}
+		for len(batch) > 0 {
+			size := min(len(batch), maxBatchSize)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := p.exporter.Export(ctx, encode(p.serviceName, batch[:size])); err != nil {
+				slog.Error("traces cannot be exported", "error", err, "trace.spans", size)
+			}
+			cancel()
+			batch = batch[size:]
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case span := <-p.queue:
+			batch = append(batch, span)
+			if len(batch) >= maxBatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case done := <-p.flush:
+			export()
+			close(done)
+		case <-p.stop:
+			export()
+			return
+		}
+	}
+}
+
+// The OTLP JSON encoding, see https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              Kind            `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"` // 0 unset, 2 error
+		Message string `json:"message,omitempty"`
+	}
+	otlpAttribute struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+)
+
+// encode converts the spans to an OTLP JSON ExportTraceServiceRequest document
+func encode(serviceName string, spans []*Span) []byte {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		span.mutex.Lock()
+		s := otlpSpan{
+			TraceID:           span.context.TraceID.String(),
+			SpanID:            span.context.SpanID.String(),
+			Name:              span.name,
+			Kind:              span.kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Attributes:        encodeAttributes(span.attributes),
+		}
+		if span.parent.IsValid() {
+			s.ParentSpanID = span.parent.String()
+		}
+		if span.failed {
+			s.Status = otlpStatus{Code: 2, Message: span.message}
+		}
+		span.mutex.Unlock()
+		encoded = append(encoded, s)
+	}
+
+	jsn, _ := json.Marshal(&otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: encodeAttributes([]Attribute{String("service.name", serviceName)})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "configserver"}, Spans: encoded}},
+	}}})
+	return jsn
+}
+
+// encodeAttributes converts attributes to their OTLP representation, 64 bits integers are encoded as strings
+func encodeAttributes(attributes []Attribute) []otlpAttribute {
+	encoded := make([]otlpAttribute, 0, len(attributes))
+	for _, attribute := range attributes {
+		var value map[string]any
+		switch v := attribute.Value.(type) {
+		case string:
+			value = map[string]any{"stringValue": v}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		encoded = append(encoded, otlpAttribute{Key: attribute.Key, Value: value})
+	}
+	return encoded
+}
+
+// WriterExporter appends each batch of spans, as a single line OTLP JSON document, to a writer i.e a file or stdout
+type WriterExporter struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+// NewWriterExporter creates an exporter writing to the provided writer
+func NewWriterExporter(writer io.Writer) *WriterExporter {
+	return &WriterExporter{writer: writer}
+}
+
+// Export writes the batch followed by a new line
+func (e *WriterExporter) Export(_ context.Context, payload []byte) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	_, err := e.writer.Write(append(payload, '\n'))
+	return err
+}
+
+// OTLPExporter sends the batches of spans to an OpenTelemetry collector using the OTLP/HTTP protocol
+type OTLPExporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+// NewOTLPExporter creates an exporter posting to the provided traces endpoint i.e http://localhost:4318/v1/traces,
+// headers are sent along each request
+func NewOTLPExporter(endpoint string, headers map[string]string) *OTLPExporter {
+	return &OTLPExporter{endpoint: endpoint, headers: headers, client: &http.Client{}}
+}
+
+// Export posts the batch to the collector
+func (e *OTLPExporter) Export(ctx context.Context, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("'%s' : invalid traces endpoint : %w", e.endpoint, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range e.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("'%s' : traces cannot be sent : %w", e.endpoint, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("'%s' : traces were rejected with status %d", e.endpoint, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+// Package tracing implements distributed tracing compatible with OpenTelemetry : spans are propagated using the W3C
+// trace context headers and exported using the OTLP/HTTP JSON encoding
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceparentHeader is the W3C trace context header carrying the parent span of a request
+const TraceparentHeader = "traceparent"
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// String returns the hex encoded trace id
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// IsValid returns false for the all zero trace id
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+// String returns the hex encoded span id
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// IsValid returns false for the all zero span id
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// SpanContext is the part of a span propagated across process boundaries
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool // false if the trace is not recorded
+}
+
+// IsValid returns true if the span context designates a span
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent returns the W3C traceparent header value designating the span
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent decodes a W3C traceparent header value, false is returned if the value is invalid
+func ParseTraceparent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	var flags [1]byte
+	for _, field := range []struct {
+		value string
+		into  []byte
+	}{{parts[0], make([]byte, 1)}, {parts[1], sc.TraceID[:]}, {parts[2], sc.SpanID[:]}, {parts[3], flags[:]}} {
+		if len(field.value) != 2*len(field.into) || field.value != strings.ToLower(field.value) {
+			return SpanContext{}, false
+		}
+		if _, err := hex.Decode(field.into, []byte(field.value)); err != nil {
+			return SpanContext{}, false
+		}
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.IsValid()
+}
+
+// Kind qualifies the relationship of a span with its parent and children, values match the OTLP encoding
+type Kind int
+
+const (
+	KindInternal Kind = 1 // KindInternal designates operations which do not cross process boundaries
+	KindServer   Kind = 2 // KindServer designates the handling of incoming requests
+	KindClient   Kind = 3 // KindClient designates outgoing requests
+)
+
+// Attribute is a key value pair describing a span, values can be strings, booleans, integers or floats
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// String creates a string attribute
+func String(key string, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int creates an integer attribute
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: int64(value)}
+}
+
+// Bool creates a boolean attribute
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Span measures an operation. Spans which are not sampled, or created while no provider is set, are not recorded but
+// still propagate their context
+type Span struct {
+	provider *Provider // nil for spans which are not recorded
+	context  SpanContext
+	parent   SpanID
+	name     string
+	kind     Kind
+	start    time.Time
+
+	mutex      sync.Mutex
+	end        time.Time
+	attributes []Attribute
+	failed     bool
+	message    string
+}
+
+// Context returns the span context to be propagated
+func (s *Span) Context() SpanContext {
+	return s.context
+}
+
+// IsRecording returns true if the span will be exported
+func (s *Span) IsRecording() bool {
+	return s.provider != nil
+}
+
+// SetAttributes adds attributes to the span
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.attributes = append(s.attributes, attributes...)
+}
+
+// RecordError flags the span as failed, nil errors are ignored
+func (s *Span) RecordError(err error) {
+	if err == nil || !s.IsRecording() {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failed = true
+	s.message = err.Error()
+}
+
+// End completes the span and hands it to the provider for export, only the first call is effective
+func (s *Span) End() {
+	if !s.IsRecording() {
+		return
+	}
+	s.mutex.Lock()
+	if !s.end.IsZero() {
+		s.mutex.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.mutex.Unlock()
+	s.provider.enqueue(s)
+}
+
+type spanKey struct{}
+
+// FromContext returns the span held by the context or a span which is not recorded if there is none
+func FromContext(ctx context.Context) *Span {
+	if span, ok := ctx.Value(spanKey{}).(*Span); ok {
+		return span
+	}
+	return &Span{}
+}
+
+// ContextWithRemoteParent returns a context whose spans are children of the provided remote span
+func ContextWithRemoteParent(ctx context.Context, parent SpanContext) context.Context {
+	return context.WithValue(ctx, spanKey{}, &Span{context: parent})
+}
+
+// current is the provider recording the spans, nil when tracing is disabled
+var current atomic.Pointer[Provider]
+
+// SetProvider sets the provider recording the spans, nil disables tracing
+func SetProvider(provider *Provider) {
+	current.Store(provider)
+}
+
+// Start creates an internal span, child of the span held by the context. The returned context holds the new span
+func Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, *Span) {
+	return start(ctx, KindInternal, name, attributes)
+}
+
+// StartServer creates a span measuring the handling of an incoming request
+func StartServer(ctx context.Context, name string, attributes ...Attribute) (context.Context, *Span) {
+	return start(ctx, KindServer, name, attributes)
+}
+
+// StartClient creates a span measuring an outgoing request
+func StartClient(ctx context.Context, name string, attributes ...Attribute) (context.Context, *Span) {
+	return start(ctx, KindClient, name, attributes)
+}
+
+func start(ctx context.Context, kind Kind, name string, attributes []Attribute) (context.Context, *Span) {
+	parent := FromContext(ctx).context
+	span := &Span{name: name, kind: kind, start: time.Now()}
+
+	span.context.Sampled = true
+	if parent.IsValid() {
+		span.context.TraceID, span.parent, span.context.Sampled = parent.TraceID, parent.SpanID, parent.Sampled
+	} else {
+		_, _ = rand.Read(span.context.TraceID[:])
+	}
+	_, _ = rand.Read(span.context.SpanID[:])
+
+	if provider := current.Load(); provider != nil && span.context.Sampled {
+		span.provider = provider
+		span.attributes = attributes
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
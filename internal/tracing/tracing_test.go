@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceparent(t *testing.T) {
+	sc, ok := ParseTraceparent(traceparent)
+	assert.True(t, ok)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	assert.True(t, sc.Sampled)
+	assert.Equal(t, traceparent, sc.Traceparent())
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		_, ok := ParseTraceparent(invalid)
+		assert.False(t, ok, invalid)
+	}
+}
+
+// decoded reads the spans written by a writer exporter
+func decoded(t *testing.T, output *bytes.Buffer) []otlpSpan {
+	var spans []otlpSpan
+	decoder := json.NewDecoder(output)
+	for {
+		var request otlpRequest
+		if err := decoder.Decode(&request); errors.Is(err, io.EOF) {
+			return spans
+		} else if !assert.NoError(t, err) {
+			return nil
+		}
+		assert.Equal(t, "configserver-test", request.ResourceSpans[0].Resource.Attributes[0].Value["stringValue"])
+		spans = append(spans, request.ResourceSpans[0].ScopeSpans[0].Spans...)
+	}
+}
+
+func TestSpansAreExported(t *testing.T) {
+	output := &bytes.Buffer{}
+	provider := NewProvider("configserver-test", NewWriterExporter(output))
+	SetProvider(provider)
+	defer SetProvider(nil)
+
+	sc, _ := ParseTraceparent(traceparent)
+	ctx, server := StartServer(ContextWithRemoteParent(context.Background(), sc), "GET /git/{repository}/{path...}", String("http.request.method", "GET"))
+	_, child := Start(ctx, "repository.read", Int("size", 42))
+	child.RecordError(errors.New("file not found"))
+	child.End()
+	server.End()
+	assert.NoError(t, provider.Shutdown(context.Background()))
+
+	spans := decoded(t, output)
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "repository.read", spans[0].Name)
+	assert.Equal(t, KindInternal, spans[0].Kind)
+	assert.Equal(t, server.Context().SpanID.String(), spans[0].ParentSpanID)
+	assert.Equal(t, "42", spans[0].Attributes[0].Value["intValue"])
+	assert.Equal(t, otlpStatus{Code: 2, Message: "file not found"}, spans[0].Status)
+	assert.Equal(t, KindServer, spans[1].Kind)
+	assert.Equal(t, sc.TraceID.String(), spans[1].TraceID)
+	assert.Equal(t, sc.SpanID.String(), spans[1].ParentSpanID)
+}
+
+func TestUnsampledSpansAreNotRecorded(t *testing.T) {
+	output := &bytes.Buffer{}
+	provider := NewProvider("configserver-test", NewWriterExporter(output))
+	SetProvider(provider)
+	defer SetProvider(nil)
+
+	sc, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	ctx, span := Start(ContextWithRemoteParent(context.Background(), sc), "ignored")
+	assert.False(t, span.IsRecording())
+	assert.Equal(t, sc.TraceID, FromContext(ctx).Context().TraceID, "unsampled spans must still propagate the trace")
+	span.End()
+	assert.NoError(t, provider.Shutdown(context.Background()))
+	assert.Empty(t, output.String())
+}
+
+func TestSpansAreNotRecordedWithoutProvider(t *testing.T) {
+	_, span := Start(context.Background(), "ignored")
+	assert.False(t, span.IsRecording())
+	assert.True(t, span.Context().IsValid())
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var received otlpRequest
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "secret", r.Header.Get("Authorization"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer collector.Close()
+
+	provider := NewProvider("configserver-test", NewOTLPExporter(collector.URL+"/v1/traces", map[string]string{"Authorization": "secret"}))
+	SetProvider(provider)
+	defer SetProvider(nil)
+	_, span := Start(context.Background(), "exported")
+	span.End()
+	assert.NoError(t, provider.ForceFlush(context.Background()))
+	assert.Equal(t, "exported", received.ResourceSpans[0].ScopeSpans[0].Spans[0].Name)
+
+	assert.Error(t, NewOTLPExporter(collector.URL+"/unknown\x00", nil).Export(context.Background(), nil))
+}
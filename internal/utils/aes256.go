@@ -5,6 +5,7 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/hex"
 )
 
 // deriveKeyFromPassPhrase derives an AES-256 compatible key from the provided password
@@ -12,6 +13,14 @@ func deriveKeyFromPassPhrase(password string) [32]byte {
 	return sha256.Sum256([]byte(password))
 }
 
+// KeyVersion returns a short fingerprint of the key derived from the provided passphrase.
+// The fingerprint changes whenever the passphrase is rotated but cannot be used to recover the key
+func KeyVersion(passPhrase string) string {
+	secretKey := deriveKeyFromPassPhrase(passPhrase)
+	fingerprint := sha256.Sum256(secretKey[:])
+	return hex.EncodeToString(fingerprint[:4])
+}
+
 // AesEncrypt uses AES256GCP to encrypt the provided plainText string using the given passPhrase
 func AesEncrypt(plainText string, passPhrase string) []byte {
 	secretKey := deriveKeyFromPassPhrase(passPhrase)
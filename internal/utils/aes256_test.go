@@ -28,3 +28,11 @@ func TestAesFailToDecrypt(t *testing.T) {
 
 	assert.Error(t, err)
 }
+
+func TestKeyVersion(t *testing.T) {
+	version := KeyVersion("This is a really long passphrase")
+
+	assert.Len(t, version, 8)
+	assert.Equal(t, version, KeyVersion("This is a really long passphrase"))
+	assert.NotEqual(t, version, KeyVersion("This is another passphrase"))
+}
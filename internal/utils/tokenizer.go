@@ -14,6 +14,9 @@ var ErrCannotDecryptTokenContent = errors.New("unable to decrypt the token's con
 // Regex used to extract the value from a substitution token
 var reToken = regexp.MustCompile(`\{enc:(.*?)}`)
 
+// MaskedToken replaces the substitution tokens whose content must not be disclosed
+const MaskedToken = "{enc:***}"
+
 // DecryptToken extracts the payload from the provided substition token and decrypts its value using the given key
 func DecryptToken(token string, passphrase string) (string, error) {
 	if len(token) == 0 {
@@ -78,3 +81,8 @@ func Tokenize(text string, passphrase string) (string, error) {
 
 	return text, nil
 }
+
+// MaskTokens replaces all the substitution tokens by MaskedToken, only the presence of a sensitive value is disclosed
+func MaskTokens(text string) string {
+	return reToken.ReplaceAllLiteralString(text, MaskedToken)
+}
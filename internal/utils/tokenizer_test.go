@@ -44,3 +44,9 @@ func TestTokenSubstitution(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "p1='value 1';p2='value 2';p3='value 3';p4='value 4';", clearText)
 }
+
+func TestMaskTokens(t *testing.T) {
+	text := fmt.Sprintf("user=admin;password=%s;key=%s", CreateToken("secret", passphrase), CreateToken("key", passphrase))
+	assert.Equal(t, "user=admin;password={enc:***};key={enc:***}", MaskTokens(text))
+	assert.Equal(t, "user=admin", MaskTokens("user=admin"))
+}